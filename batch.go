@@ -0,0 +1,90 @@
+package gohttp
+
+import (
+	"context"
+	"sync"
+)
+
+// defaultBatchConcurrency is the MaxConcurrency of a new BatchBuilder
+const defaultBatchConcurrency = 8
+
+// Result is the outcome of a request sent by a BatchBuilder
+type Result struct {
+	Resp *Response
+	Err  error
+}
+
+// BatchBuilder builds requests from a base request and sends them
+// concurrently
+type BatchBuilder struct {
+	// MaxConcurrency is the maximum number of requests in flight, all of
+	// them are sent at once when it is zero or less
+	MaxConcurrency int
+
+	base  *Request
+	calls []batchCall
+}
+
+type batchCall struct {
+	method string
+	url    string
+	opts   []func(*Request)
+}
+
+// NewBatchRequest returns a BatchBuilder for requests based on base
+func NewBatchRequest(base *Request) *BatchBuilder {
+	return &BatchBuilder{
+		MaxConcurrency: defaultBatchConcurrency,
+		base:           base,
+	}
+}
+
+// Add adds a request with method to url. It is a copy of the base request
+// with opts applied, e.g. Option functions or func(r *Request) { r.Query(q) }.
+// The copies share the http client of the base request, and so its
+// connections, like the ones of Clone, options building the client have no
+// effect on them.
+func (b *BatchBuilder) Add(method, url string, opts ...func(*Request)) *BatchBuilder {
+	b.calls = append(b.calls, batchCall{method: method, url: url, opts: opts})
+	return b
+}
+
+// Execute sends the requests with ctx and returns their results in the
+// order they were added. Requests not started when ctx is done fail with
+// the context error, a *CanceledError when ctx was canceled with a cause.
+func (b *BatchBuilder) Execute(ctx context.Context) []Result {
+	results := make([]Result, len(b.calls))
+
+	limit := b.MaxConcurrency
+	if limit <= 0 || limit > len(b.calls) {
+		limit = len(b.calls)
+	}
+	sem := make(chan struct{}, limit)
+
+	client := b.base.createClient()
+	var wg sync.WaitGroup
+	for i, call := range b.calls {
+		req := b.base.clone().SetContext(ctx)
+		req.client = client
+		for _, opt := range call.opts {
+			opt(req)
+		}
+
+		select {
+		case sem <- struct{}{}:
+		case <-ctx.Done():
+			results[i].Err = withCancelCause(ctx, ctx.Err())
+			continue
+		}
+
+		wg.Add(1)
+		go func(i int, req *Request, call batchCall) {
+			defer wg.Done()
+			defer func() { <-sem }()
+			results[i].Resp, results[i].Err = req.Do(call.method, call.url)
+		}(i, req, call)
+	}
+	wg.Wait()
+
+	return results
+}
@@ -0,0 +1,178 @@
+package gohttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestBatchRequest tests requests are sent concurrently and results kept in order
+func TestBatchRequest(t *testing.T) {
+	t.Log("Sending batch of requests... (expected ordered results and limited concurrency)")
+
+	var inFlight, maxInFlight int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&inFlight, 1)
+		defer atomic.AddInt32(&inFlight, -1)
+		for {
+			max := atomic.LoadInt32(&maxInFlight)
+			if n <= max || atomic.CompareAndSwapInt32(&maxInFlight, max, n) {
+				break
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+		fmt.Fprintf(w, "%s %s %s %s", r.Method, r.URL.Path, r.Header.Get("X-Base"), r.URL.RawQuery)
+	}))
+	defer ts.Close()
+
+	base := NewRequest().Headers(map[string]string{"X-Base": "yes"})
+	batch := NewBatchRequest(base)
+	batch.MaxConcurrency = 2
+
+	var expected []string
+	for i := 0; i < 6; i++ {
+		q := fmt.Sprintf("n=%d", i)
+		batch.Add(http.MethodGet, fmt.Sprintf("%s/%d", ts.URL, i), func(r *Request) {
+			r.Query(map[string]string{"n": q[2:]})
+		})
+		expected = append(expected, fmt.Sprintf("GET /%d yes %s", i, q))
+	}
+	batch.Add("delete", ts.URL+"/last")
+	expected = append(expected, "DELETE /last yes ")
+
+	results := batch.Execute(context.Background())
+
+	for i, res := range results {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if body, _ := res.Resp.GetBodyAsString(); body != expected[i] {
+			t.Error(
+				"For", "result", i,
+				"expected", expected[i],
+				"got", body,
+			)
+		}
+	}
+
+	if maxInFlight != 2 || base.headers["X-Base"] != "yes" || len(base.queryVals) != 0 {
+		t.Error(
+			"For", "MaxConcurrency",
+			"expected", 2, "and unchanged base",
+			"got", maxInFlight, base.queryVals,
+		)
+	}
+}
+
+// TestBatchRequestCancel tests requests not started fail with the context error
+func TestBatchRequestCancel(t *testing.T) {
+	t.Log("Sending batch with cancelled context... (expected context errors)")
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	results := NewBatchRequest(NewRequest()).
+		Add(http.MethodGet, "http://example.com/a").
+		Add(http.MethodGet, "http://example.com/b").
+		Execute(ctx)
+
+	for _, res := range results {
+		if res.Err == nil {
+			t.Error(
+				"For", "cancelled batch",
+				"expected", context.Canceled,
+				"got", res.Err,
+			)
+		}
+	}
+}
+
+// TestBatchRequestMultipart tests each request extends the base multipart form
+func TestBatchRequestMultipart(t *testing.T) {
+	t.Log("Sending batch of multipart requests... (expected base and own fields)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, r.MultipartForm.Value, len(r.MultipartForm.File["file"]))
+	}))
+	defer ts.Close()
+
+	// the clones share the reader of the base, it is read once buffered
+	base := NewRequest(WithBufferedUploads()).MultipartFormData(map[string]string{"base": "1"}).UploadFromReader(MultipartParam{
+		FieldName: "file",
+		FileName:  "file.txt",
+		FileBody:  strings.NewReader("content"),
+	})
+	results := NewBatchRequest(base).
+		Add(http.MethodPost, ts.URL, func(r *Request) {
+			r.MultipartFormData(map[string]string{"own": "a"})
+		}).
+		Add(http.MethodPost, ts.URL).
+		Execute(context.Background())
+
+	expected := []string{"map[base:[1] own:[a]] 1", "map[base:[1]] 1"}
+	for i, res := range results {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+		if body, _ := res.Resp.GetBodyAsString(); body != expected[i] {
+			t.Error(
+				"For", "result", i,
+				"expected", expected[i],
+				"got", body,
+			)
+		}
+	}
+}
+
+// TestBatchRequestReusesConnections tests the requests of a batch share
+// the connections of their base request, also with a transport it builds
+func TestBatchRequestReusesConnections(t *testing.T) {
+	t.Log("Sending batch of requests with built transport... (expected reused connections)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var mu sync.Mutex
+	var reused []bool
+	ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			mu.Lock()
+			defer mu.Unlock()
+			reused = append(reused, info.Reused)
+		},
+	})
+
+	// the connect timeout makes every client build its own transport
+	base := NewRequest(WithConnectTimeout(5 * time.Second))
+	batch := NewBatchRequest(base)
+	batch.MaxConcurrency = 1
+	for i := 0; i < 3; i++ {
+		batch.Add(http.MethodGet, ts.URL)
+	}
+
+	for _, res := range batch.Execute(ctx) {
+		if res.Err != nil {
+			t.Fatal(res.Err)
+		}
+	}
+
+	base.createClient().CloseIdleConnections()
+	if fmt.Sprint(reused) != "[false true true]" {
+		t.Error(
+			"For", "GotConn.Reused",
+			"expected", "[false true true]",
+			"got", reused,
+		)
+	}
+}
@@ -0,0 +1,139 @@
+package gohttp
+
+import (
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// The benchmarks send requests to local servers, run them with -benchmem to
+// catch allocation regressions. BenchmarkBaseline is the cost of net/http
+// alone, the overhead of gohttp is the difference to BenchmarkGet.
+
+// newDiscardServer returns a server reading the request body and answering
+// with a short JSON body
+func newDiscardServer(b *testing.B) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	b.Cleanup(ts.Close)
+	return ts
+}
+
+// readBody reads and closes the body of resp, or returns err
+func readBody(resp *Response, err error) error {
+	if err != nil {
+		return err
+	}
+	_, err = resp.GetBodyAsByte()
+	return err
+}
+
+func BenchmarkBaseline(b *testing.B) {
+	ts := newDiscardServer(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		resp, err := http.DefaultClient.Get(ts.URL)
+		if err != nil {
+			b.Fatal(err)
+		}
+		io.Copy(ioutil.Discard, resp.Body)
+		resp.Body.Close()
+	}
+}
+
+func BenchmarkGet(b *testing.B) {
+	ts := newDiscardServer(b)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := readBody(NewRequest().Get(ts.URL)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkPostJSON(b *testing.B) {
+	ts := newDiscardServer(b)
+	body := map[string]interface{}{"name": "gohttp", "tags": []string{"http", "client"}, "stars": 42}
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := readBody(NewRequest().JSON(body).Post(ts.URL)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkMultipartUpload1MB(b *testing.B) {
+	ts := newDiscardServer(b)
+	path := filepath.Join(b.TempDir(), "upload.bin")
+	if err := ioutil.WriteFile(path, make([]byte, 1<<20), 0644); err != nil {
+		b.Fatal(err)
+	}
+	b.SetBytes(1 << 20)
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := readBody(NewRequest().Upload("file", path).Post(ts.URL)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkConcurrent100(b *testing.B) {
+	ts := newDiscardServer(b)
+	// keeps the connections of every goroutine for the next iteration
+	base := NewRequest(SetTransport(&http.Transport{MaxIdleConnsPerHost: 100}))
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		var wg sync.WaitGroup
+		for j := 0; j < 100; j++ {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				resp, err := base.Clone().Get(ts.URL)
+				if err != nil {
+					b.Error(err)
+					return
+				}
+				resp.GetBodyAsByte()
+			}()
+		}
+		wg.Wait()
+	}
+}
+
+func BenchmarkRetryWith3Attempts(b *testing.B) {
+	var calls int64
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt64(&calls, 1)%3 != 0 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.Write([]byte(`{"ok":true}`))
+	}))
+	defer ts.Close()
+	b.ReportAllocs()
+	b.ResetTimer()
+
+	for i := 0; i < b.N; i++ {
+		if err := readBody(NewRequest(WithRetry(2, 0)).Get(ts.URL)); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+package gohttp
+
+import (
+	"io"
+	"io/ioutil"
+	"sync/atomic"
+)
+
+// readerBody is a request body read from the io.Reader set with
+// BodyReader, or from a new one of BodyReaderFunc or BodyFile for every
+// attempt
+type readerBody struct {
+	r       io.Reader
+	newBody func() (io.ReadCloser, error)
+	// length is the Content-Length, -1 when it is unknown
+	length int64
+	// offset is where a seekable reader is rewound to for every attempt
+	offset   int64
+	seekable bool
+	read     int32
+}
+
+func newReaderBody(r io.Reader, length int64) *readerBody {
+	b := &readerBody{r: r, length: length}
+	if s, ok := r.(io.Seeker); ok {
+		if offset, err := s.Seek(0, io.SeekCurrent); err == nil {
+			b.offset, b.seekable = offset, true
+		}
+	}
+	return b
+}
+
+// open returns the body for an attempt, a seekable reader is rewound while
+// another one fails with ErrBodyNotReplayable once it was read
+func (b *readerBody) open() (io.ReadCloser, error) {
+	if b.newBody != nil {
+		return b.newBody()
+	}
+	if b.seekable {
+		if _, err := b.r.(io.Seeker).Seek(b.offset, io.SeekStart); err != nil {
+			return nil, err
+		}
+	} else if atomic.SwapInt32(&b.read, 1) == 1 {
+		return nil, ErrBodyNotReplayable
+	}
+	return ioutil.NopCloser(b.r), nil
+}
@@ -0,0 +1,152 @@
+package gohttp
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrRangeNotSatisfiable is returned when the server answered a range
+// request with 416 Range Not Satisfiable
+var ErrRangeNotSatisfiable = errors.New("gohttp: range not satisfiable")
+
+// ByteRange is a single range of a 206 Partial Content response. Total is
+// -1 when the server does not know the complete length.
+type ByteRange struct {
+	Start int64
+	End   int64
+	Total int64
+	Body  []byte
+}
+
+// ByteRanges reads a 206 Partial Content response into its ranges ordered
+// by start offset. Both single range responses and multipart/byteranges
+// responses are supported, every Content-Range is checked against the
+// data received.
+func (res *Response) ByteRanges() ([]ByteRange, error) {
+	body := res.GetBody()
+	if body == nil {
+		return nil, nil
+	}
+	defer body.Close()
+
+	switch res.resp.StatusCode {
+	case http.StatusPartialContent:
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, ErrRangeNotSatisfiable
+	default:
+		return nil, fmt.Errorf("gohttp: expected status 206, got %d", res.resp.StatusCode)
+	}
+
+	mediaType, params, err := mime.ParseMediaType(res.resp.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/byteranges" {
+		br, err := readByteRange(res.resp.Header.Get("Content-Range"), body)
+		if err != nil {
+			return nil, err
+		}
+		return []ByteRange{br}, nil
+	}
+
+	var ranges []ByteRange
+	mr := multipart.NewReader(body, params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		br, err := readByteRange(part.Header.Get("Content-Range"), part)
+		if err != nil {
+			return nil, err
+		}
+		ranges = append(ranges, br)
+	}
+
+	sort.SliceStable(ranges, func(i, j int) bool {
+		return ranges[i].Start < ranges[j].Start
+	})
+
+	return ranges, nil
+}
+
+// ContentRange returns the range of a single range partial response, read
+// from its Content-Range header, e.g. to resume a download. Total is -1
+// when the server does not know the complete length. Ok is false without
+// a valid satisfied range, e.g. for the "bytes */1000" of a 416 response.
+func (res *Response) ContentRange() (start, end, total int64, ok bool) {
+	if res == nil || res.resp == nil {
+		return 0, 0, 0, false
+	}
+	start, end, total, err := parseContentRange(res.resp.Header.Get("Content-Range"))
+	if err != nil {
+		return 0, 0, 0, false
+	}
+	return start, end, total, true
+}
+
+// readByteRange reads the range described by contentRange from r
+func readByteRange(contentRange string, r io.Reader) (ByteRange, error) {
+	start, end, total, err := parseContentRange(contentRange)
+	if err != nil {
+		return ByteRange{}, err
+	}
+
+	body, err := ioutil.ReadAll(r)
+	if err != nil {
+		return ByteRange{}, err
+	}
+
+	if int64(len(body)) != end-start+1 {
+		return ByteRange{}, fmt.Errorf("gohttp: Content-Range %q does not match %d bytes received", contentRange, len(body))
+	}
+
+	return ByteRange{Start: start, End: end, Total: total, Body: body}, nil
+}
+
+// parseContentRange parses a satisfied byte range such as "bytes 0-99/1000"
+// or "bytes 0-99/*", the total is -1 when it is unknown
+func parseContentRange(val string) (start, end, total int64, err error) {
+	invalid := fmt.Errorf("gohttp: invalid Content-Range %q", val)
+
+	if !strings.HasPrefix(val, "bytes ") {
+		return 0, 0, 0, invalid
+	}
+
+	span := strings.TrimSpace(strings.TrimPrefix(val, "bytes "))
+	slash := strings.IndexByte(span, '/')
+	if slash < 0 {
+		return 0, 0, 0, invalid
+	}
+	span, length := span[:slash], span[slash+1:]
+
+	dash := strings.IndexByte(span, '-')
+	if dash < 0 {
+		return 0, 0, 0, invalid
+	}
+
+	if start, err = strconv.ParseInt(span[:dash], 10, 64); err != nil || start < 0 {
+		return 0, 0, 0, invalid
+	}
+	if end, err = strconv.ParseInt(span[dash+1:], 10, 64); err != nil || end < start {
+		return 0, 0, 0, invalid
+	}
+
+	total = -1
+	if length != "*" {
+		if total, err = strconv.ParseInt(length, 10, 64); err != nil || end >= total {
+			return 0, 0, 0, invalid
+		}
+	}
+
+	return start, end, total, nil
+}
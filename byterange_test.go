@@ -0,0 +1,159 @@
+package gohttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const rangeContent = "0123456789abcdefghijklmnopqrstuvwxyz"
+
+// newRangeServer returns a server serving rangeContent with range support
+func newRangeServer(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.ServeContent(w, r, "content.txt", time.Time{}, strings.NewReader(rangeContent))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// TestByteRanges tests ByteRanges for single and multiple ranges
+func TestByteRanges(t *testing.T) {
+	t.Log("Sending range requests... (expected parsed ranges)")
+
+	ts := newRangeServer(t)
+
+	tests := []struct {
+		rangeHeader string
+		expected    []ByteRange
+	}{
+		{"bytes=0-3", []ByteRange{
+			{Start: 0, End: 3, Total: 36, Body: []byte("0123")},
+		}},
+		{"bytes=10-12,30-35", []ByteRange{
+			{Start: 10, End: 12, Total: 36, Body: []byte("abc")},
+			{Start: 30, End: 35, Total: 36, Body: []byte("uvwxyz")},
+		}},
+	}
+
+	for _, tt := range tests {
+		resp, err := NewRequest().Headers(map[string]string{"Range": tt.rangeHeader}).Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		ranges, err := resp.ByteRanges()
+		if err != nil {
+			t.Error(tt.rangeHeader, err)
+			continue
+		}
+
+		if len(ranges) != len(tt.expected) {
+			t.Error(
+				"For", tt.rangeHeader,
+				"expected", tt.expected,
+				"got", ranges,
+			)
+			continue
+		}
+
+		for i, br := range ranges {
+			exp := tt.expected[i]
+			if br.Start != exp.Start || br.End != exp.End || br.Total != exp.Total || string(br.Body) != string(exp.Body) {
+				t.Error(
+					"For", tt.rangeHeader,
+					"expected", exp,
+					"got", br,
+				)
+			}
+		}
+	}
+}
+
+// TestByteRangesNotSatisfiable tests ByteRanges for an unsatisfiable range
+func TestByteRangesNotSatisfiable(t *testing.T) {
+	t.Log("Sending unsatisfiable range request... (expected ErrRangeNotSatisfiable)")
+
+	ts := newRangeServer(t)
+
+	resp, err := NewRequest().Headers(map[string]string{"Range": "bytes=100-200"}).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := resp.ByteRanges(); !errors.Is(err, ErrRangeNotSatisfiable) {
+		t.Error(
+			"For", "bytes=100-200",
+			"expected", ErrRangeNotSatisfiable,
+			"got", err,
+		)
+	}
+}
+
+// TestContentRange tests ContentRange of partial and full responses
+func TestContentRange(t *testing.T) {
+	t.Log("Sending range requests... (expected Content-Range of 206 responses)")
+
+	ts := newRangeServer(t)
+
+	tests := []struct {
+		rangeHeader       string
+		start, end, total int64
+		ok                bool
+	}{
+		{"bytes=10-19", 10, 19, 36, true},
+		{"bytes=-6", 30, 35, 36, true},
+		{"", 0, 0, 0, false},
+		{"bytes=10-12,30-35", 0, 0, 0, false},
+		{"bytes=100-", 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		req := NewRequest()
+		if tt.rangeHeader != "" {
+			req.Headers(map[string]string{"Range": tt.rangeHeader})
+		}
+		resp, err := req.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		start, end, total, ok := resp.ContentRange()
+		if ok != tt.ok || start != tt.start || end != tt.end || total != tt.total {
+			t.Error(
+				"For", tt.rangeHeader,
+				"expected", tt.start, tt.end, tt.total, tt.ok,
+				"got", start, end, total, ok,
+			)
+		}
+	}
+}
+
+// TestParseContentRange tests Content-Range parsing
+func TestParseContentRange(t *testing.T) {
+	tests := []struct {
+		val               string
+		start, end, total int64
+		ok                bool
+	}{
+		{"bytes 0-99/1000", 0, 99, 1000, true},
+		{"bytes 5-9/*", 5, 9, -1, true},
+		{"bytes */1000", 0, 0, 0, false},
+		{"bytes 9-5/1000", 0, 0, 0, false},
+		{"bytes 0-1000/1000", 0, 0, 0, false},
+		{"items 0-9/10", 0, 0, 0, false},
+	}
+
+	for _, tt := range tests {
+		start, end, total, err := parseContentRange(tt.val)
+		if (err == nil) != tt.ok || start != tt.start || end != tt.end || total != tt.total {
+			t.Error(
+				"For", tt.val,
+				"expected", tt.start, tt.end, tt.total, tt.ok,
+				"got", start, end, total, err,
+			)
+		}
+	}
+}
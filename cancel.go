@@ -0,0 +1,54 @@
+package gohttp
+
+import (
+	"context"
+	"errors"
+)
+
+// CanceledError is returned when the context of a request was canceled with
+// a cause, e.g. with context.WithCancelCause. Cause is the cause and Err
+// the original error. It matches both context.Canceled and the cause.
+type CanceledError struct {
+	Cause error
+	Err   error
+}
+
+func (e *CanceledError) Error() string {
+	return "gohttp: request cancelled: " + e.Cause.Error()
+}
+
+// Is reports whether target is context.Canceled or the cause
+func (e *CanceledError) Is(target error) bool {
+	return target == context.Canceled || errors.Is(e.Cause, target)
+}
+
+func (e *CanceledError) Unwrap() error {
+	return e.Err
+}
+
+// CancelCause returns why the request which failed with err was canceled,
+// nil when it wasn't canceled with a cause
+func CancelCause(err error) error {
+	var canceled *CanceledError
+	if errors.As(err, &canceled) {
+		return canceled.Cause
+	}
+	return nil
+}
+
+// withCancelCause wraps err in a *CanceledError when it is caused by ctx
+// being canceled with a cause. Depending on the Go version the transport
+// returns either context.Canceled or the cause.
+func withCancelCause(ctx context.Context, err error) error {
+	if err == nil || !errors.Is(ctx.Err(), context.Canceled) {
+		return err
+	}
+	cause := contextCause(ctx)
+	if cause == nil || cause == context.Canceled {
+		return err
+	}
+	if !errors.Is(err, context.Canceled) && !errors.Is(err, cause) {
+		return err
+	}
+	return &CanceledError{Cause: cause, Err: err}
+}
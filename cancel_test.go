@@ -0,0 +1,80 @@
+//go:build go1.20
+// +build go1.20
+
+package gohttp
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestCancelCause tests the cause of a cancellation is returned by sends,
+// retries and batches
+func TestCancelCause(t *testing.T) {
+	t.Log("Canceling requests with a cause... (expected cause in the errors)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/busy" {
+			w.Header().Set("Retry-After", "60")
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		<-r.Context().Done()
+	}))
+	defer ts.Close()
+
+	shutdown := errors.New("client shutting down")
+	cancelSoon := func() context.Context {
+		ctx, cancel := context.WithCancelCause(context.Background())
+		time.AfterFunc(50*time.Millisecond, func() { cancel(shutdown) })
+		return ctx
+	}
+	canceled, cancel := context.WithCancelCause(context.Background())
+	cancel(shutdown)
+
+	send := func(ctx context.Context, path string, opts ...Option) error {
+		_, err := NewRequest(opts...).SetContext(ctx).Get(ts.URL + path)
+		return err
+	}
+	batch := func(ctx context.Context) error {
+		results := NewBatchRequest(NewRequest()).
+			Add(http.MethodGet, ts.URL).
+			Execute(ctx)
+		return results[0].Err
+	}
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"send", send(cancelSoon(), "/")},
+		{"retry wait", send(cancelSoon(), "/busy", WithRetry(1, 0), WithMaxRetryAfter(0))},
+		{"batch", batch(canceled)},
+	}
+
+	for _, tt := range tests {
+		if CancelCause(tt.err) != shutdown || !errors.Is(tt.err, shutdown) || !errors.Is(tt.err, context.Canceled) ||
+			!strings.Contains(tt.err.Error(), "request cancelled: client shutting down") {
+			t.Error(
+				"For", tt.name,
+				"expected", shutdown,
+				"got", tt.err,
+			)
+		}
+	}
+
+	plain, cancelPlain := context.WithCancel(context.Background())
+	cancelPlain()
+	if err := send(plain, "/"); err == nil || CancelCause(err) != nil {
+		t.Error(
+			"For", "cancel without cause",
+			"expected", "no cause",
+			"got", err,
+		)
+	}
+}
@@ -0,0 +1,99 @@
+package gohttp
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestCanonicalJSON tests the examples of RFC 8785
+func TestCanonicalJSON(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		// section 3.2.2
+		{`{
+			"numbers": [333333333.33333329, 1E30, 4.50, 2e-3, 0.000000000000000000000000001],
+			"string": "\u20ac$\u000F\u000aA'\u0042\u0022\u005c\\\"\/",
+			"literals": [null, true, false]
+		}`, `{"literals":[null,true,false],"numbers":[333333333.3333333,1e+30,4.5,0.002,1e-27],"string":"€$\u000f\nA'B\"\\\\\"/"}`},
+		// section 3.2.3
+		{`{
+			"\u20ac": "Euro Sign",
+			"\r": "Carriage Return",
+			"\ufb33": "Hebrew Letter Dalet With Dagesh",
+			"1": "One",
+			"\ud83d\ude00": "Emoji: Grinning Face",
+			"\u0080": "Control",
+			"\u00f6": "Latin Small Letter O With Diaeresis"
+		}`, "{\"\\r\":\"Carriage Return\",\"1\":\"One\",\"\u0080\":\"Control\",\"ö\":\"Latin Small Letter O With Diaeresis\"," +
+			"\"€\":\"Euro Sign\",\"😀\":\"Emoji: Grinning Face\",\"\ufb33\":\"Hebrew Letter Dalet With Dagesh\"}"},
+		// appendix B
+		{`[0, -0, 1e-7, 1e21, 9007199254740992, 295147905179352830000, 0.000001, -1.5e-7]`,
+			`[0,0,1e-7,1e+21,9007199254740992,295147905179352830000,0.000001,-1.5e-7]`},
+	}
+
+	for _, tt := range tests {
+		got, err := canonicalJSON(json.RawMessage(tt.input))
+		if err != nil || string(got) != tt.expected {
+			t.Error(
+				"For", tt.input,
+				"expected", tt.expected,
+				"got", string(got), err,
+			)
+		}
+	}
+}
+
+// TestJSONCanonical tests JSONCanonical body with a signing hook
+func TestJSONCanonical(t *testing.T) {
+	t.Log("Sending POST request with canonical JSON... (expected signature over sent bytes)")
+
+	key := []byte("secret")
+	sign := func(body []byte) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write(body)
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+
+	var signature string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("Content-Type") != "application/json" || sign(body) != signature {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write(body)
+	}))
+	defer ts.Close()
+
+	req := NewRequest().JSONCanonical(map[string]interface{}{
+		"z": 1.0,
+		"a": []interface{}{"x", 2.50},
+		"m": map[string]interface{}{"b": true, "a": nil},
+	})
+	req.OnBeforeRequest(func(r *Request) error {
+		signature = sign(r.BodyBytes())
+		return nil
+	})
+
+	resp, err := req.Post(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `{"a":["x",2.5],"m":{"a":null,"b":true},"z":1}`
+	if body, _ := resp.GetBodyAsString(); resp.GetStatusCode() != 200 || body != expected {
+		t.Error(
+			"For", "JSONCanonical",
+			"expected", expected,
+			"got", resp.GetStatusCode(), body,
+		)
+	}
+}
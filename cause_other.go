@@ -0,0 +1,11 @@
+//go:build !go1.20
+// +build !go1.20
+
+package gohttp
+
+import "context"
+
+// contextCause returns nil, causes need Go 1.20
+func contextCause(ctx context.Context) error {
+	return nil
+}
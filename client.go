@@ -0,0 +1,150 @@
+package gohttp
+
+import (
+	"net/http"
+	"sync/atomic"
+	"time"
+)
+
+// Client sends requests sharing one http.Client and its connection pool,
+// along with the defaults given to NewClient, e.g. WithBaseURL, WithHeaders
+// or SetTimeout. It is safe for concurrent use. Its configuration is frozen
+// once a request created with R was sent, registering a hook afterwards
+// derives a new client, see With.
+type Client struct {
+	base *Request
+	sent int32
+}
+
+// ClientConfigView is a read-only view of a Client configuration, meant for
+// debugging and tests
+type ClientConfigView struct {
+	BaseURL            string
+	Headers            map[string]string
+	Timeout            time.Duration
+	HasTransport       bool
+	HasCookieJar       bool
+	BeforeRequestHooks int
+	AfterResponseHooks int
+	ErrorHooks         int
+	Frozen             bool
+}
+
+// NewClient returns a client configured with opts. The http client and its
+// transport are built right away, the ClientCreated hooks registered
+// later are not executed.
+func NewClient(opts ...Option) *Client {
+	base := NewRequest(opts...)
+	base.createClient()
+
+	return &Client{base: base}
+}
+
+// R returns a new request bound to the client. It starts with the client
+// defaults and hooks, configuring it never changes the client. Options
+// building the http client, like SetTimeout, WithProxy or
+// WithRedirectPolicy, are only taken from NewClient.
+func (c *Client) R(opts ...Option) *Request {
+	r := c.base.clone()
+	r.client = c.HTTPClient()
+	r.clientSent = &c.sent
+	for _, o := range opts {
+		o.apply(r)
+	}
+	return r
+}
+
+// With returns a client derived from c with opts applied. The derived client
+// shares the http client of c, and so its connections, while its defaults
+// and hooks are copies, so configuring it never changes c. Options building
+// the http client, like SetTimeout or WithMiddleware, have no effect on it.
+func (c *Client) With(opts ...Option) *Client {
+	base := c.base.clone()
+	base.client = c.HTTPClient()
+	for _, o := range opts {
+		o.apply(base)
+	}
+	return &Client{base: base}
+}
+
+// Config returns a snapshot of the client configuration
+func (c *Client) Config() ClientConfigView {
+	var baseURL string
+	if c.base.baseURL != nil {
+		baseURL = c.base.baseURL.String()
+	}
+	headers := make(map[string]string, len(c.base.defaultHeaders))
+	for key, val := range c.base.defaultHeaders {
+		headers[key] = val
+	}
+
+	hooks := c.base.hooks()
+	return ClientConfigView{
+		BaseURL:            baseURL,
+		Headers:            headers,
+		Timeout:            c.base.timeout,
+		HasTransport:       c.base.transport != nil || c.base.roundTripper != nil,
+		HasCookieJar:       c.base.cookie != nil,
+		BeforeRequestHooks: len(hooks.beforeRequestHooks),
+		AfterResponseHooks: len(hooks.afterResponseHooks),
+		ErrorHooks:         len(hooks.errorHooks),
+		Frozen:             atomic.LoadInt32(&c.sent) == 1,
+	}
+}
+
+// configure applies fn to the defaults of c, or of a client derived from c
+// once c is frozen, and returns the configured client
+func (c *Client) configure(fn func(base *Request)) *Client {
+	if atomic.LoadInt32(&c.sent) == 1 {
+		c = c.With()
+	}
+	fn(c.base)
+	return c
+}
+
+// HTTPClient returns the http client shared by the requests of c
+func (c *Client) HTTPClient() *http.Client {
+	c.base.state.mu.Lock()
+	defer c.base.state.mu.Unlock()
+
+	return c.base.client
+}
+
+// OnBeforeRequest registers a hook executed before every request created
+// with R afterwards. Once c is frozen, c is left unchanged and the hook is
+// registered on a derived client instead, which is returned.
+func (c *Client) OnBeforeRequest(hook BeforeRequestHook) *Client {
+	return c.configure(func(base *Request) {
+		base.OnBeforeRequest(hook)
+	})
+}
+
+// OnAfterResponse registers a hook executed after every response to a
+// request created with R afterwards. Once c is frozen, c is left unchanged
+// and the hook is registered on a derived client instead, which is
+// returned.
+func (c *Client) OnAfterResponse(hook AfterResponseHook) *Client {
+	return c.configure(func(base *Request) {
+		base.OnAfterResponse(hook)
+	})
+}
+
+// OnError registers a hook executed on errors of every request created
+// with R afterwards. Once c is frozen, c is left unchanged and the hook is
+// registered on a derived client instead, which is returned.
+func (c *Client) OnError(hook ErrorHook) *Client {
+	return c.configure(func(base *Request) {
+		base.OnError(hook)
+	})
+}
+
+// Scoped calls fn with a client derived from c with opts applied, e.g. to
+// send a few requests with an extra header. The derived client shares the
+// http client of c, and so its connections, like the requests of R, while
+// its defaults and hooks are copies, so nothing configured in the scope
+// changes c, even when fn panics. The panic is not recovered. Options
+// building the http client, like SetTimeout or WithMiddleware, have no
+// effect in the scope.
+func (c *Client) Scoped(fn func(c *Client) error, opts ...Option) error {
+	return fn(c.With(opts...))
+}
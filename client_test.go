@@ -0,0 +1,273 @@
+package gohttp
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"testing"
+)
+
+// TestClient tests requests of a client share defaults and connections
+func TestClient(t *testing.T) {
+	t.Log("Sending requests with a client... (expected defaults, one connection, no leaked state)")
+
+	var conns int32
+	var got []http.Header
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		h := r.Header.Clone()
+		h.Set("Path", r.URL.Path)
+		got = append(got, h)
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	var hookCalls int
+	c := NewClient(WithBaseURL(ts.URL+"/api"), WithHeaders(map[string]string{"X-App": "demo", "X-Env": "test"}))
+	c.OnBeforeRequest(func(r *Request) error {
+		hookCalls++
+		return nil
+	})
+
+	r := c.R().Headers(map[string]string{"X-Env": "staging"})
+	r.OnBeforeRequest(func(r *Request) error {
+		hookCalls += 10
+		return nil
+	})
+	if _, err := r.Get("/users"); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := c.R().Get("/orders"); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		key      string
+		expected []string
+	}{
+		{"Path", []string{"/api/users", "/api/orders"}},
+		{"X-App", []string{"demo", "demo"}},
+		{"X-Env", []string{"staging", "test"}},
+	}
+	for _, tt := range tests {
+		for i, expected := range tt.expected {
+			if got[i].Get(tt.key) != expected {
+				t.Error(
+					"For", tt.key, i,
+					"expected", expected,
+					"got", got[i].Get(tt.key),
+				)
+			}
+		}
+	}
+
+	if conns != 1 || hookCalls != 12 {
+		t.Error(
+			"For", "connections and hook calls",
+			"expected", 1, 12,
+			"got", conns, hookCalls,
+		)
+	}
+
+	if c.R().createClient() != c.HTTPClient() {
+		t.Error(
+			"For", "R",
+			"expected", "shared http client",
+			"got", "new client",
+		)
+	}
+}
+
+// TestClientScoped tests the options and hooks of a scope don't leak to its
+// client, even when the scope panics or runs concurrently with others
+func TestClientScoped(t *testing.T) {
+	t.Log("Sending requests in scopes... (expected scoped headers only in their scope)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Scope") + "|" + r.Header.Get("X-Hook") + "|" + r.URL.RawQuery))
+	}))
+	defer ts.Close()
+
+	client := NewClient(WithBaseURL(ts.URL))
+	scope := func(name string) Option {
+		return OptionFunc(func(r *Request) {
+			WithHeaders(map[string]string{"X-Scope": name})(r)
+			r.AddQuery("scope", name)
+		})
+	}
+	get := func(c *Client) string {
+		resp, err := c.R().Get("/")
+		if err != nil {
+			return err.Error()
+		}
+		body, _ := resp.GetBodyAsString()
+		return body
+	}
+
+	err := client.Scoped(func(c *Client) error {
+		c.OnBeforeRequest(func(r *Request) error {
+			r.Headers(map[string]string{"X-Hook": "yes"})
+			return nil
+		})
+		for i := 0; i < 3; i++ {
+			if got := get(c); got != "a|yes|scope=a" {
+				t.Error(
+					"For", "scope",
+					"expected", "a|yes|scope=a",
+					"got", got,
+				)
+			}
+		}
+		return errors.New("done")
+	}, scope("a"))
+	if err == nil || err.Error() != "done" {
+		t.Error(
+			"For", "scope error",
+			"expected", "done",
+			"got", err,
+		)
+	}
+
+	func() {
+		defer func() {
+			if r := recover(); r != "boom" {
+				t.Error(
+					"For", "panic",
+					"expected", "boom",
+					"got", r,
+				)
+			}
+		}()
+		client.Scoped(func(c *Client) error {
+			c.OnBeforeRequest(func(r *Request) error {
+				r.Headers(map[string]string{"X-Hook": "leak"})
+				return nil
+			})
+			panic("boom")
+		}, scope("b"))
+	}()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 10; i++ {
+		wg.Add(1)
+		go func(name string) {
+			defer wg.Done()
+			client.Scoped(func(c *Client) error {
+				expected := name + "||scope=" + name
+				if got := get(c); got != expected {
+					t.Error(
+						"For", "concurrent scope",
+						"expected", expected,
+						"got", got,
+					)
+				}
+				return nil
+			}, scope(name))
+		}(strconv.Itoa(i))
+	}
+	wg.Wait()
+
+	if got := get(client); got != "||" {
+		t.Error(
+			"For", "client",
+			"expected", "||",
+			"got", got,
+		)
+	}
+}
+
+// TestClientFrozenConfig tests hooks registered on a client once it was used
+// derive a new client, even while its requests are in flight
+func TestClientFrozenConfig(t *testing.T) {
+	t.Log("Registering hooks on a used client... (expected a derived client, no race)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	var calls int32
+	c := NewClient(WithBaseURL(ts.URL), WithHeaders(map[string]string{"X-App": "demo"}))
+	if got := c.OnBeforeRequest(func(*Request) error {
+		atomic.AddInt32(&calls, 1)
+		return nil
+	}); got != c || c.Config().Frozen {
+		t.Error(
+			"For", "OnBeforeRequest before use",
+			"expected", "the client itself, not frozen",
+			"got", got.Config(),
+		)
+	}
+
+	var wg sync.WaitGroup
+	derived := make(chan *Client, 20)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			derived <- c.OnBeforeRequest(func(*Request) error {
+				atomic.AddInt32(&calls, 100)
+				return nil
+			})
+		}
+		close(derived)
+	}()
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if _, err := c.R().Get("/"); err != nil {
+				t.Error(err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	applied := 0
+	for d := range derived {
+		if d == c {
+			applied++
+		} else if cfg := d.Config(); cfg.Frozen || cfg.BeforeRequestHooks < 2 {
+			t.Error(
+				"For", "derived client",
+				"expected", "not frozen with the new hook",
+				"got", cfg,
+			)
+		}
+	}
+
+	cfg := c.Config()
+	if !cfg.Frozen || cfg.BeforeRequestHooks != 1+applied || cfg.BaseURL != ts.URL || cfg.Headers["X-App"] != "demo" {
+		t.Error(
+			"For", "Config",
+			"expected", "frozen client with", 1+applied, "hooks",
+			"got", cfg,
+		)
+	}
+
+	atomic.StoreInt32(&calls, 0)
+	d := c.OnError(func(*Request, error) {})
+	if _, err := c.R().Get("/"); err != nil {
+		t.Fatal(err)
+	}
+	if d == c || c.Config().ErrorHooks != 0 || d.Config().ErrorHooks != 1 || atomic.LoadInt32(&calls) != int32(1+100*applied) {
+		t.Error(
+			"For", "OnError after use",
+			"expected", "a derived client, the client unchanged",
+			"got", c.Config(), d.Config(),
+		)
+	}
+	if d.HTTPClient() != c.HTTPClient() {
+		t.Error(
+			"For", "derived client",
+			"expected", "shared http client",
+			"got", "new client",
+		)
+	}
+}
@@ -0,0 +1,278 @@
+package gohttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnsupportedEncoding is matched by the errors of requests asking for,
+// or responses compressed with, an encoding WithCompression can't decode,
+// and of requests compressed with an encoding which is not registered
+var ErrUnsupportedEncoding = errors.New("gohttp: unsupported content encoding")
+
+// Encoding is a content encoding, see RegisterEncoding
+type Encoding struct {
+	// NewWriter returns a writer compressing to w, it is closed once the
+	// whole content is written
+	NewWriter func(w io.Writer) (io.WriteCloser, error)
+	// NewReader returns a reader decompressing r
+	NewReader func(r io.Reader) (io.ReadCloser, error)
+}
+
+// gzipEncoding is the gzip encoding, always registered
+var gzipEncoding = Encoding{
+	NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return gzip.NewWriter(w), nil
+	},
+	NewReader: func(r io.Reader) (io.ReadCloser, error) {
+		zr, err := gzip.NewReader(r)
+		if err != nil {
+			return nil, err
+		}
+		return zr, nil
+	},
+}
+
+var encodings = struct {
+	sync.RWMutex
+	m map[string]Encoding
+}{m: map[string]Encoding{"gzip": gzipEncoding}}
+
+// RegisterEncoding registers enc as the content encoding name for
+// WithCompression and WithRequestCompression, replacing a registered one
+// with the same name. Importing github.com/tenminschool/gohttp/zstd
+// registers zstd, gzip is always registered.
+func RegisterEncoding(name string, enc Encoding) {
+	encodings.Lock()
+	defer encodings.Unlock()
+
+	encodings.m[strings.ToLower(name)] = enc
+}
+
+// lookupEncoding returns the registered encoding name, case insensitively
+func lookupEncoding(name string) (Encoding, bool) {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "x-gzip" {
+		name = "gzip"
+	}
+
+	encodings.RLock()
+	defer encodings.RUnlock()
+
+	enc, ok := encodings.m[name]
+	return enc, ok
+}
+
+// acceptEncoding returns the Accept-Encoding header of WithCompression,
+// gzip followed by the other registered encodings
+func acceptEncoding() string {
+	encodings.RLock()
+	names := make([]string, 0, len(encodings.m))
+	for name := range encodings.m {
+		if name != "gzip" {
+			names = append(names, name)
+		}
+	}
+	encodings.RUnlock()
+
+	sort.Strings(names)
+	return strings.Join(append([]string{"gzip"}, names...), ", ")
+}
+
+// WithCompression option asks for compressed responses and decompresses
+// them, so the response body methods see the decompressed data. Responses
+// which are not compressed are returned as they are. Unlike the transparent
+// compression of http.Transport it also works when Accept-Encoding is set
+// with Headers. Without it the registered encodings are accepted, gzip
+// unless another is registered with RegisterEncoding.
+//
+// Requests whose Accept-Encoding asks for another encoding, e.g. br or
+// zstd, fail before being sent and responses compressed with one fail, both
+// with ErrUnsupportedEncoding, rather than returning compressed bytes.
+func WithCompression() OptionFunc {
+	return WithMiddleware(decompress)
+}
+
+// WithRequestCompression option compresses the request body with the
+// registered encoding, e.g. "gzip" like CompressBody or "zstd" once
+// github.com/tenminschool/gohttp/zstd is imported. An encoding which is
+// not registered fails the request with ErrUnsupportedEncoding. Streamed
+// bodies are compressed while they are sent, without Content-Length.
+func WithRequestCompression(encoding string) OptionFunc {
+	return func(r *Request) {
+		r.compressBody = strings.ToLower(encoding)
+	}
+}
+
+// decompress is the middleware of WithCompression
+func decompress(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if accept := r.Header.Get("Accept-Encoding"); accept == "" {
+			r = r.Clone(r.Context())
+			r.Header.Set("Accept-Encoding", acceptEncoding())
+		} else if enc := unsupportedEncoding(accept, true); enc != "" {
+			return nil, fmt.Errorf("%w: Accept-Encoding %q", ErrUnsupportedEncoding, enc)
+		}
+
+		resp, err := next.RoundTrip(r)
+		if err != nil {
+			return resp, err
+		}
+		encoding := resp.Header.Get("Content-Encoding")
+		if enc := unsupportedEncoding(encoding, false); enc != "" {
+			resp.Body.Close()
+			return nil, fmt.Errorf("%w: Content-Encoding %q", ErrUnsupportedEncoding, enc)
+		}
+		enc, ok := lookupEncoding(encoding)
+		if !ok {
+			return resp, nil
+		}
+
+		resp.Body = &decodingBody{body: resp.Body, newReader: enc.NewReader}
+		resp.Header.Del("Content-Encoding")
+		resp.Header.Del("Content-Length")
+		resp.ContentLength = -1
+		resp.Uncompressed = true
+		return resp, nil
+	})
+}
+
+// unsupportedEncoding returns the first encoding of the comma separated
+// list which can't be decoded, empty if there is none. An Accept-Encoding
+// list may have q values and ignores the refused encodings, while a
+// Content-Encoding list is decoded as a single encoding besides identity.
+func unsupportedEncoding(list string, accept bool) string {
+	var names []string
+	for _, enc := range strings.Split(list, ",") {
+		enc = strings.ToLower(strings.TrimSpace(enc))
+		if accept {
+			var params string
+			if i := strings.IndexByte(enc, ';'); i >= 0 {
+				enc, params = strings.TrimSpace(enc[:i]), strings.ReplaceAll(enc[i+1:], " ", "")
+			}
+			if params == "q=0" || strings.HasPrefix(params, "q=0.") && strings.Trim(params[4:], "0") == "" {
+				continue
+			}
+		}
+		if enc != "" && enc != "identity" && enc != "*" {
+			names = append(names, enc)
+		}
+	}
+
+	for _, name := range names {
+		if _, ok := lookupEncoding(name); !ok || !accept && len(names) > 1 {
+			return name
+		}
+	}
+	return ""
+}
+
+// decodingBody decompresses a response body, the decompressing reader is
+// created on the first Read so empty bodies, e.g. of HEAD requests, don't
+// fail on a missing header
+type decodingBody struct {
+	body      io.ReadCloser
+	newReader func(io.Reader) (io.ReadCloser, error)
+	r         io.ReadCloser
+	err       error
+}
+
+func (b *decodingBody) Read(p []byte) (int, error) {
+	if b.r == nil && b.err == nil {
+		b.r, b.err = b.newReader(b.body)
+	}
+	if b.err != nil {
+		return 0, b.err
+	}
+	return b.r.Read(p)
+}
+
+func (b *decodingBody) Close() error {
+	if b.r != nil {
+		b.r.Close()
+	}
+	return b.body.Close()
+}
+
+// encodeBuffer returns the content of buf compressed with the registered
+// encoding name, see WithRequestCompression
+func encodeBuffer(name string, buf *bytes.Buffer) (*bytes.Buffer, error) {
+	enc, ok := lookupEncoding(name)
+	if !ok {
+		return nil, fmt.Errorf("%w: %q", ErrUnsupportedEncoding, name)
+	}
+
+	var out bytes.Buffer
+	w, err := enc.NewWriter(&out)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(buf.Bytes()); err != nil {
+		w.Close()
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return &out, nil
+}
+
+// encodingWriterTo writes the body written by wt compressed with enc, for
+// the streamed bodies of WithRequestCompression
+type encodingWriterTo struct {
+	wt  io.WriterTo
+	enc Encoding
+}
+
+func (e *encodingWriterTo) WriteTo(w io.Writer) (int64, error) {
+	cw := &countingWriter{w: w}
+	zw, err := e.enc.NewWriter(cw)
+	if err != nil {
+		return 0, err
+	}
+	if _, err := e.wt.WriteTo(zw); err != nil {
+		zw.Close()
+		return cw.n, err
+	}
+	err = zw.Close()
+	return cw.n, err
+}
+
+// readerWriterTo writes a BodyReader body, opened again for every write
+type readerWriterTo struct {
+	body *readerBody
+}
+
+func (r readerWriterTo) WriteTo(w io.Writer) (int64, error) {
+	body, err := r.body.open()
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+	return io.Copy(w, body)
+}
+
+// encodeStream makes the streamed body of req, set with BodyWriterTo,
+// BodyReader or a multipart form, compressed with the registered encoding
+// name while it is sent
+func (req *Request) encodeStream(name string) error {
+	enc, ok := lookupEncoding(name)
+	if !ok {
+		return fmt.Errorf("%w: %q", ErrUnsupportedEncoding, name)
+	}
+
+	wt := req.bodyWriterTo
+	if req.bodyReader != nil {
+		wt = readerWriterTo{body: req.bodyReader}
+	}
+	req.bodyWriterTo = &encodingWriterTo{wt: wt, enc: enc}
+	req.bodyReader = nil
+	return nil
+}
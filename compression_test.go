@@ -0,0 +1,268 @@
+package gohttp
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestWithCompression tests gzip responses are decompressed and plain ones
+// are kept
+func TestWithCompression(t *testing.T) {
+	t.Log("Sending requests with compression... (expected decompressed bodies)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		if r.URL.Query().Get("plain") != "" || r.Header.Get("Accept-Encoding") != "gzip" {
+			w.Write([]byte(`{"encoding":"identity"}`))
+			return
+		}
+		w.Header().Set("Content-Encoding", "gzip")
+		if r.Method == http.MethodHead {
+			return
+		}
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(`{"encoding":"gzip"}`))
+		zw.Close()
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		req      *Request
+		url      string
+		expected string
+	}{
+		{"gzip", NewRequest(WithCompression()), ts.URL, "gzip"},
+		{"header set", NewRequest(WithCompression()).Headers(map[string]string{"Accept-Encoding": "gzip"}), ts.URL, "gzip"},
+		{"plain", NewRequest(WithCompression()), ts.URL + "?plain=1", "identity"},
+	}
+
+	for _, tt := range tests {
+		resp, err := tt.req.Get(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var body struct{ Encoding string }
+		if err := resp.JSON(&body); err != nil || body.Encoding != tt.expected || resp.GetResp().Header.Get("Content-Encoding") != "" {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", body.Encoding, err,
+			)
+		}
+	}
+
+	resp, err := NewRequest(WithCompression()).Head(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, err := resp.GetBodyAsString(); err != nil || body != "" {
+		t.Error(
+			"For", "HEAD",
+			"expected", "empty body",
+			"got", body, err,
+		)
+	}
+}
+
+// TestCompressBody tests request bodies are sent gzip compressed with their
+// compressed length
+func TestCompressBody(t *testing.T) {
+	t.Log("Sending requests with compressed body... (expected gzip bodies, except without body)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		compressed, _ := ioutil.ReadAll(r.Body)
+		var body io.Reader = bytes.NewReader(compressed)
+		if r.Header.Get("Content-Encoding") == "gzip" {
+			zr, err := gzip.NewReader(body)
+			if err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+			body = zr
+		}
+		data, _ := ioutil.ReadAll(body)
+		fmt.Fprintf(w, "%q %t %s %s", r.Header.Get("Content-Encoding"), r.ContentLength == int64(len(compressed)), data, r.URL.RawQuery)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		send     func() (*Response, error)
+		expected string
+	}{
+		{
+			"JSON",
+			func() (*Response, error) {
+				return NewRequest().JSON(map[string]interface{}{"name": "gohttp"}).CompressBody().Post(ts.URL)
+			},
+			`"gzip" true {"name":"gohttp"} `,
+		},
+		{
+			"Text",
+			func() (*Response, error) { return NewRequest().CompressBody().Text("hello").Put(ts.URL) },
+			`"gzip" true hello `,
+		},
+		{
+			"BodyReader",
+			func() (*Response, error) {
+				return NewRequest().BodyReader(strings.NewReader("streamed"), 8).CompressBody().Post(ts.URL)
+			},
+			`"gzip" false streamed `,
+		},
+		{
+			"BodyWriterTo",
+			func() (*Response, error) {
+				return NewRequest().CompressBody().BodyWriterTo(lines{"first", "second"}, "text/plain").Post(ts.URL)
+			},
+			"\"gzip\" false first\nsecond\n ",
+		},
+		{
+			"multipart",
+			func() (*Response, error) {
+				return NewRequest().MultipartBoundary("b").MultipartField("a", "1", "").CompressBody().Post(ts.URL)
+			},
+			"\"gzip\" false --b\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\n1\r\n--b--\r\n ",
+		},
+		{
+			"GET FormData",
+			func() (*Response, error) {
+				return NewRequest().CompressBody().FormData(map[string]string{"q": "go"}).Get(ts.URL)
+			},
+			`"" true  q=go`,
+		},
+		{
+			"empty",
+			func() (*Response, error) { return NewRequest().CompressBody().Post(ts.URL) },
+			`"" true  `,
+		},
+	}
+
+	for _, tt := range tests {
+		resp, err := tt.send()
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if got, _ := resp.GetBodyAsString(); got != tt.expected {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", got,
+			)
+		}
+	}
+}
+
+// TestWithRequestCompression tests request bodies are compressed with the
+// registered encodings, and an unregistered one fails the request
+func TestWithRequestCompression(t *testing.T) {
+	t.Log("Sending requests compressed with registered encodings... (expected encoded bodies or ErrUnsupportedEncoding)")
+
+	RegisterEncoding("base64", Encoding{
+		NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+			return base64.NewEncoder(base64.StdEncoding, w), nil
+		},
+		NewReader: func(r io.Reader) (io.ReadCloser, error) {
+			return ioutil.NopCloser(base64.NewDecoder(base64.StdEncoding, r)), nil
+		},
+	})
+	defer func() {
+		encodings.Lock()
+		delete(encodings.m, "base64")
+		encodings.Unlock()
+	}()
+
+	var sent int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		body, _ := ioutil.ReadAll(r.Body)
+		if r.Header.Get("Content-Encoding") == "base64" && strings.Contains(r.Header.Get("Accept-Encoding"), "base64") {
+			w.Header().Set("Content-Encoding", "base64")
+		}
+		fmt.Fprintf(w, "%s", body)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		encoding string
+		expected string
+		err      error
+	}{
+		{"base64", "hello", nil},
+		{"gzip", "\x1f\x8b", nil},
+		{"zstd", "", ErrUnsupportedEncoding},
+	}
+
+	for _, tt := range tests {
+		sent = 0
+		resp, err := NewRequest(WithCompression(), WithRequestCompression(tt.encoding)).Text("hello").Post(ts.URL)
+		var body string
+		if err == nil {
+			body, err = resp.GetBodyAsString()
+		}
+		if !errors.Is(err, tt.err) || !strings.HasPrefix(body, tt.expected) || sent != 1 && tt.err == nil || sent != 0 && tt.err != nil {
+			t.Error(
+				"For", tt.encoding,
+				"expected", tt.expected, tt.err,
+				"got", body, err, sent,
+			)
+		}
+	}
+}
+
+// TestUnsupportedEncoding tests asking for or receiving an encoding which
+// can't be decoded fails instead of returning compressed bytes
+func TestUnsupportedEncoding(t *testing.T) {
+	t.Log("Sending requests with unsupported encodings... (expected ErrUnsupportedEncoding)")
+
+	var sent int
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent++
+		if enc := r.URL.Query().Get("encoding"); enc != "" {
+			w.Header().Set("Content-Encoding", enc)
+		}
+		w.Write([]byte("compressed"))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		accept   string
+		url      string
+		expected error
+		sent     int
+	}{
+		{"accept br", "br", ts.URL, ErrUnsupportedEncoding, 0},
+		{"accept gzip and zstd", "gzip, zstd;q=0.5", ts.URL, ErrUnsupportedEncoding, 0},
+		{"refused br", "gzip, br;q=0", ts.URL, nil, 1},
+		{"received br", "", ts.URL + "?encoding=br", ErrUnsupportedEncoding, 1},
+		{"received gzip twice", "", ts.URL + "?encoding=gzip,+gzip", ErrUnsupportedEncoding, 1},
+		{"received identity", "", ts.URL + "?encoding=identity", nil, 1},
+	}
+
+	for _, tt := range tests {
+		sent = 0
+		req := NewRequest(WithCompression())
+		if tt.accept != "" {
+			req.Headers(map[string]string{"Accept-Encoding": tt.accept})
+		}
+		_, err := req.Get(tt.url)
+		if !errors.Is(err, tt.expected) || sent != tt.sent {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected, tt.sent,
+				"got", err, sent,
+			)
+		}
+	}
+}
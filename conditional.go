@@ -0,0 +1,44 @@
+package gohttp
+
+import (
+	"net/http"
+	"strings"
+)
+
+// IfMatch method sets the If-Match header to etags, so the server only
+// applies a PUT or PATCH when the resource still has one of them. Unquoted
+// etags are quoted, "*" matches any current representation. A server
+// refusing the request answers 412, see Response.IsPreconditionFailed. An
+// If-Match header given with Headers still wins.
+func (req *Request) IfMatch(etags ...string) *Request {
+	quoted := make([]string, 0, len(etags))
+	for _, etag := range etags {
+		if etag == "*" {
+			req.ifMatch = "*"
+			return req
+		}
+		quoted = append(quoted, quoteETag(etag))
+	}
+	req.ifMatch = strings.Join(quoted, ", ")
+	return req
+}
+
+// quoteETag returns etag quoted as an entity tag unless it is already,
+// e.g. W/"xyz"
+func quoteETag(etag string) string {
+	if len(etag) > 1 && strings.HasSuffix(etag, `"`) && (strings.HasPrefix(etag, `"`) || strings.HasPrefix(etag, `W/"`)) {
+		return etag
+	}
+	return `"` + etag + `"`
+}
+
+// IsPreconditionFailed reports whether the server refused the request
+// because a precondition like If-Match didn't hold, i.e. the resource was
+// modified since its etag was read. It is false if Response is not
+// returned from a Request.
+func (res *Response) IsPreconditionFailed() bool {
+	if res.resp == nil {
+		return false
+	}
+	return res.resp.StatusCode == http.StatusPreconditionFailed
+}
@@ -0,0 +1,60 @@
+package gohttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestIfMatch tests the If-Match header and the detection of a failed
+// precondition
+func TestIfMatch(t *testing.T) {
+	t.Log("Sending PUT requests with If-Match... (expected 412 for a stale etag)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ifMatch := r.Header.Get("If-Match")
+		if ifMatch != "*" && !strings.Contains(ifMatch, `"v2"`) {
+			w.WriteHeader(http.StatusPreconditionFailed)
+		}
+		fmt.Fprint(w, ifMatch)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name   string
+		etags  []string
+		header string
+		failed bool
+	}{
+		{"current", []string{"v2"}, `"v2"`, false},
+		{"stale", []string{`"v1"`}, `"v1"`, true},
+		{"several", []string{`W/"v1"`, "v2"}, `W/"v1", "v2"`, false},
+		{"any", []string{"*"}, "*", false},
+	}
+
+	for _, tt := range tests {
+		resp, err := NewRequest().IfMatch(tt.etags...).Text("data").Put(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		header, _ := resp.GetBodyAsString()
+		if header != tt.header || resp.IsPreconditionFailed() != tt.failed {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.header, tt.failed,
+				"got", header, resp.IsPreconditionFailed(),
+			)
+		}
+	}
+
+	if (&Response{}).IsPreconditionFailed() {
+		t.Error(
+			"For", "Response without http response",
+			"expected", false,
+			"got", true,
+		)
+	}
+}
@@ -0,0 +1,139 @@
+package gohttp
+
+import (
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// hookSet is an immutable set of registered hooks. Registration never
+// modifies a published hookSet, it stores a new copy instead.
+type hookSet struct {
+	beforeRequestHooks     []BeforeRequestHook
+	beforeHTTPRequestHooks []BeforeHTTPRequestHook
+	afterResponseHooks     []AfterResponseHook
+	errorHooks             []ErrorHook
+	clientCreatedHooks     []ClientCreatedHook
+	connClosedHooks        []ConnClosedHook
+}
+
+// requestState holds the parts of a Request that may be touched while sends
+// are in flight, e.g. hooks registered while async requests are running.
+type requestState struct {
+	mu        sync.Mutex
+	hooks     atomic.Value // *hookSet
+	sent      int32
+	pool      poolCounters
+	transport http.RoundTripper // built by createClient
+}
+
+// executeBeforeRequest runs the before request hooks in order, stopping at
+// the first one returning an error
+func (h *hookSet) executeBeforeRequest(req *Request) error {
+	for _, beforeReqHook := range h.beforeRequestHooks {
+		if err := beforeReqHook(req); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeBeforeHTTPRequest runs the before http request hooks in order,
+// stopping at the first one returning an error
+func (h *hookSet) executeBeforeHTTPRequest(request *http.Request) error {
+	for _, beforeHTTPReqHook := range h.beforeHTTPRequestHooks {
+		if err := beforeHTTPReqHook(request); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeAfterResponse runs the after response hooks in order, stopping at
+// the first one returning an error
+func (h *hookSet) executeAfterResponse(req *Request, response *Response) error {
+	for _, afterResponseHook := range h.afterResponseHooks {
+		if err := afterResponseHook(req, response); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// executeOnError runs the error hooks and sends the error to the event
+// channel of req
+func (h *hookSet) executeOnError(req *Request, err error) {
+	req.emit(Event{Kind: EventError, Err: err})
+	for _, errorHook := range h.errorHooks {
+		errorHook(req, err)
+	}
+}
+
+func (h *hookSet) executeClientCreated(client *http.Client) {
+	for _, clientCreatedHook := range h.clientCreatedHooks {
+		clientCreatedHook(client)
+	}
+}
+
+func (h *hookSet) executeConnClosed(stats PoolStats) {
+	for _, connClosedHook := range h.connClosedHooks {
+		connClosedHook(stats)
+	}
+}
+
+// hooks returns the current hook snapshot
+func (req *Request) hooks() *hookSet {
+	return req.state.hooks.Load().(*hookSet)
+}
+
+// registerHook publishes a copy of the current hooks modified by fn, so sends
+// already in flight keep working on the snapshot they started with
+func (req *Request) registerHook(fn func(*hookSet)) {
+	req.state.mu.Lock()
+	defer req.state.mu.Unlock()
+
+	cur := req.hooks()
+	next := &hookSet{
+		beforeRequestHooks:     append([]BeforeRequestHook(nil), cur.beforeRequestHooks...),
+		beforeHTTPRequestHooks: append([]BeforeHTTPRequestHook(nil), cur.beforeHTTPRequestHooks...),
+		afterResponseHooks:     append([]AfterResponseHook(nil), cur.afterResponseHooks...),
+		errorHooks:             append([]ErrorHook(nil), cur.errorHooks...),
+		clientCreatedHooks:     append([]ClientCreatedHook(nil), cur.clientCreatedHooks...),
+		connClosedHooks:        append([]ConnClosedHook(nil), cur.connClosedHooks...),
+	}
+	fn(next)
+	req.state.hooks.Store(next)
+}
+
+// ConfigView is a read-only view of a Request configuration, meant for
+// debugging and tests
+type ConfigView struct {
+	Timeout            time.Duration
+	HasClient          bool
+	HasTransport       bool
+	HasCookieJar       bool
+	BeforeRequestHooks int
+	AfterResponseHooks int
+	ErrorHooks         int
+	Sent               bool
+}
+
+// Config returns a snapshot of the request configuration
+func (req *Request) Config() ConfigView {
+	req.state.mu.Lock()
+	hasClient := req.client != nil
+	req.state.mu.Unlock()
+
+	hooks := req.hooks()
+	return ConfigView{
+		Timeout:            req.timeout,
+		HasClient:          hasClient,
+		HasTransport:       req.transport != nil || req.roundTripper != nil,
+		HasCookieJar:       req.cookie != nil,
+		BeforeRequestHooks: len(hooks.beforeRequestHooks),
+		AfterResponseHooks: len(hooks.afterResponseHooks),
+		ErrorHooks:         len(hooks.errorHooks),
+		Sent:               atomic.LoadInt32(&req.state.sent) == 1,
+	}
+}
@@ -0,0 +1,103 @@
+package gohttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+// TestConcurrentHookRegistration tests registering hooks while requests are in flight
+func TestConcurrentHookRegistration(t *testing.T) {
+	t.Log("Registering hooks while sending requests... (expected no race)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	req := NewRequest()
+	ch := make(chan *AsyncResponse)
+
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < 20; i++ {
+			req.OnBeforeRequest(func(*Request) error { return nil })
+			req.OnAfterResponse(func(*Request, *Response) error { return nil })
+			req.OnError(func(*Request, error) {})
+		}
+	}()
+
+	for i := 0; i < 20; i++ {
+		req.AsyncGet(ts.URL, ch)
+	}
+
+	for i := 0; i < 20; i++ {
+		aRes := <-ch
+		if aRes.Err != nil {
+			t.Error(aRes.Err)
+			continue
+		}
+		aRes.Resp.GetBody().Close()
+	}
+	wg.Wait()
+
+	cfg := req.Config()
+	if cfg.BeforeRequestHooks != 20 || cfg.AfterResponseHooks != 20 || cfg.ErrorHooks != 20 {
+		t.Error(
+			"For", "Config",
+			"expected", "20 hooks of each kind",
+			"got", cfg,
+		)
+	}
+
+	if !cfg.Sent || !cfg.HasClient {
+		t.Error(
+			"For", "Config",
+			"expected", "sent request with client",
+			"got", cfg,
+		)
+	}
+}
+
+// TestHookSnapshot tests that a send keeps the hooks it started with
+func TestHookSnapshot(t *testing.T) {
+	t.Log("Registering a hook from a hook... (expected to apply on next send)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	calls := 0
+	req := NewRequest()
+	req.OnBeforeRequest(func(r *Request) error {
+		r.OnAfterResponse(func(*Request, *Response) error {
+			calls++
+			return nil
+		})
+		return nil
+	})
+
+	if _, err := req.Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 0 {
+		t.Error(
+			"For", "first send",
+			"expected", 0,
+			"got", calls,
+		)
+	}
+
+	if _, err := req.Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	if calls != 1 {
+		t.Error(
+			"For", "second send",
+			"expected", 1,
+			"got", calls,
+		)
+	}
+}
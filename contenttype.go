@@ -0,0 +1,182 @@
+package gohttp
+
+import (
+	"errors"
+	"fmt"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// ErrContentTypeConflict is returned with FailOnContentTypeConflict for
+// responses with conflicting Content-Type headers
+var ErrContentTypeConflict = errors.New("gohttp: conflicting Content-Type headers")
+
+// ErrContentTypeMismatch is matched by the *ContentTypeMismatchError of
+// WithContentSniffing
+var ErrContentTypeMismatch = errors.New("gohttp: response body doesn't match its Content-Type")
+
+// ContentTypeMismatchError is returned with WithContentSniffing when the
+// body of a response looks like another type than its Content-Type, e.g. a
+// captive portal answering with an HTML page. Snippet is the beginning of
+// the body.
+type ContentTypeMismatchError struct {
+	Declared string
+	Sniffed  string
+	Snippet  []byte
+}
+
+func (e *ContentTypeMismatchError) Error() string {
+	return fmt.Sprintf("%v: declared %q, sniffed %q: %q", ErrContentTypeMismatch, e.Declared, e.Sniffed, e.Snippet)
+}
+
+// Is reports whether target is ErrContentTypeMismatch
+func (e *ContentTypeMismatchError) Is(target error) bool {
+	return target == ErrContentTypeMismatch
+}
+
+// SniffException is a mismatch WithContentSniffing accepts, between the
+// declared media type of a response and the one sniffed from its body,
+// e.g. {"application/json", "text/html"} for an API documenting HTML
+// errors. "*" matches any media type.
+type SniffException struct {
+	Declared string
+	Sniffed  string
+}
+
+// contentTypePolicy decides the Content-Type of responses without one or
+// with several conflicting ones
+type contentTypePolicy struct {
+	fallback   string
+	strict     bool
+	sniff      bool
+	exceptions []SniffException
+	warnf      func(format string, v ...interface{})
+}
+
+// WithDefaultContentType option sets the Content-Type of responses without
+// one, e.g. for decoding them with Response.JSON. A diagnostic is logged
+// when it is used, see WithLogger.
+func WithDefaultContentType(ct string) OptionFunc {
+	return func(r *Request) {
+		r.contentTypePolicy.fallback = ct
+	}
+}
+
+// FailOnContentTypeConflict option makes Response.ContentType and the
+// decoding of responses fail with ErrContentTypeConflict when a response
+// has several different Content-Type headers. Without it the first one is
+// used and a diagnostic is logged.
+func FailOnContentTypeConflict() OptionFunc {
+	return func(r *Request) {
+		r.contentTypePolicy.strict = true
+	}
+}
+
+// WithContentSniffing option makes the decoding of responses, e.g. with
+// Response.JSON, fail with a *ContentTypeMismatchError when the type
+// http.DetectContentType sniffs from the body differs from the declared
+// Content-Type. Bodies sniffed as text/plain or application/octet-stream,
+// which the sniffer returns when it recognizes nothing, and XML sniffed for
+// an XML type are accepted, exceptions accepts more mismatches.
+func WithContentSniffing(exceptions ...SniffException) OptionFunc {
+	return func(r *Request) {
+		r.contentTypePolicy.sniff = true
+		r.contentTypePolicy.exceptions = append(r.contentTypePolicy.exceptions, exceptions...)
+	}
+}
+
+// ContentTypeRaw returns the values of every Content-Type header of the
+// response, in the order they were received
+func (res *Response) ContentTypeRaw() []string {
+	if res.resp == nil {
+		return nil
+	}
+	return res.resp.Header.Values("Content-Type")
+}
+
+// ContentType returns the Content-Type of the response. Without one it is
+// the default set with WithDefaultContentType, empty if there is none. With
+// several different ones it is the first, or ErrContentTypeConflict is
+// returned with FailOnContentTypeConflict.
+func (res *Response) ContentType() (string, error) {
+	raw := res.ContentTypeRaw()
+	policy := res.contentTypePolicy
+
+	if len(raw) == 0 {
+		if policy.fallback != "" {
+			res.warnf("gohttp: response of %s has no Content-Type, using %q", res.requestURL(), policy.fallback)
+		}
+		return policy.fallback, nil
+	}
+
+	for _, ct := range raw[1:] {
+		if strings.EqualFold(strings.TrimSpace(ct), strings.TrimSpace(raw[0])) {
+			continue
+		}
+		if policy.strict {
+			return "", fmt.Errorf("%w: %q", ErrContentTypeConflict, raw)
+		}
+		res.warnf("gohttp: response of %s has conflicting Content-Type headers %q, using %q", res.requestURL(), raw, raw[0])
+		break
+	}
+	return raw[0], nil
+}
+
+func (res *Response) warnf(format string, v ...interface{}) {
+	if res.contentTypePolicy.warnf != nil {
+		res.contentTypePolicy.warnf(format, v...)
+	}
+}
+
+// requestURL returns the URL of the request of the response for
+// diagnostics
+func (res *Response) requestURL() string {
+	if res.resp == nil || res.resp.Request == nil || res.resp.Request.URL == nil {
+		return "unknown URL"
+	}
+	return res.resp.Request.URL.String()
+}
+
+// sniffBody checks body against the declared Content-Type ct with
+// WithContentSniffing, before it is decoded
+func (res *Response) sniffBody(ct string, body []byte) error {
+	policy := res.contentTypePolicy
+	if !policy.sniff || ct == "" || len(body) == 0 {
+		return nil
+	}
+
+	declared, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return nil
+	}
+	sniffedType := http.DetectContentType(body)
+	sniffed, _, _ := mime.ParseMediaType(sniffedType)
+
+	switch {
+	case sniffed == declared, sniffed == "text/plain", sniffed == "application/octet-stream":
+		return nil
+	case sniffed == "text/xml" && (strings.HasSuffix(declared, "/xml") || strings.HasSuffix(declared, "+xml")):
+		return nil
+	}
+	for _, e := range policy.exceptions {
+		if (e.Declared == "*" || strings.EqualFold(e.Declared, declared)) && (e.Sniffed == "*" || strings.EqualFold(e.Sniffed, sniffed)) {
+			return nil
+		}
+	}
+
+	return &ContentTypeMismatchError{Declared: ct, Sniffed: sniffedType, Snippet: snippet(body)}
+}
+
+// sniffDecoded checks body with WithContentSniffing for the decoders which
+// don't check the Content-Type otherwise
+func (res *Response) sniffDecoded(body []byte) error {
+	if !res.contentTypePolicy.sniff {
+		return nil
+	}
+	ct, err := res.ContentType()
+	if err != nil {
+		return err
+	}
+	return res.sniffBody(ct, body)
+}
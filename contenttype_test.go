@@ -0,0 +1,157 @@
+package gohttp
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// contentTypeFixtures are raw responses with unusual Content-Type headers
+var contentTypeFixtures = map[string]string{
+	"none": "HTTP/1.1 200 OK\r\n" +
+		"Content-Length: 8\r\n" +
+		"\r\n" +
+		`{"a":1}` + "\n",
+	"duplicate": "HTTP/1.1 200 OK\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Content-Length: 8\r\n" +
+		"\r\n" +
+		`{"a":1}` + "\n",
+	"conflicting": "HTTP/1.1 200 OK\r\n" +
+		"Content-Type: application/json\r\n" +
+		"Content-Type: text/html\r\n" +
+		"Content-Length: 8\r\n" +
+		"\r\n" +
+		`{"a":1}` + "\n",
+	"captive portal": "HTTP/1.1 200 OK\r\n" +
+		"Content-Type: application/json\r\n" +
+		"\r\n" +
+		"<!DOCTYPE html><html><head><title>Hotel Wi-Fi</title></head><body>Accept the terms to continue</body></html>",
+	"html error": "HTTP/1.1 200 OK\r\n" +
+		"Content-Type: application/json\r\n" +
+		"\r\n" +
+		"<html><body>maintenance</body></html>",
+	"xml": "HTTP/1.1 200 OK\r\n" +
+		"Content-Type: application/atom+xml\r\n" +
+		"\r\n" +
+		`<?xml version="1.0"?><feed></feed>`,
+	"png": "HTTP/1.1 200 OK\r\n" +
+		"Content-Type: image/png\r\n" +
+		"\r\n" +
+		"\x89PNG\r\n\x1a\n\x00\x00\x00\rIHDR",
+}
+
+// sendFixture sends a request answered with the raw response of fixture
+func sendFixture(t *testing.T, fixture string, opts ...Option) *Response {
+	resp, err := http.ReadResponse(bufio.NewReader(strings.NewReader(contentTypeFixtures[fixture])), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	mock := NewMockTransport().Register("GET", "http://example.com/fixture", resp)
+
+	res, err := NewRequest(append(opts, WithTransport(mock))...).Get("http://example.com/fixture")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return res
+}
+
+// TestResponseContentType tests the Content-Type of responses without one
+// or with several
+func TestResponseContentType(t *testing.T) {
+	t.Log("Decoding responses with unusual Content-Type headers... (expected policy applied)")
+
+	tests := []struct {
+		fixture     string
+		opts        []Option
+		raw         []string
+		contentType string
+		err         error
+		diagnostics int
+	}{
+		{"none", nil, nil, "", nil, 0},
+		{"none", []Option{WithDefaultContentType("application/json")}, nil, "application/json", nil, 1},
+		{"none", []Option{WithDefaultContentType("text/plain")}, nil, "text/plain", ErrNotJSON, 1},
+		{"duplicate", []Option{FailOnContentTypeConflict()}, []string{"application/json", "application/json"}, "application/json", nil, 0},
+		{"conflicting", nil, []string{"application/json", "text/html"}, "application/json", nil, 1},
+		{"conflicting", []Option{FailOnContentTypeConflict()}, []string{"application/json", "text/html"}, "", ErrContentTypeConflict, 0},
+	}
+
+	for _, tt := range tests {
+		logger := &recordingLogger{}
+		res := sendFixture(t, tt.fixture, append(tt.opts, WithLogger(logger))...)
+
+		ct, ctErr := res.ContentType()
+		diagnostics := len(logger.msgs)
+		var v map[string]int
+		err := res.JSON(&v)
+
+		if fmt.Sprint(res.ContentTypeRaw()) != fmt.Sprint(tt.raw) || ct != tt.contentType ||
+			!errors.Is(err, tt.err) || (tt.err == nil && v["a"] != 1) ||
+			(ctErr != nil) != (tt.err == ErrContentTypeConflict) || diagnostics != tt.diagnostics {
+			t.Error(
+				"For", tt.fixture, len(tt.opts),
+				"expected", tt.raw, tt.contentType, tt.err, tt.diagnostics,
+				"got", res.ContentTypeRaw(), ct, err, logger.msgs,
+			)
+		}
+	}
+}
+
+// TestWithContentSniffing tests bodies which don't look like their
+// Content-Type are refused before they are decoded, unless the mismatch is
+// accepted
+func TestWithContentSniffing(t *testing.T) {
+	t.Log("Decoding responses with sniffing... (expected mismatch error for HTML claiming JSON)")
+
+	tests := []struct {
+		fixture string
+		opts    []Option
+		json    bool
+		err     error
+	}{
+		{"captive portal", []Option{WithContentSniffing()}, true, ErrContentTypeMismatch},
+		{"captive portal", nil, true, ErrNotJSON},
+		{"html error", []Option{WithContentSniffing(SniffException{Declared: "application/json", Sniffed: "text/html"})}, true, ErrNotJSON},
+		{"duplicate", []Option{WithContentSniffing()}, true, nil},
+		{"xml", []Option{WithContentSniffing()}, false, nil},
+		{"png", []Option{WithContentSniffing()}, false, nil},
+	}
+
+	for _, tt := range tests {
+		res := sendFixture(t, tt.fixture, tt.opts...)
+
+		var err error
+		if tt.json {
+			var v map[string]int
+			err = res.JSON(&v)
+		} else {
+			body, _ := res.GetBodyAsByte()
+			err = res.sniffDecoded(body)
+		}
+
+		if !errors.Is(err, tt.err) {
+			t.Error(
+				"For", tt.fixture, len(tt.opts),
+				"expected", tt.err,
+				"got", err,
+			)
+		}
+	}
+
+	res := sendFixture(t, "captive portal", WithContentSniffing())
+	var v interface{}
+	var mismatch *ContentTypeMismatchError
+	if err := res.UnmarshalBody(&v); !errors.As(err, &mismatch) || mismatch.Sniffed != "text/html; charset=utf-8" ||
+		!strings.Contains(err.Error(), "Hotel Wi-Fi") {
+		t.Error(
+			"For", "UnmarshalBody",
+			"expected", "mismatch with sniffed type and snippet",
+			"got", err,
+		)
+	}
+}
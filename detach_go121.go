@@ -0,0 +1,12 @@
+//go:build go1.21
+// +build go1.21
+
+package gohttp
+
+import "context"
+
+// withoutCancel returns a context with the values of ctx which is never
+// done, even when ctx is
+func withoutCancel(ctx context.Context) context.Context {
+	return context.WithoutCancel(ctx)
+}
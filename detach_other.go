@@ -0,0 +1,24 @@
+//go:build !go1.21
+// +build !go1.21
+
+package gohttp
+
+import (
+	"context"
+	"time"
+)
+
+// withoutCancel returns a context with the values of ctx which is never
+// done, even when ctx is, like context.WithoutCancel of Go 1.21
+func withoutCancel(ctx context.Context) context.Context {
+	return detachedContext{ctx}
+}
+
+type detachedContext struct {
+	parent context.Context
+}
+
+func (detachedContext) Deadline() (time.Time, bool)         { return time.Time{}, false }
+func (detachedContext) Done() <-chan struct{}               { return nil }
+func (detachedContext) Err() error                          { return nil }
+func (c detachedContext) Value(key interface{}) interface{} { return c.parent.Value(key) }
@@ -0,0 +1,378 @@
+package gohttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/http"
+	"net/textproto"
+	"net/url"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// missingValue stands for a value which is not present in a Difference
+const missingValue = "<missing>"
+
+// Difference is a difference between an expected and an actual request or
+// response. Path tells what differs, e.g. "method", "header X-Id",
+// "query page", "form name", "part file" or "body items.0.price" for JSON
+// bodies. A value which is not present is "<missing>".
+type Difference struct {
+	Path     string
+	Expected string
+	Actual   string
+}
+
+func (d Difference) String() string {
+	return fmt.Sprintf("%s expected %s got %s", d.Path, quoteValue(d.Expected), quoteValue(d.Actual))
+}
+
+func quoteValue(v string) string {
+	if v == missingValue {
+		return v
+	}
+	return strconv.Quote(v)
+}
+
+// Matcher reports whether an actual value is acceptable for an expected
+// one, see DiffOptions
+type Matcher func(expected, actual string) bool
+
+// AnyValue returns a matcher accepting any present value, e.g. for
+// generated request IDs
+func AnyValue() Matcher {
+	return func(expected, actual string) bool {
+		return actual != missingValue
+	}
+}
+
+// MatchRegexp returns a matcher accepting the values matching expr, e.g.
+// for dates. It panics if expr is not a valid regular expression.
+func MatchRegexp(expr string) Matcher {
+	re := regexp.MustCompile(expr)
+	return func(expected, actual string) bool {
+		return re.MatchString(actual)
+	}
+}
+
+// DiffOptions configures DiffRequests and DiffResponses. Only the headers
+// of the expected request or response are compared, except Date and
+// Content-Length, multipart boundaries are never compared.
+type DiffOptions struct {
+	// IgnoreHeaders are the headers which are not compared
+	IgnoreHeaders []string
+	// Matchers compare the values at a Difference path instead of
+	// requiring them to be equal, e.g. "header X-Request-Id" or
+	// "body items.0.id"
+	Matchers map[string]Matcher
+}
+
+// DiffRequests compares the method, URL, query, headers and body of actual
+// with expected. Bodies are compared by their Content-Type, as JSON values,
+// form values, multipart parts or as text. The bodies can still be read
+// afterwards, a body failing to be read is a difference of its own.
+func DiffRequests(expected, actual *http.Request, opts DiffOptions) []Difference {
+	d := newDiffer(opts)
+
+	d.compare("method", expected.Method, actual.Method)
+	expectedURL, actualURL := *expected.URL, *actual.URL
+	expectedURL.RawQuery, actualURL.RawQuery = "", ""
+	d.compare("url", expectedURL.String(), actualURL.String())
+	d.values("query", expected.URL.Query(), actual.URL.Query())
+	d.headers(expected.Header, actual.Header)
+	expectedBody, expectedErr := readRequestBody(expected)
+	actualBody, actualErr := readRequestBody(actual)
+	d.readBodies(expected.Header.Get("Content-Type"), actual.Header.Get("Content-Type"),
+		expectedBody, actualBody, expectedErr, actualErr)
+
+	return d.diffs
+}
+
+// DiffResponses compares the status, headers and body of actual with
+// expected, like DiffRequests
+func DiffResponses(expected, actual *http.Response, opts DiffOptions) []Difference {
+	d := newDiffer(opts)
+
+	d.compare("status", strconv.Itoa(expected.StatusCode), strconv.Itoa(actual.StatusCode))
+	d.headers(expected.Header, actual.Header)
+	expectedBody, expectedErr := readResponseBody(expected)
+	actualBody, actualErr := readResponseBody(actual)
+	d.readBodies(expected.Header.Get("Content-Type"), actual.Header.Get("Content-Type"),
+		expectedBody, actualBody, expectedErr, actualErr)
+
+	return d.diffs
+}
+
+// readRequestBody returns the body of r and replaces it with a copy
+func readRequestBody(r *http.Request) ([]byte, error) {
+	if r.GetBody != nil {
+		if body, err := r.GetBody(); err == nil {
+			defer body.Close()
+			return ioutil.ReadAll(body)
+		}
+	}
+	return readAndRestore(&r.Body)
+}
+
+// readResponseBody returns the body of r and replaces it with a copy
+func readResponseBody(r *http.Response) ([]byte, error) {
+	return readAndRestore(&r.Body)
+}
+
+// readAndRestore reads body and replaces it with a copy of what was read,
+// the read error is returned too
+func readAndRestore(body *io.ReadCloser) ([]byte, error) {
+	if *body == nil || *body == http.NoBody {
+		return nil, nil
+	}
+	data, err := ioutil.ReadAll(*body)
+	(*body).Close()
+	*body = ioutil.NopCloser(bytes.NewReader(data))
+	return data, err
+}
+
+// readBodies compares bodies read without error, a body which failed to
+// be read is reported as a difference rather than compared truncated
+func (d *differ) readBodies(expectedType, actualType string, expected, actual []byte, expectedErr, actualErr error) {
+	if expectedErr == nil && actualErr == nil {
+		d.body(expectedType, actualType, expected, actual)
+		return
+	}
+
+	expectedVal, actualVal := "<read>", "<read>"
+	if expectedErr != nil {
+		expectedVal = "<read error: " + expectedErr.Error() + ">"
+	}
+	if actualErr != nil {
+		actualVal = "<read error: " + actualErr.Error() + ">"
+	}
+	d.diffs = append(d.diffs, Difference{Path: "body", Expected: expectedVal, Actual: actualVal})
+}
+
+// differ collects the differences of a comparison
+type differ struct {
+	opts    DiffOptions
+	ignored map[string]bool
+	diffs   []Difference
+}
+
+func newDiffer(opts DiffOptions) *differ {
+	d := &differ{opts: opts, ignored: map[string]bool{"Date": true, "Content-Length": true}}
+	for _, key := range opts.IgnoreHeaders {
+		d.ignored[http.CanonicalHeaderKey(key)] = true
+	}
+	return d
+}
+
+// compare records a difference at path unless the values are equal or
+// accepted by its matcher
+func (d *differ) compare(path, expected, actual string) {
+	if expected == actual {
+		return
+	}
+	if match, ok := d.opts.Matchers[path]; ok && match(expected, actual) {
+		return
+	}
+	d.diffs = append(d.diffs, Difference{Path: path, Expected: expected, Actual: actual})
+}
+
+// values compares multi-valued maps key by key in sorted order
+func (d *differ) values(prefix string, expected, actual map[string][]string) {
+	for _, key := range unionKeys(expected, actual) {
+		d.compare(prefix+" "+key, joinValues(expected, key), joinValues(actual, key))
+	}
+}
+
+// headers compares the headers of expected with the ones of actual
+func (d *differ) headers(expected, actual http.Header) {
+	normalize := func(h http.Header) map[string][]string {
+		n := make(map[string][]string, len(h))
+		for key, vals := range h {
+			if _, ok := expected[key]; !ok || d.ignored[key] {
+				continue
+			}
+			if key == "Content-Type" {
+				vals = []string{withoutBoundary(h.Get(key))}
+			}
+			n[key] = vals
+		}
+		return n
+	}
+	d.values("header", normalize(expected), normalize(actual))
+}
+
+// withoutBoundary returns the media type ct without its boundary parameter
+func withoutBoundary(ct string) string {
+	mediaType, params, err := mime.ParseMediaType(ct)
+	if err != nil || params["boundary"] == "" {
+		return ct
+	}
+	delete(params, "boundary")
+	return mime.FormatMediaType(mediaType, params)
+}
+
+func (d *differ) body(expectedType, actualType string, expected, actual []byte) {
+	mediaType, _, _ := mime.ParseMediaType(expectedType)
+	switch {
+	case isJSONContentType(expectedType):
+		var e, a interface{}
+		if json.Unmarshal(expected, &e) == nil && json.Unmarshal(actual, &a) == nil {
+			d.json("body", e, a)
+			return
+		}
+	case mediaType == "application/x-www-form-urlencoded":
+		e, errE := url.ParseQuery(string(expected))
+		a, errA := url.ParseQuery(string(actual))
+		if errE == nil && errA == nil {
+			d.values("form", e, a)
+			return
+		}
+	case mediaType == "multipart/form-data":
+		e, errE := readParts(expectedType, expected)
+		a, errA := readParts(actualType, actual)
+		if errE == nil && errA == nil {
+			d.values("part", e, a)
+			return
+		}
+	}
+	d.compare("body", string(expected), string(actual))
+}
+
+// json compares decoded JSON values, objects and arrays member by member
+func (d *differ) json(path string, expected, actual interface{}) {
+	switch e := expected.(type) {
+	case map[string]interface{}:
+		if a, ok := actual.(map[string]interface{}); ok {
+			keys := make(map[string]bool, len(e)+len(a))
+			for key := range e {
+				keys[key] = true
+			}
+			for key := range a {
+				keys[key] = true
+			}
+			for _, key := range sortedKeys(keys) {
+				ev, eok := e[key]
+				av, aok := a[key]
+				d.jsonMember(jsonPath(path, key), ev, eok, av, aok)
+			}
+			return
+		}
+	case []interface{}:
+		if a, ok := actual.([]interface{}); ok {
+			for i := 0; i < len(e) || i < len(a); i++ {
+				var ev, av interface{}
+				if i < len(e) {
+					ev = e[i]
+				}
+				if i < len(a) {
+					av = a[i]
+				}
+				d.jsonMember(jsonPath(path, strconv.Itoa(i)), ev, i < len(e), av, i < len(a))
+			}
+			return
+		}
+	}
+	d.compare(path, jsonString(expected), jsonString(actual))
+}
+
+func (d *differ) jsonMember(path string, expected interface{}, expectedOK bool, actual interface{}, actualOK bool) {
+	if expectedOK && actualOK {
+		d.json(path, expected, actual)
+		return
+	}
+	e, a := missingValue, missingValue
+	if expectedOK {
+		e = jsonString(expected)
+	}
+	if actualOK {
+		a = jsonString(actual)
+	}
+	d.compare(path, e, a)
+}
+
+// jsonPath returns the path of the member key of the value at path, e.g.
+// "body items.0"
+func jsonPath(path, key string) string {
+	if path == "body" {
+		return path + " " + key
+	}
+	return path + "." + key
+}
+
+func jsonString(v interface{}) string {
+	data, _ := json.Marshal(v)
+	return string(data)
+}
+
+// readParts reads a multipart body into its parts by form name, a file
+// part is described by its file name, Content-Type and content
+func readParts(contentType string, body []byte) (map[string][]string, error) {
+	_, params, err := mime.ParseMediaType(contentType)
+	if err != nil {
+		return nil, err
+	}
+
+	parts := map[string][]string{}
+	mr := multipart.NewReader(bytes.NewReader(body), params["boundary"])
+	for {
+		part, err := mr.NextPart()
+		if err == io.EOF {
+			return parts, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		data, err := ioutil.ReadAll(part)
+		if err != nil {
+			return nil, err
+		}
+
+		val := string(data)
+		if part.FileName() != "" {
+			val = fmt.Sprintf("file %s (%s): %s", part.FileName(), partContentType(part.Header), data)
+		}
+		parts[part.FormName()] = append(parts[part.FormName()], val)
+	}
+}
+
+func partContentType(h textproto.MIMEHeader) string {
+	if ct := h.Get("Content-Type"); ct != "" {
+		return ct
+	}
+	return "text/plain"
+}
+
+func unionKeys(a, b map[string][]string) []string {
+	keys := make(map[string]bool, len(a)+len(b))
+	for key := range a {
+		keys[key] = true
+	}
+	for key := range b {
+		keys[key] = true
+	}
+	return sortedKeys(keys)
+}
+
+func sortedKeys(m map[string]bool) []string {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+func joinValues(m map[string][]string, key string) string {
+	vals, ok := m[key]
+	if !ok {
+		return missingValue
+	}
+	return strings.Join(vals, ", ")
+}
@@ -0,0 +1,195 @@
+package gohttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// newDiffRequest returns a request with body and headers given as
+// alternating keys and values
+func newDiffRequest(method, url, body string, header ...string) *http.Request {
+	r, _ := http.NewRequest(method, url, strings.NewReader(body))
+	for i := 0; i < len(header); i += 2 {
+		r.Header.Add(header[i], header[i+1])
+	}
+	return r
+}
+
+// multipartBody returns a multipart body with a field and a file, and its
+// Content-Type with a new boundary
+func multipartBody(field, file string) (string, string) {
+	var buf bytes.Buffer
+	w := multipart.NewWriter(&buf)
+	w.WriteField("name", field)
+	fw, _ := w.CreateFormFile("file", "a.txt")
+	fw.Write([]byte(file))
+	w.Close()
+	return buf.String(), w.FormDataContentType()
+}
+
+// TestDiffRequests tests the differences found between requests
+func TestDiffRequests(t *testing.T) {
+	t.Log("Comparing requests... (expected readable differences)")
+
+	mpExpected, ctExpected := multipartBody("report", "data")
+	mpSame, ctSame := multipartBody("report", "data")
+	mpOther, ctOther := multipartBody("summary", "other")
+
+	tests := []struct {
+		name     string
+		expected *http.Request
+		actual   *http.Request
+		opts     DiffOptions
+		diffs    []string
+	}{
+		{
+			"headers",
+			newDiffRequest("GET", "http://example.com/users?page=1", "", "X-Team", "core", "Accept", "application/json", "Date", "Mon"),
+			newDiffRequest("GET", "http://example.com/users?page=1", "", "X-Team", "ops", "Date", "Tue"),
+			DiffOptions{},
+			[]string{`header Accept expected "application/json" got <missing>`, `header X-Team expected "core" got "ops"`},
+		},
+		{
+			"method, url and query",
+			newDiffRequest("GET", "http://example.com/users?page=1&sort=asc", ""),
+			newDiffRequest("POST", "http://example.com/orders?page=2&sort=asc&x=1", ""),
+			DiffOptions{},
+			[]string{
+				`method expected "GET" got "POST"`,
+				`url expected "http://example.com/users" got "http://example.com/orders"`,
+				`query page expected "1" got "2"`,
+				`query x expected <missing> got "1"`,
+			},
+		},
+		{
+			"JSON body",
+			newDiffRequest("POST", "http://example.com", `{"items":[{"price":10,"sku":"a"}],"id":"x"}`, "Content-Type", "application/json"),
+			newDiffRequest("POST", "http://example.com", `{"id":"y","items":[{"price":12,"sku":"a"},{"price":1}]}`, "Content-Type", "application/json"),
+			DiffOptions{},
+			[]string{
+				`body id expected "\"x\"" got "\"y\""`,
+				`body items.0.price expected "10" got "12"`,
+				`body items.1 expected <missing> got "{\"price\":1}"`,
+			},
+		},
+		{
+			"matchers",
+			newDiffRequest("POST", "http://example.com", `{"id":"x","at":"2020-01-01"}`, "Content-Type", "application/json", "X-Request-Id", "1"),
+			newDiffRequest("POST", "http://example.com", `{"id":"y","at":"2024-05-06"}`, "Content-Type", "application/json", "X-Request-Id", "2"),
+			DiffOptions{Matchers: map[string]Matcher{
+				"header X-Request-Id": AnyValue(),
+				"body at":             MatchRegexp(`^"\d{4}-\d{2}-\d{2}"$`),
+			}},
+			[]string{`body id expected "\"x\"" got "\"y\""`},
+		},
+		{
+			"same multipart with other boundary",
+			newDiffRequest("POST", "http://example.com", mpExpected, "Content-Type", ctExpected),
+			newDiffRequest("POST", "http://example.com", mpSame, "Content-Type", ctSame),
+			DiffOptions{},
+			nil,
+		},
+		{
+			"multipart",
+			newDiffRequest("POST", "http://example.com", mpExpected, "Content-Type", ctExpected),
+			newDiffRequest("POST", "http://example.com", mpOther, "Content-Type", ctOther),
+			DiffOptions{},
+			[]string{
+				`part file expected "file a.txt (application/octet-stream): data" got "file a.txt (application/octet-stream): other"`,
+				`part name expected "report" got "summary"`,
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		var got []string
+		for _, diff := range DiffRequests(tt.expected, tt.actual, tt.opts) {
+			got = append(got, diff.String())
+		}
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", tt.diffs) {
+			t.Error(
+				"For", tt.name,
+				"expected", fmt.Sprintf("%q", tt.diffs),
+				"got", fmt.Sprintf("%q", got),
+			)
+		}
+	}
+}
+
+// TestDiffResponses tests the differences found between responses, and
+// that their bodies can still be read
+func TestDiffResponses(t *testing.T) {
+	t.Log("Comparing responses... (expected status and form differences)")
+
+	form := http.Header{"Content-Type": {"application/x-www-form-urlencoded"}}
+	expected := mockResponseWith(200, "a=1&b=2")
+	expected.Header = form
+	actual := mockResponseWith(201, "a=1&b=3")
+	actual.Header = form
+
+	var got []string
+	for _, diff := range DiffResponses(expected, actual, DiffOptions{}) {
+		got = append(got, diff.String())
+	}
+	want := []string{`status expected "200" got "201"`, `form b expected "2" got "3"`}
+	body, _ := ioutil.ReadAll(actual.Body)
+	if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) || string(body) != "a=1&b=3" {
+		t.Error(
+			"For", "responses",
+			"expected", want,
+			"got", got, string(body),
+		)
+	}
+
+	actual = mockResponseWith(200, "")
+	actual.Header = form
+	actual.Body = ioutil.NopCloser(&failingReader{n: 3, err: errors.New("boom")})
+	got = nil
+	for _, diff := range DiffResponses(expected, actual, DiffOptions{}) {
+		got = append(got, diff.String())
+	}
+	want = []string{`body expected "<read>" got "<read error: boom>"`}
+	if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", want) {
+		t.Error(
+			"For", "failing body",
+			"expected", want,
+			"got", got,
+		)
+	}
+}
+
+// TestMockTransportDiff tests unmatched requests report the differences to
+// the nearest mock response
+func TestMockTransportDiff(t *testing.T) {
+	t.Log("Sending request without matching mock response... (expected differences to nearest one)")
+
+	expected := newDiffRequest("POST", "http://example.com/users", `{"name":"gohttp"}`, "Content-Type", "application/json")
+	mock := NewMockTransport().
+		Register("GET", "http://example.com/orders", mockResponseWith(200, "ok")).
+		RegisterRequest(expected, mockResponseWith(201, "created"), DiffOptions{})
+
+	req := NewRequest(WithTransport(mock))
+	_, err := req.JSON(map[string]interface{}{"name": "other"}).Post("http://example.com/users")
+	if err == nil || !strings.Contains(err.Error(), "the nearest one differs:\n\tbody name expected \"\\\"gohttp\\\"\" got \"\\\"other\\\"\"") {
+		t.Error(
+			"For", "unmatched request",
+			"expected", "body difference",
+			"got", err,
+		)
+	}
+
+	resp, err := NewRequest(WithTransport(mock)).JSON(map[string]interface{}{"name": "gohttp"}).Post("http://example.com/users")
+	if err != nil || resp.GetStatusCode() != 201 {
+		t.Error(
+			"For", "matched request",
+			"expected", 201,
+			"got", resp, err,
+		)
+	}
+}
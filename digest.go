@@ -0,0 +1,187 @@
+package gohttp
+
+import (
+	"crypto/md5"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"net/http"
+	"strings"
+	"sync/atomic"
+)
+
+// digestChallenge is a parsed WWW-Authenticate Digest challenge (RFC 7616)
+type digestChallenge struct {
+	realm     string
+	nonce     string
+	opaque    string
+	algorithm string
+	qop       string
+	nc        uint32
+	cnonce    func() string
+}
+
+// parseDigestChallenge returns the first Digest challenge of header, nil
+// if there is none and an error if it is malformed or unsupported
+func parseDigestChallenge(header http.Header) (*digestChallenge, error) {
+	for _, val := range header.Values("WWW-Authenticate") {
+		if len(val) < 7 || !strings.EqualFold(val[:7], "Digest ") {
+			continue
+		}
+
+		params, err := parseAuthParams(val[7:])
+		if err != nil {
+			return nil, err
+		}
+
+		c := &digestChallenge{
+			realm:     params["realm"],
+			nonce:     params["nonce"],
+			opaque:    params["opaque"],
+			algorithm: params["algorithm"],
+			cnonce:    newCnonce,
+		}
+		if c.algorithm == "" {
+			c.algorithm = "MD5"
+		}
+
+		if _, ok := params["realm"]; !ok {
+			return nil, fmt.Errorf("gohttp: digest challenge without realm: %q", val)
+		}
+		if c.nonce == "" {
+			return nil, fmt.Errorf("gohttp: digest challenge without nonce: %q", val)
+		}
+		if c.hash() == nil {
+			return nil, fmt.Errorf("gohttp: digest algorithm %q is not supported", c.algorithm)
+		}
+
+		if qop, ok := params["qop"]; ok {
+			for _, opt := range strings.Split(qop, ",") {
+				if strings.TrimSpace(opt) == "auth" {
+					c.qop = "auth"
+				}
+			}
+			if c.qop == "" {
+				return nil, fmt.Errorf("gohttp: digest qop %q is not supported", qop)
+			}
+		}
+
+		return c, nil
+	}
+
+	return nil, nil
+}
+
+// parseAuthParams parses comma separated auth-params, values may be
+// quoted strings
+func parseAuthParams(s string) (map[string]string, error) {
+	params := map[string]string{}
+
+	for {
+		s = strings.TrimLeft(s, " \t,")
+		if s == "" {
+			return params, nil
+		}
+
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return nil, fmt.Errorf("gohttp: malformed auth-param in %q", s)
+		}
+		key := strings.ToLower(strings.TrimSpace(s[:eq]))
+		s = strings.TrimLeft(s[eq+1:], " \t")
+
+		var val strings.Builder
+		if strings.HasPrefix(s, `"`) {
+			i := 1
+			for ; i < len(s) && s[i] != '"'; i++ {
+				if s[i] == '\\' && i+1 < len(s) {
+					i++
+				}
+				val.WriteByte(s[i])
+			}
+			if i == len(s) {
+				return nil, fmt.Errorf("gohttp: unterminated quoted string for %q", key)
+			}
+			s = s[i+1:]
+		} else {
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			val.WriteString(strings.TrimSpace(s[:end]))
+			s = s[end:]
+		}
+
+		params[key] = val.String()
+	}
+}
+
+// hash returns the hash function of the challenge algorithm
+func (c *digestChallenge) hash() func() hash.Hash {
+	switch strings.ToUpper(strings.TrimSuffix(strings.ToLower(c.algorithm), "-sess")) {
+	case "MD5":
+		return md5.New
+	case "SHA-256":
+		return sha256.New
+	}
+	return nil
+}
+
+func (c *digestChallenge) h(s string) string {
+	h := c.hash()()
+	h.Write([]byte(s))
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// authorize sets the Authorization header answering the challenge
+func (c *digestChallenge) authorize(request *http.Request, username, password string) {
+	uri := request.URL.RequestURI()
+	nc := fmt.Sprintf("%08x", atomic.AddUint32(&c.nc, 1))
+	cnonce := c.cnonce()
+
+	ha1 := c.h(username + ":" + c.realm + ":" + password)
+	if strings.HasSuffix(strings.ToLower(c.algorithm), "-sess") {
+		ha1 = c.h(ha1 + ":" + c.nonce + ":" + cnonce)
+	}
+	ha2 := c.h(request.Method + ":" + uri)
+
+	var response string
+	if c.qop != "" {
+		response = c.h(strings.Join([]string{ha1, c.nonce, nc, cnonce, c.qop, ha2}, ":"))
+	} else {
+		response = c.h(ha1 + ":" + c.nonce + ":" + ha2)
+	}
+
+	fields := []string{
+		"username=" + quoteString(username),
+		"realm=" + quoteString(c.realm),
+		"nonce=" + quoteString(c.nonce),
+		"uri=" + quoteString(uri),
+		"algorithm=" + c.algorithm,
+		"response=" + quoteString(response),
+	}
+	if c.opaque != "" {
+		fields = append(fields, "opaque="+quoteString(c.opaque))
+	}
+	if c.qop != "" {
+		fields = append(fields, "qop="+c.qop, "nc="+nc, "cnonce="+quoteString(cnonce))
+	}
+
+	request.Header.Set("Authorization", "Digest "+strings.Join(fields, ", "))
+}
+
+// quoteString returns s as a HTTP quoted-string
+func quoteString(s string) string {
+	return `"` + strings.NewReplacer(`\`, `\\`, `"`, `\"`).Replace(s) + `"`
+}
+
+// newCnonce returns a random client nonce
+func newCnonce() string {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b)
+}
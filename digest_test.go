@@ -0,0 +1,142 @@
+package gohttp
+
+import (
+	"crypto/md5"
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestDigestRFC7616 tests the examples of RFC 7616 section 3.9.1
+func TestDigestRFC7616(t *testing.T) {
+	tests := []struct {
+		algorithm string
+		response  string
+	}{
+		{"MD5", "8ca523f5e9506fed4657c9700eebdbec"},
+		{"SHA-256", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1"},
+	}
+
+	for _, tt := range tests {
+		header := http.Header{}
+		header.Set("WWW-Authenticate", `Digest realm="http-auth@example.org", qop="auth, auth-int", algorithm=`+tt.algorithm+
+			`, nonce="7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", opaque="FQhe/qaU925kfnzjCev0ciny7QMkPqMAFRtzCUYo5tdS"`)
+
+		c, err := parseDigestChallenge(header)
+		if err != nil {
+			t.Fatal(err)
+		}
+		c.cnonce = func() string { return "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ" }
+
+		request, _ := http.NewRequest(http.MethodGet, "http://www.example.org/dir/index.html", nil)
+		c.authorize(request, "Mufasa", "Circle of Life")
+
+		auth := request.Header.Get("Authorization")
+		if !strings.Contains(auth, `response="`+tt.response+`"`) || !strings.Contains(auth, "nc=00000001") {
+			t.Error(
+				"For", tt.algorithm,
+				"expected", tt.response,
+				"got", auth,
+			)
+		}
+	}
+}
+
+// newDigestServer returns a server protected by MD5 digest auth which
+// echoes the request body to authenticated requests
+func newDigestServer(t *testing.T, hits *int) *httptest.Server {
+	h := func(s string) string {
+		sum := md5.Sum([]byte(s))
+		return hex.EncodeToString(sum[:])
+	}
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*hits++
+		auth := r.Header.Get("Authorization")
+		if !strings.HasPrefix(auth, "Digest ") {
+			w.Header().Set("WWW-Authenticate", `Digest realm="test", qop="auth", nonce="abc123", opaque="xyz"`)
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+
+		params, err := parseAuthParams(auth[7:])
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+
+		ha1 := h("user:test:pass")
+		ha2 := h(r.Method + ":" + r.URL.RequestURI())
+		expected := h(ha1 + ":abc123:" + params["nc"] + ":" + params["cnonce"] + ":auth:" + ha2)
+		if params["response"] != expected || params["opaque"] != "xyz" {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s", r.Method, body)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// TestDigestAuth tests DigestAuth answers the challenge with the same body
+func TestDigestAuth(t *testing.T) {
+	t.Log("Sending POST request with digest auth... (expected authenticated replay)")
+
+	hits := 0
+	ts := newDigestServer(t, &hits)
+
+	resp, err := NewRequest().DigestAuth("user", "pass").Text("payload").Post(ts.URL + "/dir?q=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body, _ := resp.GetBodyAsString(); resp.GetStatusCode() != 200 || body != "POST payload" || hits != 2 {
+		t.Error(
+			"For", "DigestAuth",
+			"expected", "POST payload after 2 requests",
+			"got", resp.GetStatusCode(), body, hits,
+		)
+	}
+}
+
+// TestDigestAuthChallenges tests non digest and malformed challenges
+func TestDigestAuthChallenges(t *testing.T) {
+	t.Log("Sending GET request with digest auth to other challenges... (expected untouched 401 or error)")
+
+	tests := []struct {
+		challenge string
+		fails     bool
+	}{
+		{`Basic realm="test"`, false},
+		{`Digest realm="test"`, true},
+		{`Digest realm="test", nonce="abc", algorithm=SHA-1`, true},
+		{`Digest realm="test", nonce="abc", qop="auth-int"`, true},
+		{`Digest realm="test", nonce="abc`, true},
+	}
+
+	for _, tt := range tests {
+		hits := 0
+		ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			hits++
+			w.Header().Set("WWW-Authenticate", tt.challenge)
+			w.WriteHeader(http.StatusUnauthorized)
+		}))
+
+		resp, err := NewRequest().DigestAuth("user", "pass").Get(ts.URL)
+		ts.Close()
+
+		if tt.fails && err == nil || !tt.fails && (err != nil || resp.GetStatusCode() != 401) || hits != 1 {
+			t.Error(
+				"For", tt.challenge,
+				"expected", "fails:", tt.fails,
+				"got", err, hits,
+			)
+		}
+	}
+}
@@ -0,0 +1,164 @@
+package gohttp
+
+import (
+	"io"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// redactedValue replaces the values of the redacted headers
+const redactedValue = "***"
+
+// sensitiveHeaders are the headers RedactedHeader always masks, besides
+// the ones whose name contains token or secret
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+	"Set-Cookie":          true,
+	"X-Api-Key":           true,
+}
+
+// RedactedHeader is a header which masks its credentials as *** when it is
+// formatted, e.g. for logging it with %v. Authorization, Cookie,
+// Set-Cookie, Proxy-Authorization, X-Api-Key and the headers whose name
+// contains token or secret are always masked, along with Keys.
+type RedactedHeader struct {
+	Header http.Header
+	Keys   []string
+}
+
+// redacts reports whether the values of key are masked
+func (h RedactedHeader) redacts(key string) bool {
+	key = http.CanonicalHeaderKey(key)
+	if sensitiveHeaders[key] {
+		return true
+	}
+	lower := strings.ToLower(key)
+	if strings.Contains(lower, "token") || strings.Contains(lower, "secret") {
+		return true
+	}
+	for _, k := range h.Keys {
+		if http.CanonicalHeaderKey(k) == key {
+			return true
+		}
+	}
+	return false
+}
+
+// Redacted returns a copy of the header with the masked values replaced by
+// ***
+func (h RedactedHeader) Redacted() http.Header {
+	redacted := make(http.Header, len(h.Header))
+	for key, vals := range h.Header {
+		if h.redacts(key) {
+			masked := make([]string, len(vals))
+			for i := range masked {
+				masked[i] = redactedValue
+			}
+			vals = masked
+		}
+		redacted[key] = vals
+	}
+	return redacted
+}
+
+// String returns the header in wire format with the masked values, sorted
+// by key
+func (h RedactedHeader) String() string {
+	var b strings.Builder
+	redacted := h.Redacted()
+	keys := make([]string, 0, len(redacted))
+	for key := range redacted {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, val := range redacted[key] {
+			b.WriteString(key)
+			b.WriteString(": ")
+			b.WriteString(val)
+			b.WriteString("\r\n")
+		}
+	}
+	return b.String()
+}
+
+// redactsParam reports whether the values of the query param key are
+// masked, the ones RedactedHeader masks and the ones whose name contains
+// key or password are, e.g. api_key of APIKeyQuery
+func (h RedactedHeader) redactsParam(key string) bool {
+	lower := strings.ToLower(key)
+	return h.redacts(key) || strings.Contains(lower, "key") || strings.Contains(lower, "password")
+}
+
+// redactURL returns a copy of u with its user info and the values of the
+// params redactsParam reports masked by ***, the params keep their order
+func (h RedactedHeader) redactURL(u *url.URL) *url.URL {
+	redacted := *u
+	if u.User != nil {
+		redacted.User = url.User(redactedValue)
+	}
+
+	var b strings.Builder
+	for raw := u.RawQuery; raw != ""; {
+		param, sep := raw, ""
+		if i := strings.IndexAny(raw, "&;"); i >= 0 {
+			param, sep, raw = raw[:i], raw[i:i+1], raw[i+1:]
+		} else {
+			raw = ""
+		}
+		if i := strings.IndexByte(param, '='); i >= 0 {
+			if key, err := url.QueryUnescape(param[:i]); err != nil || h.redactsParam(key) {
+				param = param[:i+1] + redactedValue
+			}
+		}
+		b.WriteString(param)
+		b.WriteString(sep)
+	}
+	redacted.RawQuery = b.String()
+	return &redacted
+}
+
+// WithDumpTo option writes the request line and headers of every request
+// sent, and the status line and headers of its response, to w. Bodies are
+// not written. The headers are written as a RedactedHeader masking
+// redactKeys too, so credentials never appear in the dump. The user info of
+// the URL is masked as well, like the query params named like the masked
+// headers or containing key or password, e.g. api_key, or in redactKeys.
+func WithDumpTo(w io.Writer, redactKeys ...string) OptionFunc {
+	var mu sync.Mutex
+	write := func(dump []byte) {
+		mu.Lock()
+		defer mu.Unlock()
+		w.Write(dump)
+	}
+
+	return WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			out := r.Clone(r.Context())
+			redacted := RedactedHeader{Header: r.Header, Keys: redactKeys}
+			out.Header = redacted.Redacted()
+			out.URL = redacted.redactURL(r.URL)
+			if dump, err := httputil.DumpRequestOut(out, false); err == nil {
+				write(dump)
+			}
+
+			resp, err := next.RoundTrip(r)
+			if err != nil {
+				return resp, err
+			}
+			in := *resp
+			in.Header = RedactedHeader{Header: resp.Header, Keys: redactKeys}.Redacted()
+			in.Body = nil
+			if dump, err := httputil.DumpResponse(&in, false); err == nil {
+				write(dump)
+			}
+			return resp, nil
+		})
+	})
+}
@@ -0,0 +1,134 @@
+package gohttp
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestRedactedHeader tests credentials are masked when a header is
+// formatted
+func TestRedactedHeader(t *testing.T) {
+	t.Log("Formatting redacted headers... (expected credentials masked)")
+
+	header := http.Header{
+		"Authorization":       {"Basic dXNlcjpwYXNz"},
+		"Proxy-Authorization": {"Basic cHJveHk="},
+		"Cookie":              {"session=s3cr3t"},
+		"X-Api-Key":           {"k3y"},
+		"X-Auth-Token":        {"t0ken"},
+		"Client-Secret":       {"s3cret", "other"},
+		"X-Internal":          {"internal"},
+		"Accept":              {"application/json"},
+	}
+
+	expected := "Accept: application/json\r\n" +
+		"Authorization: ***\r\n" +
+		"Client-Secret: ***\r\n" +
+		"Client-Secret: ***\r\n" +
+		"Cookie: ***\r\n" +
+		"Proxy-Authorization: ***\r\n" +
+		"X-Api-Key: ***\r\n" +
+		"X-Auth-Token: ***\r\n" +
+		"X-Internal: ***\r\n"
+	got := fmt.Sprintf("%v", RedactedHeader{Header: header, Keys: []string{"x-internal"}})
+	if got != expected || header.Get("Authorization") != "Basic dXNlcjpwYXNz" {
+		t.Error(
+			"For", "RedactedHeader",
+			"expected", expected,
+			"got", got, header.Get("Authorization"),
+		)
+	}
+}
+
+// TestWithDumpTo tests the dump of a request with basic auth doesn't hold
+// its credentials
+func TestWithDumpTo(t *testing.T) {
+	t.Log("Dumping request with basic auth and cookies... (expected no raw credentials)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3ss10n"})
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Write([]byte("hidden body"))
+	}))
+	defer ts.Close()
+
+	var dump bytes.Buffer
+	resp, err := NewRequest(WithDumpTo(&dump, "X-Internal")).
+		BasicAuth("user", "p@ssw0rd").
+		Headers(map[string]string{"Cookie": "theme=dark", "X-Api-Key": "k3y", "X-Internal": "internal"}).
+		Text("sent body").
+		Post(ts.URL + "/login")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := resp.GetBodyAsString()
+
+	credentials := base64.StdEncoding.EncodeToString([]byte("user:p@ssw0rd"))
+	for _, secret := range []string{credentials, "p@ssw0rd", "theme=dark", "k3y", "internal", "s3ss10n", "body"} {
+		if strings.Contains(dump.String(), secret) {
+			t.Error(
+				"For", secret,
+				"expected", "not dumped",
+				"got", dump.String(),
+			)
+		}
+	}
+	for _, line := range []string{"POST /login HTTP/1.1", "Authorization: ***", "Cookie: ***", "HTTP/1.1 200 OK", "Set-Cookie: ***", "X-Ratelimit-Remaining: 42"} {
+		if !strings.Contains(dump.String(), line) {
+			t.Error(
+				"For", line,
+				"expected", "dumped",
+				"got", dump.String(),
+			)
+		}
+	}
+	if body != "hidden body" {
+		t.Error(
+			"For", "response body",
+			"expected", "hidden body",
+			"got", body,
+		)
+	}
+}
+
+// TestWithDumpToURL tests the dump of a request doesn't hold the
+// credentials of its URL
+func TestWithDumpToURL(t *testing.T) {
+	t.Log("Dumping request with API key query and user info... (expected URL credentials masked)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	defer ts.Close()
+
+	var dump bytes.Buffer
+	u := strings.Replace(ts.URL, "://", "://admin:hunter2@", 1) + "/items?page=2&Access_Token=t0k3n&x-internal=h1dden&q=go"
+	resp, err := NewRequest(WithDumpTo(&dump, "X-Internal")).APIKeyQuery("api_key", "qk3y").Get(u)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sent, _ := resp.GetBodyAsString()
+
+	for _, secret := range []string{"hunter2", "admin", "qk3y", "t0k3n", "h1dden"} {
+		if strings.Contains(dump.String(), secret) {
+			t.Error(
+				"For", secret,
+				"expected", "not dumped",
+				"got", dump.String(),
+			)
+		}
+	}
+	expected := "GET /items?page=2&Access_Token=***&x-internal=***&q=go&api_key=*** HTTP/1.1"
+	if !strings.Contains(dump.String(), expected) || !strings.Contains(sent, "api_key=qk3y") {
+		t.Error(
+			"For", "request line",
+			"expected", expected,
+			"got", dump.String(), sent,
+		)
+	}
+}
@@ -0,0 +1,184 @@
+package gohttp
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// TestErrorSentinels tests the errors of every layer a request can fail in
+// match the standard sentinels
+func TestErrorSentinels(t *testing.T) {
+	t.Log("Failing requests in every layer... (expected errors.Is to match context and io errors)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		wait := func() {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+		}
+		switch r.URL.Path {
+		case "/slow":
+			wait()
+		case "/stall":
+			w.Write([]byte(`{"partial":`))
+			w.(http.Flusher).Flush()
+			wait()
+		case "/retry":
+			w.Header().Set("Retry-After", "5")
+			w.WriteHeader(http.StatusServiceUnavailable)
+		case "/hangup":
+			conn, _, _ := w.(http.Hijacker).Hijack()
+			conn.Close()
+		}
+	}))
+	defer ts.Close()
+
+	deadline := func() context.Context {
+		ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+		t.Cleanup(cancel)
+		return ctx
+	}
+	canceled := func() context.Context {
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+		return ctx
+	}
+	readBody := func(resp *Response, err error) error {
+		if err != nil {
+			return err
+		}
+		_, err = resp.GetBodyAsByte()
+		return err
+	}
+	hanging := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	tests := []struct {
+		layer    string
+		fail     func() error
+		expected error
+	}{
+		{"expired before send", func() error {
+			ctx := deadline()
+			<-ctx.Done()
+			_, err := NewRequest().SetContext(ctx).Get(ts.URL)
+			return err
+		}, context.DeadlineExceeded},
+		{"context deadline", func() error {
+			_, err := NewRequest().SetContext(deadline()).Get(ts.URL + "/slow")
+			return err
+		}, context.DeadlineExceeded},
+		{"client timeout", func() error {
+			_, err := NewRequest(SetTimeout(50 * time.Millisecond)).Get(ts.URL + "/slow")
+			return err
+		}, context.DeadlineExceeded},
+		{"request timeout", func() error {
+			_, err := NewRequest().Timeout(50 * time.Millisecond).Get(ts.URL + "/slow")
+			return err
+		}, context.DeadlineExceeded},
+		{"connect timeout", func() error {
+			_, err := NewRequest(SetTransport(hanging), WithConnectTimeout(50*time.Millisecond)).Get("http://example.com/")
+			return err
+		}, context.DeadlineExceeded},
+		{"read timeout on headers", func() error {
+			_, err := NewRequest(WithReadTimeout(50 * time.Millisecond)).Get(ts.URL + "/slow")
+			return err
+		}, context.DeadlineExceeded},
+		{"read timeout on body", func() error {
+			return readBody(NewRequest(WithReadTimeout(50 * time.Millisecond)).Get(ts.URL + "/stall"))
+		}, context.DeadlineExceeded},
+		{"client timeout on body", func() error {
+			return readBody(NewRequest(SetTimeout(100 * time.Millisecond)).Get(ts.URL + "/stall"))
+		}, context.DeadlineExceeded},
+		{"context deadline on body", func() error {
+			return readBody(NewRequest().SetContext(deadline()).Get(ts.URL + "/stall"))
+		}, context.DeadlineExceeded},
+		{"JSON decode", func() error {
+			resp, err := NewRequest().Timeout(50 * time.Millisecond).Get(ts.URL + "/stall")
+			if err != nil {
+				return err
+			}
+			var v interface{}
+			return resp.JSON(&v)
+		}, context.DeadlineExceeded},
+		{"expectations", func() error {
+			_, err := NewRequest().SetContext(deadline()).ExpectStatus(200).Get(ts.URL + "/stall")
+			return err
+		}, context.DeadlineExceeded},
+		{"retry wait", func() error {
+			_, err := NewRequest(WithRetry(3, time.Millisecond)).SetContext(deadline()).Get(ts.URL + "/retry")
+			return err
+		}, context.DeadlineExceeded},
+		{"hook", func() error {
+			_, err := NewRequest().SetContext(deadline()).OnBeforeRequest(func(r *Request) error {
+				<-r.Context().Done()
+				return r.Context().Err()
+			}).Get(ts.URL)
+			return err
+		}, context.DeadlineExceeded},
+		{"batch", func() error {
+			results := NewBatchRequest(NewRequest()).Add("GET", ts.URL+"/slow").Execute(deadline())
+			return results[0].Err
+		}, context.DeadlineExceeded},
+		{"async", func() error {
+			ch := make(chan *AsyncResponse, 1)
+			NewRequest().SetContext(deadline()).AsyncGet(ts.URL+"/slow", ch)
+			return (<-ch).Err
+		}, context.DeadlineExceeded},
+		{"lines", func() error {
+			resp, err := NewRequest().Get(ts.URL + "/stall")
+			if err != nil {
+				return err
+			}
+			lines, errs := resp.Lines(deadline())
+			for range lines {
+			}
+			return <-errs
+		}, context.DeadlineExceeded},
+		{"download", func() error {
+			_, err := Download(deadline(), ts.URL+"/stall", filepath.Join(t.TempDir(), "file"))
+			return err
+		}, context.DeadlineExceeded},
+		{"canceled", func() error {
+			_, err := NewRequest().SetContext(canceled()).Get(ts.URL)
+			return err
+		}, context.Canceled},
+		{"canceled retry wait", func() error {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			_, err := NewRequest(WithRetry(3, time.Millisecond)).SetContext(ctx).Get(ts.URL + "/retry")
+			return err
+		}, context.Canceled},
+		{"canceled body", func() error {
+			ctx, cancel := context.WithCancel(context.Background())
+			time.AfterFunc(50*time.Millisecond, cancel)
+			return readBody(NewRequest().SetContext(ctx).Get(ts.URL + "/stall"))
+		}, context.Canceled},
+		{"connection closed", func() error {
+			_, err := NewRequest().Get(ts.URL + "/hangup")
+			return err
+		}, io.EOF},
+	}
+
+	for _, tt := range tests {
+		if err := tt.fail(); !errors.Is(err, tt.expected) {
+			t.Error(
+				"For", tt.layer,
+				"expected", tt.expected,
+				"got", err,
+			)
+		}
+	}
+}
@@ -0,0 +1,65 @@
+package gohttp
+
+import "time"
+
+// EventKind is the lifecycle phase of an Event
+type EventKind int
+
+const (
+	// EventStart is sent when the request is about to be sent
+	EventStart EventKind = iota
+	// EventRetry is sent when a response is discarded to retry the request
+	EventRetry
+	// EventResponse is sent when the response is received
+	EventResponse
+	// EventError is sent when the request fails
+	EventError
+)
+
+func (k EventKind) String() string {
+	switch k {
+	case EventStart:
+		return "start"
+	case EventRetry:
+		return "retry"
+	case EventResponse:
+		return "response"
+	case EventError:
+		return "error"
+	}
+	return "unknown"
+}
+
+// Event is a lifecycle phase of a request sent to the channel given with
+// WithEventChannel. Attempt counts the retries, StatusCode is set for
+// retries and responses, Err for errors.
+type Event struct {
+	Kind       EventKind
+	Time       time.Time
+	Method     string
+	URL        string
+	Attempt    int
+	StatusCode int
+	Err        error
+}
+
+// WithEventChannel option sends the lifecycle events of the request to ch.
+// Sending never blocks the request, events are dropped while ch is full, so
+// it should be buffered.
+func WithEventChannel(ch chan<- Event) OptionFunc {
+	return func(r *Request) {
+		r.events = ch
+	}
+}
+
+// emit sends e to the event channel unless it is full
+func (req *Request) emit(e Event) {
+	if req.events == nil {
+		return
+	}
+	e.Time = time.Now()
+	select {
+	case req.events <- e:
+	default:
+	}
+}
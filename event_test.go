@@ -0,0 +1,80 @@
+package gohttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// collectEvents returns the events sent to ch so far
+func collectEvents(ch chan Event) []Event {
+	var events []Event
+	for {
+		select {
+		case e := <-ch:
+			events = append(events, e)
+		default:
+			return events
+		}
+	}
+}
+
+// TestWithEventChannel tests the events of a retried request and a failed
+// one
+func TestWithEventChannel(t *testing.T) {
+	t.Log("Collecting events of requests... (expected start, retry and response or error)")
+
+	var calls int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&calls, 1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	ch := make(chan Event, 10)
+	if _, err := NewRequest(WithEventChannel(ch), WithRetry(1, time.Millisecond)).Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+	NewRequest(WithEventChannel(ch)).Get("http://127.0.0.1:0")
+
+	events := collectEvents(ch)
+	expected := "[start 0 0 retry 1 503 response 1 200 start 0 0 error 0 0]"
+	var got []interface{}
+	for _, e := range events {
+		got = append(got, e.Kind, e.Attempt, e.StatusCode)
+		if e.Time.IsZero() || (e.Kind == EventError) != (e.Err != nil) {
+			t.Error(
+				"For", e.Kind,
+				"expected", "time and error of error events",
+				"got", e.Time, e.Err,
+			)
+		}
+	}
+	if fmt.Sprint(got) != expected {
+		t.Error(
+			"For", "events",
+			"expected", expected,
+			"got", got,
+		)
+	}
+	if events[0].Method != "GET" || events[0].URL != ts.URL {
+		t.Error(
+			"For", "start event",
+			"expected", "GET", ts.URL,
+			"got", events[0].Method, events[0].URL,
+		)
+	}
+
+	// nobody receives from an unbuffered channel, the request must not block
+	if _, err := NewRequest(WithEventChannel(make(chan Event))).Get(ts.URL); err != nil {
+		t.Error(
+			"For", "full channel",
+			"expected", "no error",
+			"got", err,
+		)
+	}
+}
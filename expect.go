@@ -0,0 +1,121 @@
+package gohttp
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+)
+
+// ErrExpectationFailed is returned when a response doesn't meet the
+// expectations set with ExpectStatus, ExpectHeader or ExpectJSONField
+var ErrExpectationFailed = errors.New("gohttp: response expectation failed")
+
+// expectationSnippetLen is the length of the body included in an
+// ExpectationError
+const expectationSnippetLen = 256
+
+// ExpectationError lists the expectations a response didn't meet, Body is
+// the beginning of the response body
+type ExpectationError struct {
+	StatusCode int
+	Failures   []string
+	Body       string
+}
+
+func (e *ExpectationError) Error() string {
+	return fmt.Sprintf("gohttp: status %d: %s; body: %q", e.StatusCode, strings.Join(e.Failures, "; "), e.Body)
+}
+
+// Is reports whether target is ErrExpectationFailed
+func (e *ExpectationError) Is(target error) bool {
+	return target == ErrExpectationFailed
+}
+
+// expectation returns a description of how res fails it, or an empty string
+type expectation func(res *Response, body []byte) string
+
+// ExpectStatus method expects the response status to be code
+func (req *Request) ExpectStatus(code int) *Request {
+	return req.expect(func(res *Response, body []byte) string {
+		if res.GetStatusCode() != code {
+			return fmt.Sprintf("expected status %d, got %d", code, res.GetStatusCode())
+		}
+		return ""
+	})
+}
+
+// ExpectHeader method expects the response to have a non-empty header key
+func (req *Request) ExpectHeader(key string) *Request {
+	return req.expect(func(res *Response, body []byte) string {
+		if res.resp.Header.Get(key) == "" {
+			return fmt.Sprintf("expected header %s, got none", key)
+		}
+		return ""
+	})
+}
+
+// ExpectJSONField method expects the response body to be a JSON object
+// with field. Nested fields are separated by dots, e.g. "data.id".
+func (req *Request) ExpectJSONField(field string) *Request {
+	return req.expect(func(res *Response, body []byte) string {
+		var v interface{}
+		if err := json.Unmarshal(body, &v); err != nil {
+			return fmt.Sprintf("expected JSON field %s, got invalid JSON: %v", field, err)
+		}
+		for _, key := range strings.Split(field, ".") {
+			obj, ok := v.(map[string]interface{})
+			if !ok {
+				return fmt.Sprintf("expected JSON field %s, got no object at %s", field, key)
+			}
+			if v, ok = obj[key]; !ok {
+				return fmt.Sprintf("expected JSON field %s, got none", field)
+			}
+		}
+		return ""
+	})
+}
+
+func (req *Request) expect(e expectation) *Request {
+	req.expectations = append(req.expectations[:len(req.expectations):len(req.expectations)], e)
+	return req
+}
+
+// checkExpectations returns an ExpectationError when res doesn't meet the
+// expectations. The body is read into memory and put back so the caller
+// can still read it.
+func (req *Request) checkExpectations(res *Response) error {
+	if len(req.expectations) == 0 {
+		return nil
+	}
+
+	body, err := ioutil.ReadAll(res.resp.Body)
+	res.resp.Body.Close()
+	res.resp.Body = ioutil.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	var failures []string
+	for _, e := range req.expectations {
+		if failure := e(res, body); failure != "" {
+			failures = append(failures, failure)
+		}
+	}
+	if len(failures) == 0 {
+		return nil
+	}
+
+	snippet := body
+	if len(snippet) > expectationSnippetLen {
+		snippet = snippet[:expectationSnippetLen]
+	}
+
+	return &ExpectationError{
+		StatusCode: res.GetStatusCode(),
+		Failures:   failures,
+		Body:       string(snippet),
+	}
+}
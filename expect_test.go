@@ -0,0 +1,72 @@
+package gohttp
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestExpectations tests each expectation alone and combined
+func TestExpectations(t *testing.T) {
+	t.Log("Sending POST requests with expectations... (expected errors for unmet ones)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/users/7")
+		w.WriteHeader(http.StatusCreated)
+		w.Write([]byte(`{"id":7,"data":{"name":"x"}}`))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		expect   func(*Request) *Request
+		failures []string
+	}{
+		{"status", func(r *Request) *Request { return r.ExpectStatus(201) }, nil},
+		{"wrong status", func(r *Request) *Request { return r.ExpectStatus(200) }, []string{"expected status 200, got 201"}},
+		{"header", func(r *Request) *Request { return r.ExpectHeader("Location") }, nil},
+		{"missing header", func(r *Request) *Request { return r.ExpectHeader("ETag") }, []string{"expected header ETag, got none"}},
+		{"field", func(r *Request) *Request { return r.ExpectJSONField("id") }, nil},
+		{"nested field", func(r *Request) *Request { return r.ExpectJSONField("data.name") }, nil},
+		{"missing field", func(r *Request) *Request { return r.ExpectJSONField("data.age") }, []string{"expected JSON field data.age, got none"}},
+		{"field in value", func(r *Request) *Request { return r.ExpectJSONField("id.x") }, []string{"expected JSON field id.x, got no object at x"}},
+		{"all met", func(r *Request) *Request {
+			return r.ExpectStatus(201).ExpectHeader("Location").ExpectJSONField("id")
+		}, nil},
+		{"some unmet", func(r *Request) *Request {
+			return r.ExpectStatus(200).ExpectHeader("Location").ExpectJSONField("name")
+		}, []string{"expected status 200, got 201", "expected JSON field name, got none"}},
+	}
+
+	for _, tt := range tests {
+		resp, err := tt.expect(NewRequest()).Post(ts.URL)
+
+		var expErr *ExpectationError
+		if errors.As(err, &expErr) {
+			if !errors.Is(err, ErrExpectationFailed) || strings.Join(expErr.Failures, "|") != strings.Join(tt.failures, "|") ||
+				expErr.Body != `{"id":7,"data":{"name":"x"}}` {
+				t.Error(
+					"For", tt.name,
+					"expected", tt.failures,
+					"got", err,
+				)
+			}
+		} else if err != nil || tt.failures != nil {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.failures,
+				"got", err,
+			)
+		}
+
+		if body, _ := resp.GetBodyAsString(); body != `{"id":7,"data":{"name":"x"}}` {
+			t.Error(
+				"For", tt.name,
+				"expected", "unconsumed body",
+				"got", body,
+			)
+		}
+	}
+}
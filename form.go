@@ -0,0 +1,34 @@
+package gohttp
+
+import (
+	"bytes"
+	"net/url"
+)
+
+// FormDataMulti set Post request form parameters like FormData, a key with
+// several values is sent repeated, e.g. tag=a&tag=b
+func (req *Request) FormDataMulti(formValues url.Values) *Request {
+	req.resetBody()
+	req.formVals = bytes.NewBuffer([]byte(formValues.Encode()))
+	req.contentType = "application/x-www-form-urlencoded"
+
+	return req
+}
+
+// FormStruct set Post request form parameters from the fields of the struct
+// v, like FormDataMulti. A field is sent with the name of its form tag, or
+// its own name, and is skipped with the tag "-" or when it is empty and the
+// tag has omitempty, e.g. `form:"tag,omitempty"`. Slices send repeated
+// keys, or one comma separated value with the comma option, nested structs
+// and maps are sent as parent[child] and time.Time as RFC 3339, or with the
+// layout of a layout tag. A field which can't be encoded, e.g. a func, is
+// returned as error when the request is sent.
+func (req *Request) FormStruct(v interface{}) *Request {
+	vals, err := structEncoder{tags: []string{"form"}, what: "form"}.structValues(v)
+	if err != nil {
+		req.setErr(err)
+		return req
+	}
+
+	return req.FormDataMulti(vals)
+}
@@ -0,0 +1,113 @@
+package gohttp
+
+import (
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+type formAddress struct {
+	City string `form:"city"`
+	Zip  string `form:"zip,omitempty"`
+}
+
+type formPaging struct {
+	Page int `form:"page"`
+}
+
+type formUser struct {
+	formPaging
+	Name     string            `form:"name"`
+	Tags     []string          `form:"tag"`
+	Age      *int              `form:"age"`
+	Admin    bool              `form:"admin,omitempty"`
+	Score    float64           `form:"score"`
+	Address  formAddress       `form:"address"`
+	Previous []formAddress     `form:"previous"`
+	Meta     map[string]string `form:"meta"`
+	Since    time.Time         `form:"since"`
+	IP       net.IP            `form:"ip"`
+	Secret   string            `form:"-"`
+	Nickname string
+	internal string
+}
+
+// TestFormStruct tests the exact bodies of FormDataMulti and FormStruct
+func TestFormStruct(t *testing.T) {
+	t.Log("Encoding forms with repeated and nested values... (expected encoded bodies)")
+
+	user := formUser{
+		formPaging: formPaging{Page: 2},
+		Name:       "Nahid & co",
+		Tags:       []string{"b", "a"},
+		Score:      1.5,
+		Address:    formAddress{City: "Dhaka"},
+		Previous:   []formAddress{{City: "Sylhet", Zip: "3100"}},
+		Meta:       map[string]string{"z": "1", "a": "2"},
+		Since:      time.Date(2020, 1, 2, 3, 4, 5, 0, time.UTC),
+		IP:         net.ParseIP("10.0.0.1"),
+		Secret:     "hidden",
+		Nickname:   "nahid",
+		internal:   "hidden",
+	}
+
+	tests := []struct {
+		name     string
+		req      *Request
+		expected string
+	}{
+		{"FormDataMulti", NewRequest().FormDataMulti(url.Values{"tag": {"b", "a"}, "q": {"a b"}}), "q=a+b&tag=b&tag=a"},
+		{
+			"FormStruct",
+			NewRequest().FormStruct(&user),
+			"Nickname=nahid&address%5Bcity%5D=Dhaka&ip=10.0.0.1&meta%5Ba%5D=2&meta%5Bz%5D=1&name=Nahid+%26+co&page=2" +
+				"&previous%5B0%5D%5Bcity%5D=Sylhet&previous%5B0%5D%5Bzip%5D=3100&score=1.5&since=2020-01-02T03%3A04%3A05Z&tag=b&tag=a",
+		},
+		{"map", NewRequest().FormStruct(map[string][]int{"id": {3, 1}}), "id=3&id=1"},
+	}
+
+	for _, tt := range tests {
+		if got := string(tt.req.BodyBytes()); got != tt.expected || tt.req.contentType != "application/x-www-form-urlencoded" {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", got, tt.req.contentType,
+			)
+		}
+	}
+}
+
+// TestFormStructError tests values which can't be encoded fail the request
+func TestFormStructError(t *testing.T) {
+	t.Log("Encoding forms of unsupported values... (expected errors before sending)")
+
+	sent := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		sent = true
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		v    interface{}
+	}{
+		{"string", "name=x"},
+		{"func field", struct{ F func() }{func() {}}},
+		{"int keys", map[int]string{1: "a"}},
+	}
+
+	for _, tt := range tests {
+		_, err := NewRequest().FormStruct(tt.v).Post(ts.URL)
+		if err == nil || errors.Is(err, ErrBadStatus) || sent {
+			t.Error(
+				"For", tt.name,
+				"expected", "encoding error",
+				"got", err, sent,
+			)
+		}
+	}
+}
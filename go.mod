@@ -1,3 +1,8 @@
 module github.com/tenminschool/gohttp
 
-go 1.14
+go 1.16
+
+require (
+	go.uber.org/goleak v1.1.12
+	golang.org/x/sync v0.0.0-20220907140024-f12130a52804
+)
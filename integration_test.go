@@ -0,0 +1,324 @@
+package gohttp
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// inspected is how the inspect server saw a request
+type inspected struct {
+	Method      string
+	Path        string
+	Query       url.Values
+	ContentType string
+	Body        string
+	User        string
+	Password    string
+	Cookie      string
+}
+
+// newInspectServer returns a server answering every request with how it
+// saw it. /redirect/<code> redirects to /target with code, /cookie sets a
+// cookie and /slow waits until the request is canceled.
+func newInspectServer(t *testing.T, tls bool) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		in := inspected{
+			Method:      r.Method,
+			Path:        r.URL.Path,
+			Query:       r.URL.Query(),
+			ContentType: r.Header.Get("Content-Type"),
+			Body:        string(body),
+		}
+		in.User, in.Password, _ = r.BasicAuth()
+		if c, err := r.Cookie("session"); err == nil {
+			in.Cookie = c.Value
+		}
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(in)
+	})
+	mux.HandleFunc("/redirect/", func(w http.ResponseWriter, r *http.Request) {
+		var code int
+		fmt.Sscan(strings.TrimPrefix(r.URL.Path, "/redirect/"), &code)
+		http.Redirect(w, r, "/target", code)
+	})
+	mux.HandleFunc("/cookie", func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/"})
+	})
+	mux.HandleFunc("/slow", func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	})
+
+	ts := httptest.NewUnstartedServer(mux)
+	if tls {
+		ts.StartTLS()
+	} else {
+		ts.Start()
+	}
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// newInspectRequest returns a request trusting the certificate of ts
+func newInspectRequest(ts *httptest.Server, opts ...Option) *Request {
+	if ts.TLS != nil {
+		pool := x509.NewCertPool()
+		pool.AddCert(ts.Certificate())
+		opts = append([]Option{WithRootCAs(pool)}, opts...)
+	}
+	return NewRequest(opts...)
+}
+
+// inspector returns a function decoding how the inspect server saw the
+// request of a response, it fails t on errors
+func inspector(t *testing.T) func(*Response, error) inspected {
+	return func(resp *Response, err error) inspected {
+		t.Helper()
+
+		if err != nil {
+			t.Fatal(err)
+		}
+		var in inspected
+		if err := resp.JSON(&in); err != nil {
+			t.Fatal(err)
+		}
+		return in
+	}
+}
+
+// TestIntegrationMethodsAndBodies tests every method with every body type,
+// over HTTP and HTTPS
+func TestIntegrationMethodsAndBodies(t *testing.T) {
+	t.Log("Sending requests with bodies to HTTP and HTTPS servers... (expected method, body and Content-Type)")
+
+	bodies := []struct {
+		name        string
+		set         func(*Request) *Request
+		body        string
+		contentType string
+	}{
+		{"JSON", func(r *Request) *Request { return r.JSON(map[string]interface{}{"name": "gohttp"}) }, `{"name":"gohttp"}`, "application/json"},
+		{"FormData", func(r *Request) *Request { return r.FormData(map[string]string{"name": "go http"}) }, "name=go+http", "application/x-www-form-urlencoded"},
+		{"Text", func(r *Request) *Request { return r.Text("hello") }, "hello", "text/plain"},
+		{"Body", func(r *Request) *Request { return r.Body([]byte{1, 2, 3}) }, "\x01\x02\x03", "application/octet-stream"},
+	}
+
+	inspect := inspector(t)
+	for _, tls := range []bool{false, true} {
+		ts := newInspectServer(t, tls)
+		for _, method := range []string{"POST", "PUT", "PATCH", "DELETE"} {
+			for _, b := range bodies {
+				req := b.set(newInspectRequest(ts))
+				send := map[string]func(string) (*Response, error){
+					"POST": req.Post, "PUT": req.Put, "PATCH": req.Patch, "DELETE": req.Delete,
+				}[method]
+
+				in := inspect(send(ts.URL + "/items"))
+				if in.Method != method || in.Path != "/items" || in.Body != b.body || in.ContentType != b.contentType {
+					t.Error(
+						"For", method, b.name, "tls", tls,
+						"expected", method, b.body, b.contentType,
+						"got", in.Method, in.Body, in.ContentType,
+					)
+				}
+			}
+		}
+
+		in := inspect(newInspectRequest(ts).Get(ts.URL + "/items"))
+		if in.Method != "GET" || in.Body != "" {
+			t.Error(
+				"For", "GET", "tls", tls,
+				"expected", "GET without body",
+				"got", in.Method, in.Body,
+			)
+		}
+	}
+}
+
+// TestIntegrationBasicAuthAndQuery tests the basic auth header and the
+// encoding of query values
+func TestIntegrationBasicAuthAndQuery(t *testing.T) {
+	t.Log("Sending GET request with basic auth and query... (expected credentials and decoded query)")
+
+	ts := newInspectServer(t, false)
+	inspect := inspector(t)
+
+	query := map[string]string{"q": "a b&c=d", "name": "José", "empty": ""}
+	in := inspect(NewRequest().BasicAuth("user", "p@ss:word").Query(query).Get(ts.URL))
+
+	if in.User != "user" || in.Password != "p@ss:word" {
+		t.Error(
+			"For", "BasicAuth",
+			"expected", "user p@ss:word",
+			"got", in.User, in.Password,
+		)
+	}
+	for key, val := range query {
+		if got, ok := in.Query[key]; !ok || got[0] != val {
+			t.Error(
+				"For", "query", key,
+				"expected", val,
+				"got", got,
+			)
+		}
+	}
+}
+
+// TestIntegrationCookieJar tests a cookie set by the server is sent back
+func TestIntegrationCookieJar(t *testing.T) {
+	t.Log("Sending requests with cookie jar... (expected cookie sent back)")
+
+	ts := newInspectServer(t, false)
+	inspect := inspector(t)
+	jar, _ := cookiejar.New(nil)
+
+	if _, err := NewRequest(SetCookieJar(jar)).Get(ts.URL + "/cookie"); err != nil {
+		t.Fatal(err)
+	}
+	withJar := inspect(NewRequest(SetCookieJar(jar)).Get(ts.URL))
+	withoutJar := inspect(NewRequest().Get(ts.URL))
+
+	if withJar.Cookie != "s3cr3t" || withoutJar.Cookie != "" {
+		t.Error(
+			"For", "cookie jar",
+			"expected", "s3cr3t only with jar",
+			"got", withJar.Cookie, withoutJar.Cookie,
+		)
+	}
+}
+
+// TestIntegrationMultipart tests a multipart form with fields and a file
+func TestIntegrationMultipart(t *testing.T) {
+	t.Log("Sending multipart form... (expected fields and file)")
+
+	var fields, file string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fields = fmt.Sprint(r.MultipartForm.Value)
+		if f, h, err := r.FormFile("report"); err == nil {
+			data, _ := ioutil.ReadAll(f)
+			file = h.Filename + ": " + string(data)
+		}
+	}))
+	defer ts.Close()
+
+	for _, opts := range [][]Option{nil, {WithBufferedUploads()}} {
+		fields, file = "", ""
+		resp, err := NewRequest(opts...).
+			MultipartFormData(map[string]string{"team": "core"}).
+			UploadFromReader(MultipartParam{FieldName: "report", FileName: "report.csv", FileBody: strings.NewReader("a,b")}).
+			Post(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if resp.GetStatusCode() != 200 || fields != "map[team:[core]]" || file != "report.csv: a,b" {
+			t.Error(
+				"For", "multipart", len(opts),
+				"expected", "team field and report.csv",
+				"got", resp.GetStatusCode(), fields, file,
+			)
+		}
+	}
+}
+
+// TestIntegrationContextCancel tests a request is abandoned when its
+// context is done
+func TestIntegrationContextCancel(t *testing.T) {
+	t.Log("Sending request with short deadline to slow server... (expected deadline exceeded)")
+
+	ts := newInspectServer(t, false)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := NewRequest().SetContext(ctx).Get(ts.URL + "/slow")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > 5*time.Second {
+		t.Error(
+			"For", "deadline",
+			"expected", context.DeadlineExceeded,
+			"got", err, time.Since(start),
+		)
+	}
+}
+
+// TestIntegrationRedirects tests the method and body kept through
+// redirects
+func TestIntegrationRedirects(t *testing.T) {
+	t.Log("Sending POST requests to redirects... (expected GET for 301, POST with body for 307 and 308)")
+
+	ts := newInspectServer(t, false)
+	inspect := inspector(t)
+
+	tests := []struct {
+		code   int
+		method string
+		body   string
+	}{
+		{http.StatusMovedPermanently, "GET", ""},
+		{http.StatusTemporaryRedirect, "POST", "hello"},
+		{http.StatusPermanentRedirect, "POST", "hello"},
+	}
+
+	for _, tt := range tests {
+		in := inspect(NewRequest().Text("hello").Post(fmt.Sprintf("%s/redirect/%d", ts.URL, tt.code)))
+		if in.Path != "/target" || in.Method != tt.method || in.Body != tt.body {
+			t.Error(
+				"For", tt.code,
+				"expected", "/target", tt.method, tt.body,
+				"got", in.Path, in.Method, in.Body,
+			)
+		}
+	}
+}
+
+// TestIntegrationHooks tests the hooks run in order around a request
+func TestIntegrationHooks(t *testing.T) {
+	t.Log("Sending request with hooks... (expected before and after hooks in order)")
+
+	ts := newInspectServer(t, false)
+	inspect := inspector(t)
+
+	var calls []string
+	req := NewRequest().
+		OnBeforeRequest(func(r *Request) error {
+			calls = append(calls, "before")
+			r.Query(map[string]string{"hooked": "yes"})
+			return nil
+		}).
+		OnBeforeHTTPRequest(func(r *http.Request) error {
+			calls = append(calls, "before http "+r.URL.RawQuery)
+			return nil
+		}).
+		OnAfterResponse(func(r *Request, resp *Response) error {
+			calls = append(calls, fmt.Sprint("after ", resp.GetStatusCode()))
+			return nil
+		})
+
+	in := inspect(req.Get(ts.URL))
+
+	expected := "[before before http hooked=yes after 200]"
+	if fmt.Sprint(calls) != expected || in.Query.Get("hooked") != "yes" {
+		t.Error(
+			"For", "hooks",
+			"expected", expected,
+			"got", calls, in.Query,
+		)
+	}
+}
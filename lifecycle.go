@@ -0,0 +1,185 @@
+package gohttp
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"net"
+	"net/http"
+	"reflect"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// PoolStats are connection counters of the client created by a Request.
+// Closed is only counted when an OnIdleConnClosed hook is registered.
+type PoolStats struct {
+	Dials         int64
+	Reused        int64
+	TLSHandshakes int64
+	Closed        int64
+}
+
+// poolCounters are the atomically updated counters behind PoolStats
+type poolCounters struct {
+	dials         int64
+	reused        int64
+	tlsHandshakes int64
+	closed        int64
+}
+
+func (c *poolCounters) snapshot() PoolStats {
+	return PoolStats{
+		Dials:         atomic.LoadInt64(&c.dials),
+		Reused:        atomic.LoadInt64(&c.reused),
+		TLSHandshakes: atomic.LoadInt64(&c.tlsHandshakes),
+		Closed:        atomic.LoadInt64(&c.closed),
+	}
+}
+
+// OnClientCreated registers a hook executed once the http client and its
+// transport are constructed, which happens on the first send. It is not
+// executed for a client given with SetClient.
+func (req *Request) OnClientCreated(hook ClientCreatedHook) *Request {
+	req.registerHook(func(h *hookSet) {
+		h.clientCreatedHooks = append(h.clientCreatedHooks, hook)
+	})
+	return req
+}
+
+// OnIdleConnClosed registers a hook executed whenever a connection of the
+// client is closed, e.g. when the pool drops an idle connection. It must be
+// registered before the first send since the connections are tracked by the
+// dialer of the transport.
+func (req *Request) OnIdleConnClosed(hook ConnClosedHook) *Request {
+	req.registerHook(func(h *hookSet) {
+		h.connClosedHooks = append(h.connClosedHooks, hook)
+	})
+	return req
+}
+
+// PoolStats returns the connection counters of the client, poll it from a
+// ticker for periodic reporting
+func (req *Request) PoolStats() PoolStats {
+	return req.state.pool.snapshot()
+}
+
+// trackingDialer wraps dial so closed connections are counted and reported
+// to the OnIdleConnClosed hooks
+func (req *Request) trackingDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error)) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		conn, err := dial(ctx, network, addr)
+		if err != nil {
+			return nil, err
+		}
+		return &trackedConn{Conn: conn, req: req}, nil
+	}
+}
+
+// trackedConn reports its close to the request it was dialed for
+type trackedConn struct {
+	net.Conn
+	req  *Request
+	once sync.Once
+}
+
+func (c *trackedConn) Close() error {
+	err := c.Conn.Close()
+	c.once.Do(func() {
+		atomic.AddInt64(&c.req.state.pool.closed, 1)
+		c.req.hooks().executeConnClosed(c.req.PoolStats())
+	})
+	return err
+}
+
+// Description is a static description of the client used by a Request
+type Description struct {
+	Timeout               time.Duration
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	IdleConnTimeout       time.Duration
+	TLSHandshakeTimeout   time.Duration
+	ResponseHeaderTimeout time.Duration
+	TLS                   string
+	Proxy                 string
+	DevShaping            bool
+}
+
+func (d Description) String() string {
+	return fmt.Sprintf("timeout=%s max_idle_conns=%d max_idle_conns_per_host=%d max_conns_per_host=%d idle_conn_timeout=%s tls_handshake_timeout=%s response_header_timeout=%s tls=%s proxy=%s dev_shaping=%t",
+		d.Timeout, d.MaxIdleConns, d.MaxIdleConnsPerHost, d.MaxConnsPerHost, d.IdleConnTimeout,
+		d.TLSHandshakeTimeout, d.ResponseHeaderTimeout, d.TLS, d.Proxy, d.DevShaping)
+}
+
+// Describe returns a description of the client the request is sent with,
+// suitable for a startup log line. TLS is one of default, custom or
+// insecure, Proxy one of none, environment or custom.
+func (req *Request) Describe() Description {
+	req.state.mu.Lock()
+	client, built := req.client, req.state.transport
+	req.state.mu.Unlock()
+
+	var rt http.RoundTripper
+	d := Description{Timeout: req.timeout, DevShaping: req.devShapingActive()}
+	switch {
+	case built != nil:
+		rt = built
+		d.Timeout = client.Timeout
+	case client != nil:
+		rt = client.Transport
+		d.Timeout = client.Timeout
+	default:
+		rt = req.baseTransport()
+	}
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+
+	tr, ok := rt.(*http.Transport)
+	if !ok {
+		d.TLS, d.Proxy = "custom", "custom"
+		return d
+	}
+
+	d.MaxIdleConns = tr.MaxIdleConns
+	d.MaxIdleConnsPerHost = tr.MaxIdleConnsPerHost
+	d.MaxConnsPerHost = tr.MaxConnsPerHost
+	d.IdleConnTimeout = tr.IdleConnTimeout
+	d.TLSHandshakeTimeout = tr.TLSHandshakeTimeout
+	d.ResponseHeaderTimeout = tr.ResponseHeaderTimeout
+
+	d.TLS = describeTLS(tr.TLSClientConfig)
+
+	switch {
+	case tr.Proxy == nil:
+		d.Proxy = "none"
+	case reflect.ValueOf(tr.Proxy).Pointer() == reflect.ValueOf(http.ProxyFromEnvironment).Pointer():
+		d.Proxy = "environment"
+	default:
+		d.Proxy = "custom"
+	}
+
+	return d
+}
+
+// describeTLS tells whether cfg changes how servers are verified or how the
+// client authenticates
+func describeTLS(cfg *tls.Config) string {
+	switch {
+	case cfg == nil:
+		return "default"
+	case cfg.InsecureSkipVerify:
+		return "insecure"
+	case cfg.RootCAs != nil, len(cfg.Certificates) > 0, cfg.GetClientCertificate != nil,
+		cfg.VerifyPeerCertificate != nil, cfg.VerifyConnection != nil:
+		return "custom"
+	default:
+		return "default"
+	}
+}
@@ -0,0 +1,94 @@
+package gohttp
+
+import (
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// TestClientLifecycleHooks tests OnClientCreated and OnIdleConnClosed hooks
+func TestClientLifecycleHooks(t *testing.T) {
+	t.Log("Sending two GET requests and closing idle connections... (expected hooks and counters)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer ts.Close()
+
+	var clients []*http.Client
+	var closed []PoolStats
+	req := NewRequest().
+		OnClientCreated(func(c *http.Client) {
+			clients = append(clients, c)
+		}).
+		OnIdleConnClosed(func(stats PoolStats) {
+			closed = append(closed, stats)
+		})
+
+	for i := 0; i < 2; i++ {
+		resp, err := req.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.GetBodyAsByte()
+	}
+
+	if len(clients) != 1 {
+		t.Fatal(
+			"For", "OnClientCreated",
+			"expected", 1,
+			"got", len(clients),
+		)
+	}
+
+	clients[0].CloseIdleConnections()
+
+	stats := req.PoolStats()
+	if len(closed) != 1 || stats != (PoolStats{Dials: 1, Reused: 1, Closed: 1}) {
+		t.Error(
+			"For", "OnIdleConnClosed",
+			"expected", PoolStats{Dials: 1, Reused: 1, Closed: 1},
+			"got", closed, stats,
+		)
+	}
+}
+
+// TestDescribe tests Describe for a configured client
+func TestDescribe(t *testing.T) {
+	t.Log("Describing configured request... (expected transport settings)")
+
+	tr := &http.Transport{
+		MaxIdleConns:        7,
+		MaxIdleConnsPerHost: 3,
+		IdleConnTimeout:     time.Minute,
+		TLSClientConfig:     &tls.Config{InsecureSkipVerify: true},
+	}
+
+	d := NewRequest(SetTransport(tr), WithProxy("http://proxy:3128"), SetTimeout(3*time.Second)).Describe()
+	expected := Description{
+		Timeout:             3 * time.Second,
+		MaxIdleConns:        7,
+		MaxIdleConnsPerHost: 3,
+		IdleConnTimeout:     time.Minute,
+		TLS:                 "insecure",
+		Proxy:               "custom",
+	}
+	if d != expected {
+		t.Error(
+			"For", "Describe",
+			"expected", expected,
+			"got", d,
+		)
+	}
+
+	d = NewRequest().Describe()
+	if d.TLS != "default" || d.Proxy != "environment" || d.MaxIdleConns != 100 {
+		t.Error(
+			"For", "Describe",
+			"expected", "default transport",
+			"got", d.String(),
+		)
+	}
+}
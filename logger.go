@@ -0,0 +1,36 @@
+package gohttp
+
+import (
+	"fmt"
+	"os"
+	"sync"
+)
+
+// Logger logs warnings of a Request, a *log.Logger satisfies it
+type Logger interface {
+	Printf(format string, v ...interface{})
+}
+
+// WithLogger option logs warnings of the request to l instead of os.Stderr
+func WithLogger(l Logger) OptionFunc {
+	return func(r *Request) {
+		r.logger = l
+	}
+}
+
+// stderrWarnings are the warnings written to os.Stderr already, each is
+// only written once per process when no Logger is set
+var stderrWarnings sync.Map
+
+// warnf logs a warning to the logger of the request, without one it is
+// written to os.Stderr the first time
+func (req *Request) warnf(format string, v ...interface{}) {
+	msg := fmt.Sprintf(format, v...)
+	if req.logger != nil {
+		req.logger.Printf("%s", msg)
+		return
+	}
+	if _, loaded := stderrWarnings.LoadOrStore(msg, true); !loaded {
+		fmt.Fprintln(os.Stderr, msg)
+	}
+}
@@ -0,0 +1,67 @@
+package gohttp
+
+import (
+	"net/http"
+	"reflect"
+	"sync"
+)
+
+// Middleware wraps a http.RoundTripper, e.g. to instrument or modify every
+// request sent through it
+type Middleware func(http.RoundTripper) http.RoundTripper
+
+// RoundTripperFunc is an adapter to use an ordinary function as a
+// http.RoundTripper
+type RoundTripperFunc func(*http.Request) (*http.Response, error)
+
+// RoundTrip calls fn(r)
+func (fn RoundTripperFunc) RoundTrip(r *http.Request) (*http.Response, error) {
+	return fn(r)
+}
+
+var globalMiddlewares struct {
+	sync.RWMutex
+	list []Middleware
+}
+
+// RegisterGlobalMiddleware registers mw for every client created by this
+// package from now on, clients which already exist are not affected.
+// Middlewares see a request in registration order, global middlewares see
+// it before the ones given with WithMiddleware.
+func RegisterGlobalMiddleware(mw func(http.RoundTripper) http.RoundTripper) {
+	globalMiddlewares.Lock()
+	defer globalMiddlewares.Unlock()
+
+	globalMiddlewares.list = append(globalMiddlewares.list, mw)
+}
+
+// UnregisterGlobalMiddleware removes the latest registration of mw. Go
+// can't tell apart closures created by the same function literal, those
+// are treated as the same middleware.
+func UnregisterGlobalMiddleware(mw func(http.RoundTripper) http.RoundTripper) {
+	globalMiddlewares.Lock()
+	defer globalMiddlewares.Unlock()
+
+	ptr := reflect.ValueOf(mw).Pointer()
+	list := globalMiddlewares.list
+	for i := len(list) - 1; i >= 0; i-- {
+		if reflect.ValueOf(list[i]).Pointer() == ptr {
+			globalMiddlewares.list = append(list[:i:i], list[i+1:]...)
+			return
+		}
+	}
+}
+
+// wrapMiddlewares wraps rt with the global middlewares and then the request
+// middlewares, so the first registered one is the outermost
+func (req *Request) wrapMiddlewares(rt http.RoundTripper) http.RoundTripper {
+	globalMiddlewares.RLock()
+	mws := append([]Middleware(nil), globalMiddlewares.list...)
+	globalMiddlewares.RUnlock()
+
+	mws = append(mws, req.middlewares...)
+	for i := len(mws) - 1; i >= 0; i-- {
+		rt = mws[i](rt)
+	}
+	return rt
+}
@@ -0,0 +1,56 @@
+package gohttp
+
+import (
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// tagMiddleware returns a middleware appending tag to the X-Trail header
+func tagMiddleware(tag string) func(http.RoundTripper) http.RoundTripper {
+	return func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r.Header.Set("X-Trail", strings.TrimPrefix(r.Header.Get("X-Trail")+","+tag, ","))
+			return next.RoundTrip(r)
+		})
+	}
+}
+
+func globalTag(next http.RoundTripper) http.RoundTripper {
+	return tagMiddleware("global")(next)
+}
+
+// TestGlobalMiddleware tests RegisterGlobalMiddleware and WithMiddleware
+func TestGlobalMiddleware(t *testing.T) {
+	t.Log("Sending GET request with middlewares... (expected middleware order)")
+
+	ts := newHeaderServer(t, "X-Trail")
+
+	RegisterGlobalMiddleware(globalTag)
+
+	resp, err := NewRequest(WithMiddleware(tagMiddleware("first")), WithMiddleware(tagMiddleware("second"))).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := resp.GetBodyAsString(); body != "global,first,second" {
+		t.Error(
+			"For", "RegisterGlobalMiddleware",
+			"expected", "global,first,second",
+			"got", body,
+		)
+	}
+
+	UnregisterGlobalMiddleware(globalTag)
+
+	resp, err = NewRequest().Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := resp.GetBodyAsString(); body != "" {
+		t.Error(
+			"For", "UnregisterGlobalMiddleware",
+			"expected", "",
+			"got", body,
+		)
+	}
+}
@@ -0,0 +1,162 @@
+package gohttp
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// MockTransport is an http.RoundTripper answering requests with registered
+// responses, for tests which don't need a server. Use it with WithTransport.
+type MockTransport struct {
+	mu    sync.Mutex
+	mocks []*mockResponse
+}
+
+type mockResponse struct {
+	method  string
+	pattern string
+	// expected is set for responses registered with RegisterRequest
+	expected *http.Request
+	opts     DiffOptions
+	resp     *http.Response
+	used     bool
+}
+
+// NewMockTransport returns a MockTransport without responses
+func NewMockTransport() *MockTransport {
+	return &MockTransport{}
+}
+
+// Register adds resp as the response to one request with method to a URL
+// matching urlPattern, either the URL itself or a pattern in the syntax of
+// path.Match, e.g. "https://api.example.com/users/*". Registering a pattern
+// again queues another response for the next matching request.
+func (m *MockTransport) Register(method, urlPattern string, resp *http.Response) *MockTransport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mocks = append(m.mocks, &mockResponse{
+		method:  strings.ToUpper(method),
+		pattern: urlPattern,
+		resp:    resp,
+	})
+	return m
+}
+
+// RegisterRequest adds resp as the response to one request without
+// differences to expected, see DiffRequests
+func (m *MockTransport) RegisterRequest(expected *http.Request, resp *http.Response, opts DiffOptions) *MockTransport {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	m.mocks = append(m.mocks, &mockResponse{
+		method:   expected.Method,
+		pattern:  expected.URL.String(),
+		expected: expected,
+		opts:     opts,
+		resp:     resp,
+	})
+	return m
+}
+
+// RoundTrip answers r with the first unused response registered for it.
+// Without one the error lists the differences to the nearest unused
+// response. An error reading the body of r is returned as it is.
+func (m *MockTransport) RoundTrip(r *http.Request) (*http.Response, error) {
+	orig := r
+	if r.Body != nil && r.Body != http.NoBody {
+		// the body is compared on a copy, a RoundTripper must not modify r
+		data, err := ioutil.ReadAll(r.Body)
+		r.Body.Close()
+		if err != nil {
+			return nil, err
+		}
+		r = r.Clone(r.Context())
+		r.Body = ioutil.NopCloser(bytes.NewReader(data))
+		r.GetBody = func() (io.ReadCloser, error) {
+			return ioutil.NopCloser(bytes.NewReader(data)), nil
+		}
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	u := r.URL.String()
+	var nearest []Difference
+	for _, mock := range m.mocks {
+		if mock.used {
+			continue
+		}
+		if diffs := mock.diff(r, u); len(diffs) > 0 {
+			if nearest == nil || len(diffs) < len(nearest) {
+				nearest = diffs
+			}
+			continue
+		}
+		mock.used = true
+
+		resp := *mock.resp
+		resp.Request = orig
+		if resp.Body == nil {
+			resp.Body = http.NoBody
+		}
+		if resp.Header == nil {
+			resp.Header = http.Header{}
+		}
+		return &resp, nil
+	}
+
+	if nearest != nil {
+		lines := make([]string, len(nearest))
+		for i, diff := range nearest {
+			lines[i] = "\t" + diff.String()
+		}
+		return nil, fmt.Errorf("gohttp: no mock response registered for %s %s, the nearest one differs:\n%s", r.Method, u, strings.Join(lines, "\n"))
+	}
+	return nil, fmt.Errorf("gohttp: no mock response registered for %s %s", r.Method, u)
+}
+
+// AssertExpectations fails t for every registered response no request used
+func (m *MockTransport) AssertExpectations(t testing.TB) {
+	t.Helper()
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, mock := range m.mocks {
+		if !mock.used {
+			t.Errorf("gohttp: mock response for %s %s was not used", mock.method, mock.pattern)
+		}
+	}
+}
+
+// diff returns the differences of r with URL u to the request mock answers
+func (mock *mockResponse) diff(r *http.Request, u string) []Difference {
+	if mock.expected != nil {
+		return DiffRequests(mock.expected, r, mock.opts)
+	}
+
+	var diffs []Difference
+	if mock.method != r.Method {
+		diffs = append(diffs, Difference{Path: "method", Expected: mock.method, Actual: r.Method})
+	}
+	if !mock.matches(u) {
+		diffs = append(diffs, Difference{Path: "url", Expected: mock.pattern, Actual: u})
+	}
+	return diffs
+}
+
+func (mock *mockResponse) matches(u string) bool {
+	if mock.pattern == u {
+		return true
+	}
+	ok, err := path.Match(mock.pattern, u)
+	return err == nil && ok
+}
@@ -0,0 +1,135 @@
+package gohttp
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// recordingTB records the errors reported to it
+type recordingTB struct {
+	testing.TB
+	errors []string
+}
+
+func (r *recordingTB) Helper() {}
+
+func (r *recordingTB) Errorf(format string, args ...interface{}) {
+	r.errors = append(r.errors, fmt.Sprintf(format, args...))
+}
+
+func mockResponseWith(status int, body string) *http.Response {
+	return &http.Response{
+		StatusCode: status,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}
+}
+
+// TestMockTransport tests registered responses answer matching requests once
+func TestMockTransport(t *testing.T) {
+	t.Log("Sending requests through mock transport... (expected registered responses)")
+
+	mock := NewMockTransport().
+		Register(http.MethodGet, "https://api.example.com/users/*", mockResponseWith(200, "user")).
+		Register(http.MethodPost, "https://api.example.com/users", mockResponseWith(201, "created")).
+		Register(http.MethodGet, "https://api.example.com/users/*", mockResponseWith(404, "gone"))
+
+	tests := []struct {
+		method   string
+		url      string
+		status   int
+		expected string
+	}{
+		{http.MethodGet, "https://api.example.com/users/7", 200, "user"},
+		{http.MethodPost, "https://api.example.com/users", 201, "created"},
+		{http.MethodGet, "https://api.example.com/users/7", 404, "gone"},
+	}
+
+	for _, tt := range tests {
+		resp, err := NewRequest(WithTransport(mock)).Do(tt.method, tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if body, _ := resp.GetBodyAsString(); resp.GetStatusCode() != tt.status || body != tt.expected {
+			t.Error(
+				"For", tt.method, tt.url,
+				"expected", tt.status, tt.expected,
+				"got", resp.GetStatusCode(), body,
+			)
+		}
+	}
+
+	if _, err := NewRequest(WithTransport(mock)).Get("https://api.example.com/users/7"); err == nil ||
+		!strings.Contains(err.Error(), "no mock response registered for GET https://api.example.com/users/7") {
+		t.Error(
+			"For", "unregistered request",
+			"expected", "descriptive error",
+			"got", err,
+		)
+	}
+
+	mock.AssertExpectations(t)
+}
+
+// TestMockTransportAssertExpectations tests unused responses fail the test
+func TestMockTransportAssertExpectations(t *testing.T) {
+	t.Log("Asserting unused mock responses... (expected one error)")
+
+	mock := NewMockTransport().
+		Register(http.MethodGet, "https://example.com/a", mockResponseWith(200, "")).
+		Register(http.MethodDelete, "https://example.com/b", mockResponseWith(204, ""))
+
+	if _, err := NewRequest(WithTransport(mock)).Get("https://example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	tb := &recordingTB{TB: t}
+	mock.AssertExpectations(tb)
+
+	if len(tb.errors) != 1 || !strings.Contains(tb.errors[0], "DELETE https://example.com/b") {
+		t.Error(
+			"For", "AssertExpectations",
+			"expected", "one unused response",
+			"got", tb.errors,
+		)
+	}
+}
+
+// TestMockTransportRequestBody tests the request body is read without
+// modifying the request and a read error is returned
+func TestMockTransportRequestBody(t *testing.T) {
+	t.Log("Sending requests with bodies to a mock transport... (expected the request unchanged, read errors returned)")
+
+	expected := newDiffRequest("POST", "http://example.com/users", "xxxx", "Content-Type", "text/plain")
+	mock := NewMockTransport().
+		RegisterRequest(expected, mockResponseWith(201, "created"), DiffOptions{}).
+		RegisterRequest(expected, mockResponseWith(201, "created"), DiffOptions{})
+
+	body := ioutil.NopCloser(strings.NewReader("xxxx"))
+	r, _ := http.NewRequest("POST", "http://example.com/users", body)
+	r.Header.Set("Content-Type", "text/plain")
+	resp, err := mock.RoundTrip(r)
+	if err != nil || resp.StatusCode != 201 || r.Body != body || resp.Request != r {
+		t.Error(
+			"For", "matching request",
+			"expected", "201 with the request unchanged",
+			"got", resp, err,
+		)
+	}
+
+	boom := errors.New("boom")
+	body = ioutil.NopCloser(&failingReader{n: 2, err: boom})
+	r, _ = http.NewRequest("POST", "http://example.com/users", body)
+	r.Header.Set("Content-Type", "text/plain")
+	if _, err := mock.RoundTrip(r); !errors.Is(err, boom) || r.Body != body {
+		t.Error(
+			"For", "failing body",
+			"expected", boom,
+			"got", err,
+		)
+	}
+}
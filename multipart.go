@@ -0,0 +1,302 @@
+package gohttp
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"io"
+	"net/textproto"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+)
+
+// ErrBodyNotReplayable is returned when a request body which can only be
+// read once has to be sent again, e.g. for a retry or a 307 redirect
+var ErrBodyNotReplayable = errors.New("gohttp: request body can't be sent again")
+
+// defaultFileContentType is the Content-Type of the files of
+// UploadFromReader, like multipart.Writer sets it
+const defaultFileContentType = "application/octet-stream"
+
+// multipartPart is a field or a file of a multipart body
+type multipartPart struct {
+	fieldName string
+	fileName  string
+	file      bool
+	// contentType is the Content-Type of the part, empty for a plain field
+	contentType string
+	// header are the other headers of the part
+	header textproto.MIMEHeader
+	value  string
+	// r is the reader of UploadFromReader, it can only be read once, by
+	// the copy of the request claiming read first
+	r    io.Reader
+	read *int32
+	// path is the file of Upload, it is opened for every attempt
+	path string
+}
+
+// multipartStream writes a multipart body when the request is sent, the
+// files are read while the transport sends them, through the pipe of
+// writerToBody. Its parts are never modified, a new one is built when
+// parts are added.
+type multipartStream struct {
+	boundary string
+	parts    []multipartPart
+	// owner is the request which may append to the backing array of
+	// parts, copies of the request copy the parts before adding some
+	owner *Request
+}
+
+// partHeaderPool holds the buffers the part headers of streamed bodies are
+// formatted in
+var partHeaderPool = sync.Pool{
+	New: func() interface{} {
+		return new(bytes.Buffer)
+	},
+}
+
+// maxPooledPartHeader is the capacity above which a part header buffer,
+// grown by a large field value, is not pooled
+const maxPooledPartHeader = 64 << 10
+
+// WriteTo writes the multipart body to w, it fails with
+// ErrBodyNotReplayable when a reader of a part was read already
+func (s *multipartStream) WriteTo(w io.Writer) (int64, error) {
+	for _, part := range s.parts {
+		if part.r != nil && atomic.SwapInt32(part.read, 1) == 1 {
+			return 0, ErrBodyNotReplayable
+		}
+	}
+
+	buf := partHeaderPool.Get().(*bytes.Buffer)
+	defer func() {
+		if buf.Cap() <= maxPooledPartHeader {
+			buf.Reset()
+			partHeaderPool.Put(buf)
+		}
+	}()
+
+	cw := &countingWriter{w: w}
+	for i := range s.parts {
+		part := &s.parts[i]
+		buf.Reset()
+		writePartHeader(buf, s.boundary, i == 0, part)
+		if !part.file {
+			buf.WriteString(part.value)
+		}
+		if _, err := cw.Write(buf.Bytes()); err != nil {
+			return cw.n, err
+		}
+
+		var err error
+		switch {
+		case part.path != "":
+			err = copyFile(cw, part.path)
+		case part.r != nil:
+			_, err = io.Copy(cw, part.r)
+		}
+		if err != nil {
+			return cw.n, err
+		}
+	}
+
+	buf.Reset()
+	writeClosingBoundary(buf, s.boundary, len(s.parts) == 0)
+	_, err := cw.Write(buf.Bytes())
+	return cw.n, err
+}
+
+// contentLength returns the length of the body WriteTo writes, -1 when it
+// is unknown because a reader of a part doesn't tell its length
+func (s *multipartStream) contentLength() int64 {
+	buf := partHeaderPool.Get().(*bytes.Buffer)
+	defer func() {
+		buf.Reset()
+		partHeaderPool.Put(buf)
+	}()
+
+	var n int64
+	for i := range s.parts {
+		part := &s.parts[i]
+		buf.Reset()
+		writePartHeader(buf, s.boundary, i == 0, part)
+		n += int64(buf.Len())
+
+		switch r := part.r.(type) {
+		case nil:
+			if part.path == "" {
+				n += int64(len(part.value))
+				continue
+			}
+			info, err := os.Stat(part.path)
+			if err != nil {
+				return -1
+			}
+			n += info.Size()
+		case interface{ Len() int }:
+			n += int64(r.Len())
+		default:
+			return -1
+		}
+	}
+
+	buf.Reset()
+	writeClosingBoundary(buf, s.boundary, len(s.parts) == 0)
+	return n + int64(buf.Len())
+}
+
+// copyFile copies the file at path to w
+func copyFile(w io.Writer, path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, err = io.Copy(w, f)
+	return err
+}
+
+// countingWriter counts the bytes written to w
+type countingWriter struct {
+	w io.Writer
+	n int64
+}
+
+func (c *countingWriter) Write(b []byte) (int, error) {
+	n, err := c.w.Write(b)
+	c.n += int64(n)
+	return n, err
+}
+
+// newBoundary returns a random multipart boundary, like multipart.Writer
+// does
+func newBoundary() string {
+	var b [30]byte
+	if _, err := io.ReadFull(rand.Reader, b[:]); err != nil {
+		panic(err)
+	}
+	return hex.EncodeToString(b[:])
+}
+
+var quoteEscaper = strings.NewReplacer("\\", "\\\\", `"`, "\\\"")
+
+// writePartHeader writes the boundary and the headers starting part to
+// buf, the same ones multipart.Writer writes for a field or a file. The
+// other headers of the part follow the Content-Type, sorted.
+func writePartHeader(buf *bytes.Buffer, boundary string, first bool, part *multipartPart) {
+	if !first {
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("--")
+	buf.WriteString(boundary)
+	buf.WriteString("\r\nContent-Disposition: form-data; name=\"")
+	buf.WriteString(quoteEscaper.Replace(part.fieldName))
+	if part.file {
+		buf.WriteString("\"; filename=\"")
+		buf.WriteString(quoteEscaper.Replace(part.fileName))
+	}
+	buf.WriteString("\"\r\n")
+	if part.contentType != "" {
+		buf.WriteString("Content-Type: ")
+		buf.WriteString(part.contentType)
+		buf.WriteString("\r\n")
+	}
+	if len(part.header) > 0 {
+		keys := make([]string, 0, len(part.header))
+		for key := range part.header {
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			for _, val := range part.header[key] {
+				buf.WriteString(key)
+				buf.WriteString(": ")
+				buf.WriteString(val)
+				buf.WriteString("\r\n")
+			}
+		}
+	}
+	buf.WriteString("\r\n")
+}
+
+// writeClosingBoundary writes the boundary ending a multipart body to buf
+func writeClosingBoundary(buf *bytes.Buffer, boundary string, empty bool) {
+	if !empty {
+		buf.WriteString("\r\n")
+	}
+	buf.WriteString("--")
+	buf.WriteString(boundary)
+	buf.WriteString("--\r\n")
+}
+
+// startPart starts part in the buffered multipart body, its content is
+// written to multipartBuffer next
+func (req *Request) startPart(part *multipartPart) {
+	if req.boundary == "" {
+		req.boundary = newBoundary()
+	}
+	writePartHeader(&req.multipartBuffer, req.boundary, req.multipartBuffer.Len() == 0, part)
+
+	req.bodyWriterTo = nil
+	req.bodyReader = nil
+	req.contentType = multipartContentType(req.boundary)
+	req.formVals = &req.multipartBuffer
+}
+
+// addStreamedParts adds parts to the multipart body streamed at send time
+func (req *Request) addStreamedParts(parts ...multipartPart) {
+	next := &multipartStream{owner: req}
+	if prev := req.multipart; prev != nil {
+		next.boundary = prev.boundary
+		next.parts = prev.parts
+		if prev.owner != req {
+			next.parts = prev.parts[:len(prev.parts):len(prev.parts)]
+		}
+	} else if next.boundary = req.boundary; next.boundary == "" {
+		next.boundary = newBoundary()
+	}
+	next.parts = append(next.parts, parts...)
+
+	req.multipart = next
+	req.bodyWriterTo = next
+	req.bodyReader = nil
+	req.contentType = multipartContentType(next.boundary)
+}
+
+// multipartContentType returns the Content-Type of a multipart form with
+// boundary, quoted when needed like multipart.Writer does
+func multipartContentType(boundary string) string {
+	if strings.ContainsAny(boundary, `()<>@,;:\"/[]?= `) {
+		boundary = `"` + boundary + `"`
+	}
+	return "multipart/form-data; boundary=" + boundary
+}
+
+// partHeader returns the headers of header other than Content-Disposition
+// and Content-Type, which are written from the part, and the Content-Type
+// of header
+func partHeader(header textproto.MIMEHeader) (textproto.MIMEHeader, string) {
+	var other textproto.MIMEHeader
+	var contentType string
+	for key, vals := range header {
+		switch key = textproto.CanonicalMIMEHeaderKey(key); key {
+		case "Content-Disposition":
+		case "Content-Type":
+			if len(vals) > 0 {
+				contentType = vals[0]
+			}
+		default:
+			if other == nil {
+				other = textproto.MIMEHeader{}
+			}
+			other[key] = append(other[key], vals...)
+		}
+	}
+	return other, contentType
+}
@@ -0,0 +1,431 @@
+package gohttp
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// TestStreamingUploads tests files of a streamed upload are read on every
+// send and not kept open
+func TestStreamingUploads(t *testing.T) {
+	t.Log("Uploading files in streaming mode twice... (expected files received every time)")
+
+	var received []map[string]string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		got := map[string]string{"name": r.FormValue("name")}
+		for field := range r.MultipartForm.File {
+			f, _, _ := r.FormFile(field)
+			data, _ := ioutil.ReadAll(f)
+			f.Close()
+			got[field] = string(data)
+		}
+		received = append(received, got)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{}
+	for _, name := range []string{"a", "b", "c"} {
+		path := filepath.Join(dir, name+".txt")
+		if err := ioutil.WriteFile(path, []byte(strings.Repeat(name, 100)), 0644); err != nil {
+			t.Fatal(err)
+		}
+		files[name] = path
+	}
+
+	req := NewRequest().
+		MultipartFormData(map[string]string{"name": "report"}).
+		Uploads(files)
+	if req.formVals != nil {
+		t.Fatal("expected no buffered body")
+	}
+
+	if _, err := req.Post("http://127.0.0.1:0"); err == nil {
+		t.Fatal("expected error for failed send")
+	}
+	for i := 0; i < 2; i++ {
+		resp, err := req.Post(ts.URL)
+		if err != nil || resp.GetStatusCode() != http.StatusOK {
+			t.Fatal(resp, err)
+		}
+	}
+
+	expected := "[map[a:" + strings.Repeat("a", 100) + " b:" + strings.Repeat("b", 100) +
+		" c:" + strings.Repeat("c", 100) + " name:report]]"
+	for i, got := range received {
+		if fmt.Sprint([]map[string]string{got}) != expected {
+			t.Error(
+				"For", "send", i,
+				"expected", expected,
+				"got", got,
+			)
+		}
+	}
+}
+
+// failingReader returns err after n bytes
+type failingReader struct {
+	n   int
+	err error
+}
+
+func (r *failingReader) Read(p []byte) (int, error) {
+	if r.n == 0 {
+		return 0, r.err
+	}
+	if len(p) > r.n {
+		p = p[:r.n]
+	}
+	for i := range p {
+		p[i] = 'x'
+	}
+	r.n -= len(p)
+	return len(p), nil
+}
+
+// TestStreamingUploadReadError tests a read error in the middle of a
+// streamed upload fails the request and runs the OnError hooks
+func TestStreamingUploadReadError(t *testing.T) {
+	t.Log("Uploading file failing mid-stream... (expected read error from request and hook)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ioutil.ReadAll(r.Body)
+	}))
+	defer ts.Close()
+
+	errRead := errors.New("disk on fire")
+	var hookErr error
+	_, err := NewRequest().
+		OnError(func(r *Request, err error) { hookErr = err }).
+		UploadFromReader(MultipartParam{FieldName: "file", FileName: "big.bin", FileBody: &failingReader{n: 64 << 10, err: errRead}}).
+		Post(ts.URL)
+
+	if !errors.Is(err, errRead) || !errors.Is(hookErr, errRead) {
+		t.Error(
+			"For", "read error",
+			"expected", errRead,
+			"got", err, hookErr,
+		)
+	}
+}
+
+// TestRequestUploadFile tests the file name and the Content-Type of uploaded
+// files, streamed and buffered
+func TestRequestUploadFile(t *testing.T) {
+	t.Log("Uploading files... (expected base names and detected or given Content-Types)")
+
+	var got string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		h := r.MultipartForm.File["file"][0]
+		got = h.Filename + " " + h.Header.Get("Content-Type")
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	files := map[string]string{"report.json": `{"ok":true}`, "document": "%PDF-1.4 data"}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name     string
+		set      func(r *Request) *Request
+		expected string
+	}{
+		{
+			"extension",
+			func(r *Request) *Request { return r.UploadFile("file", filepath.Join(dir, "report.json"), "", "") },
+			"report.json application/json",
+		},
+		{
+			"sniffed",
+			func(r *Request) *Request { return r.UploadFile("file", filepath.Join(dir, "document"), "", "") },
+			"document application/pdf",
+		},
+		{
+			"overridden",
+			func(r *Request) *Request {
+				return r.UploadFile("file", filepath.Join(dir, "document"), "doc.json", "application/vnd.api+json")
+			},
+			"doc.json application/vnd.api+json",
+		},
+		{
+			"Upload",
+			func(r *Request) *Request { return r.Upload("file", filepath.Join(dir, "report.json")) },
+			"report.json application/json",
+		},
+	}
+
+	for _, opts := range [][]Option{nil, {WithBufferedUploads()}} {
+		for _, tt := range tests {
+			got = ""
+			if _, err := tt.set(NewRequest(opts...)).Post(ts.URL); err != nil {
+				t.Fatal(err)
+			}
+			if got != tt.expected {
+				t.Error(
+					"For", tt.name, len(opts),
+					"expected", tt.expected,
+					"got", got,
+				)
+			}
+		}
+	}
+
+	_, err := NewRequest().UploadFile("file", filepath.Join(dir, "missing"), "", "").Post(ts.URL)
+	if !errors.Is(err, os.ErrNotExist) {
+		t.Error(
+			"For", "missing file",
+			"expected", os.ErrNotExist,
+			"got", err,
+		)
+	}
+}
+
+// TestMultipartPartHeaders tests the Content-Type and the other headers of
+// parts are sent, streamed and buffered
+func TestMultipartPartHeaders(t *testing.T) {
+	t.Log("Sending multipart parts with headers... (expected part headers and contents)")
+
+	var got []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mr, err := r.MultipartReader()
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		for {
+			part, err := mr.NextPart()
+			if err != nil {
+				return
+			}
+			data, _ := ioutil.ReadAll(part)
+			got = append(got, fmt.Sprintf("%s %q %q %q %s", part.FormName(), part.FileName(),
+				part.Header.Get("Content-Type"), part.Header.Get("Content-Id"), data))
+		}
+	}))
+	defer ts.Close()
+
+	expected := []string{
+		`metadata "" "application/json" "" {"name":"logo"}`,
+		`image "logo.png" "image/png" "<logo@example.com>" png`,
+		`avatar "avatar.bin" "image/gif" "" gif`,
+		`raw "raw.bin" "application/octet-stream" "" raw`,
+	}
+	for _, opts := range [][]Option{nil, {WithBufferedUploads()}} {
+		got = nil
+		_, err := NewRequest(opts...).
+			MultipartField("metadata", `{"name":"logo"}`, "application/json").
+			UploadFromReader(MultipartParam{
+				FieldName:   "image",
+				FileName:    "logo.png",
+				FileBody:    strings.NewReader("png"),
+				ContentType: "image/png",
+				Header:      textproto.MIMEHeader{"Content-Id": {"<logo@example.com>"}, "Content-Type": {"text/plain"}},
+			}).
+			UploadFromReader(MultipartParam{
+				FieldName: "avatar",
+				FileName:  "avatar.bin",
+				FileBody:  strings.NewReader("gif"),
+				Header:    textproto.MIMEHeader{"Content-Type": {"image/gif"}},
+			}).
+			UploadFromReader(MultipartParam{FieldName: "raw", FileName: "raw.bin", FileBody: strings.NewReader("raw")}).
+			Post(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if fmt.Sprintf("%q", got) != fmt.Sprintf("%q", expected) {
+			t.Error(
+				"For", "parts", len(opts),
+				"expected", expected,
+				"got", got,
+			)
+		}
+	}
+}
+
+// multipartRequest returns a request with a multipart body of n parts,
+// alternately fields and 1 KiB files
+func multipartRequest(n int, opts ...Option) *Request {
+	file := make([]byte, 1024)
+	req := NewRequest(opts...)
+	for i := 0; i < n; i++ {
+		name := "part" + strconv.Itoa(i)
+		if i%2 == 0 {
+			req.UploadFromReader(MultipartParam{FieldName: name, FileName: name + ".jpg", FileBody: bytes.NewReader(file)})
+		} else {
+			req.MultipartFormData(map[string]string{name: "value"})
+		}
+	}
+	return req
+}
+
+// BenchmarkMultipartBody benchmarks building multipart bodies, buffered
+// and streamed
+func BenchmarkMultipartBody(b *testing.B) {
+	for _, n := range []int{1, 10, 100} {
+		b.Run(fmt.Sprintf("buffered/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				multipartRequest(n, WithBufferedUploads()).multipartBody()
+			}
+		})
+		b.Run(fmt.Sprintf("streamed/%d", n), func(b *testing.B) {
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				multipartRequest(n).multipart.WriteTo(ioutil.Discard)
+			}
+		})
+	}
+}
+
+// TestMultipartBodyFormat tests buffered and streamed multipart bodies are
+// written like multipart.Writer writes them
+func TestMultipartBodyFormat(t *testing.T) {
+	t.Log("Building multipart bodies... (expected same bytes as multipart.Writer)")
+
+	for _, opts := range [][]Option{nil, {WithBufferedUploads()}} {
+		req := NewRequest(opts...).
+			MultipartFormData(map[string]string{`na"me`: "gohttp"}).
+			UploadFromReader(MultipartParam{FieldName: "file", FileName: `a\b.txt`, FileBody: strings.NewReader("data")})
+
+		var body bytes.Buffer
+		boundary := req.boundary
+		if req.multipart != nil {
+			boundary = req.multipart.boundary
+			req.multipart.WriteTo(&body)
+		} else {
+			body.Write(req.multipartBody().Bytes())
+		}
+
+		var expected bytes.Buffer
+		w := multipart.NewWriter(&expected)
+		w.SetBoundary(boundary)
+		w.WriteField(`na"me`, "gohttp")
+		fw, _ := w.CreateFormFile("file", `a\b.txt`)
+		fw.Write([]byte("data"))
+		w.Close()
+
+		if body.String() != expected.String() || req.contentType != w.FormDataContentType() {
+			t.Error(
+				"For", "multipart", len(opts),
+				"expected", expected.String(), w.FormDataContentType(),
+				"got", body.String(), req.contentType,
+			)
+		}
+	}
+}
+
+// TestMultipartBoundary tests a custom boundary is used for the body and
+// the Content-Type, and invalid or late ones fail the request
+func TestMultipartBoundary(t *testing.T) {
+	t.Log("Sending multipart forms with custom boundaries... (expected fixed bodies or errors)")
+
+	var contentType, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		contentType, body = r.Header.Get("Content-Type"), string(data)
+	}))
+	defer ts.Close()
+
+	for _, boundary := range []string{"gohttp-fixed-boundary", "fixed boundary:1"} {
+		var expected bytes.Buffer
+		w := multipart.NewWriter(&expected)
+		w.SetBoundary(boundary)
+		w.WriteField("name", "gohttp")
+		w.Close()
+
+		for _, opts := range [][]Option{nil, {WithBufferedUploads()}} {
+			contentType, body = "", ""
+			_, err := NewRequest(opts...).MultipartBoundary(boundary).MultipartFormData(map[string]string{"name": "gohttp"}).Post(ts.URL)
+			if err != nil || body != expected.String() || contentType != w.FormDataContentType() {
+				t.Error(
+					"For", boundary, len(opts),
+					"expected", expected.String(), w.FormDataContentType(),
+					"got", body, contentType, err,
+				)
+			}
+		}
+	}
+
+	tests := []struct {
+		name string
+		req  *Request
+	}{
+		{"invalid character", NewRequest().MultipartBoundary("fixed*boundary")},
+		{"too long", NewRequest().MultipartBoundary(strings.Repeat("b", 71))},
+		{"empty", NewRequest().MultipartBoundary("")},
+		{"after parts", NewRequest().MultipartFormData(map[string]string{"name": "gohttp"}).MultipartBoundary("fixed")},
+		{"after buffered parts", NewRequest(WithBufferedUploads()).MultipartFormData(map[string]string{"name": "gohttp"}).MultipartBoundary("fixed")},
+	}
+
+	for _, tt := range tests {
+		body = ""
+		_, err := tt.req.MultipartFormData(map[string]string{"other": "value"}).Post(ts.URL)
+		if err == nil || !strings.Contains(err.Error(), "multipart boundary") || body != "" {
+			t.Error(
+				"For", tt.name,
+				"expected", "boundary error",
+				"got", err, body,
+			)
+		}
+	}
+}
+
+// TestMultipartAllocations tests the allocations of building multipart
+// bodies stay below ceilings, see BenchmarkMultipartBody
+func TestMultipartAllocations(t *testing.T) {
+	t.Log("Counting allocations of multipart bodies... (expected below ceilings)")
+
+	tests := []struct {
+		parts    int
+		streamed bool
+		ceiling  float64
+	}{
+		{1, false, 25},
+		{10, false, 60},
+		{100, false, 400},
+		{1, true, 20},
+		{10, true, 75},
+		{100, true, 550},
+	}
+
+	for _, tt := range tests {
+		allocs := testing.AllocsPerRun(20, func() {
+			if tt.streamed {
+				multipartRequest(tt.parts).multipart.WriteTo(ioutil.Discard)
+			} else {
+				multipartRequest(tt.parts, WithBufferedUploads()).multipartBody()
+			}
+		})
+		if allocs > tt.ceiling {
+			t.Error(
+				"For", tt.parts, "parts streamed", tt.streamed,
+				"expected at most", tt.ceiling,
+				"got", allocs,
+			)
+		}
+	}
+}
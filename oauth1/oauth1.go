@@ -0,0 +1,221 @@
+// Package oauth1 signs gohttp requests with OAuth 1.0a, as described in
+// RFC 5849, for APIs which still require it.
+package oauth1
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha1"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"hash"
+	"io/ioutil"
+	"mime"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/tenminschool/gohttp"
+)
+
+// Signature methods of OAuth1Config
+const (
+	HMACSHA1   = "HMAC-SHA1"
+	HMACSHA256 = "HMAC-SHA256"
+	PlainText  = "PLAINTEXT"
+)
+
+// OAuth1Config holds the credentials requests are signed with
+type OAuth1Config struct {
+	ConsumerKey    string
+	ConsumerSecret string
+	// Token and TokenSecret are empty when requesting a temporary token
+	Token       string
+	TokenSecret string
+	// SignatureMethod is HMACSHA1 when empty
+	SignatureMethod string
+	// Realm is sent in the Authorization header when set
+	Realm string
+}
+
+// nonce and now are replaced by tests for known signatures
+var (
+	nonce = randomNonce
+	now   = time.Now
+)
+
+// WithOAuth1 option signs every request with config and sets its
+// Authorization header. The signature covers the method, the URL, the
+// query and a form body. Requests are signed again for every retry and
+// redirect, it has no effect when a client is given with gohttp.SetClient.
+func WithOAuth1(config OAuth1Config) gohttp.OptionFunc {
+	return gohttp.WithMiddleware(func(next http.RoundTripper) http.RoundTripper {
+		return gohttp.RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r = r.Clone(r.Context())
+			if err := config.sign(r); err != nil {
+				if r.Body != nil {
+					r.Body.Close()
+				}
+				return nil, err
+			}
+			return next.RoundTrip(r)
+		})
+	})
+}
+
+// sign sets the Authorization header of r
+func (c OAuth1Config) sign(r *http.Request) error {
+	method := c.SignatureMethod
+	if method == "" {
+		method = HMACSHA1
+	}
+
+	params := map[string]string{
+		"oauth_consumer_key":     c.ConsumerKey,
+		"oauth_nonce":            nonce(),
+		"oauth_signature_method": method,
+		"oauth_timestamp":        strconv.FormatInt(now().Unix(), 10),
+		"oauth_version":          "1.0",
+	}
+	if c.Token != "" {
+		params["oauth_token"] = c.Token
+	}
+
+	form, err := formParams(r)
+	if err != nil {
+		return err
+	}
+
+	key := encode(c.ConsumerSecret) + "&" + encode(c.TokenSecret)
+	var signature string
+	switch method {
+	case HMACSHA1:
+		signature = hmacSignature(sha1.New, key, baseString(r, params, form))
+	case HMACSHA256:
+		signature = hmacSignature(sha256.New, key, baseString(r, params, form))
+	case PlainText:
+		signature = key
+	default:
+		return fmt.Errorf("oauth1: unsupported signature method %q", method)
+	}
+	params["oauth_signature"] = signature
+
+	r.Header.Set("Authorization", authorization(c.Realm, params))
+	return nil
+}
+
+// formParams returns the parameters of a form body of r, which is read
+// and replaced by a copy
+func formParams(r *http.Request) (url.Values, error) {
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if r.Body == nil || r.Body == http.NoBody || mediaType != "application/x-www-form-urlencoded" {
+		return nil, nil
+	}
+
+	body, err := ioutil.ReadAll(r.Body)
+	r.Body.Close()
+	if err != nil {
+		return nil, err
+	}
+	r.Body = ioutil.NopCloser(bytes.NewReader(body))
+	return url.ParseQuery(string(body))
+}
+
+// baseString returns the signature base string of r, see RFC 5849 section
+// 3.4.1
+func baseString(r *http.Request, oauth map[string]string, form url.Values) string {
+	var pairs [][2]string
+	add := func(key, val string) {
+		pairs = append(pairs, [2]string{encode(key), encode(val)})
+	}
+	for key, val := range oauth {
+		add(key, val)
+	}
+	for _, values := range []url.Values{r.URL.Query(), form} {
+		for key, vals := range values {
+			for _, val := range vals {
+				add(key, val)
+			}
+		}
+	}
+	sort.Slice(pairs, func(i, j int) bool {
+		if pairs[i][0] != pairs[j][0] {
+			return pairs[i][0] < pairs[j][0]
+		}
+		return pairs[i][1] < pairs[j][1]
+	})
+
+	normalized := make([]string, len(pairs))
+	for i, pair := range pairs {
+		normalized[i] = pair[0] + "=" + pair[1]
+	}
+
+	return strings.ToUpper(r.Method) + "&" + encode(baseURL(r.URL)) + "&" + encode(strings.Join(normalized, "&"))
+}
+
+// baseURL returns u without query and fragment, with a lowercase scheme
+// and host and without default port
+func baseURL(u *url.URL) string {
+	scheme, host := strings.ToLower(u.Scheme), strings.ToLower(u.Host)
+	if (scheme == "http" && strings.HasSuffix(host, ":80")) || (scheme == "https" && strings.HasSuffix(host, ":443")) {
+		host = host[:strings.LastIndexByte(host, ':')]
+	}
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	return scheme + "://" + host + path
+}
+
+func hmacSignature(h func() hash.Hash, key, base string) string {
+	mac := hmac.New(h, []byte(key))
+	mac.Write([]byte(base))
+	return base64.StdEncoding.EncodeToString(mac.Sum(nil))
+}
+
+// authorization returns the Authorization header with params sorted by
+// name
+func authorization(realm string, params map[string]string) string {
+	keys := make([]string, 0, len(params))
+	for key := range params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var parts []string
+	if realm != "" {
+		parts = append(parts, `realm="`+encode(realm)+`"`)
+	}
+	for _, key := range keys {
+		parts = append(parts, encode(key)+`="`+encode(params[key])+`"`)
+	}
+	return "OAuth " + strings.Join(parts, ", ")
+}
+
+// encode percent-encodes s as RFC 3986 requires, only unreserved
+// characters are kept
+func encode(s string) string {
+	var b strings.Builder
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if ('A' <= c && c <= 'Z') || ('a' <= c && c <= 'z') || ('0' <= c && c <= '9') ||
+			c == '-' || c == '.' || c == '_' || c == '~' {
+			b.WriteByte(c)
+			continue
+		}
+		fmt.Fprintf(&b, "%%%02X", c)
+	}
+	return b.String()
+}
+
+func randomNonce() string {
+	b := make([]byte, 16)
+	rand.Read(b)
+	return hex.EncodeToString(b)
+}
@@ -0,0 +1,118 @@
+package oauth1
+
+import (
+	"crypto/sha1"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/tenminschool/gohttp"
+)
+
+// twitterConfig holds the credentials of the signature example of the
+// Twitter API documentation
+var twitterConfig = OAuth1Config{
+	ConsumerKey:    "xvz1evFS4wEEPTGEFPHBog",
+	ConsumerSecret: "kAcSOqF21Fu85e7zjz7ZN2U4ZRhfV3WpwPAoE3Z7kBw",
+	Token:          "370773112-GmHxMAgYyLbNEtIKZeRNFsMKPR9EyMZeS9weJAEb",
+	TokenSecret:    "LswwdoUaIvS8ltyTt5jkRh4J50vUPVVHtR2YPi5kE",
+}
+
+// fixedNonce makes signatures reproducible for the test
+func fixedNonce(t *testing.T) {
+	nonce = func() string { return "kYjzVBB8Y0ZFabxSWbWovY3uYSQ2pTgmZeNu2VS4cg" }
+	now = func() time.Time { return time.Unix(1318622958, 0) }
+	t.Cleanup(func() {
+		nonce, now = randomNonce, time.Now
+	})
+}
+
+// TestSign tests the signature of the Twitter API documentation example
+func TestSign(t *testing.T) {
+	t.Log("Signing request... (expected known signature)")
+
+	fixedNonce(t)
+
+	form := url.Values{"status": {"Hello Ladies + Gentlemen, a signed OAuth request!"}}
+	r, _ := http.NewRequest("POST", "https://api.twitter.com/1.1/statuses/update.json?include_entities=true", strings.NewReader(form.Encode()))
+	r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if err := twitterConfig.sign(r); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := `OAuth oauth_consumer_key="xvz1evFS4wEEPTGEFPHBog", ` +
+		`oauth_nonce="kYjzVBB8Y0ZFabxSWbWovY3uYSQ2pTgmZeNu2VS4cg", ` +
+		`oauth_signature="hCtSmYh%2BiHYCEqBWrE7C7hYmtUk%3D", ` +
+		`oauth_signature_method="HMAC-SHA1", oauth_timestamp="1318622958", ` +
+		`oauth_token="370773112-GmHxMAgYyLbNEtIKZeRNFsMKPR9EyMZeS9weJAEb", oauth_version="1.0"`
+	body, _ := ioutil.ReadAll(r.Body)
+	if got := r.Header.Get("Authorization"); got != expected || string(body) != form.Encode() {
+		t.Error(
+			"For", "Authorization",
+			"expected", expected,
+			"got", got, string(body),
+		)
+	}
+}
+
+// TestSignatureRFC5849 tests the signature of the example of RFC 5849,
+// which has no oauth_version
+func TestSignatureRFC5849(t *testing.T) {
+	t.Log("Signing RFC 5849 example... (expected known signature)")
+
+	r, _ := http.NewRequest("GET", "http://photos.example.net/photos?file=vacation.jpg&size=original", nil)
+	params := map[string]string{
+		"oauth_consumer_key":     "dpf43f3p2l4k3l03",
+		"oauth_token":            "nnch734d00sl2jdk",
+		"oauth_signature_method": "HMAC-SHA1",
+		"oauth_timestamp":        "137131202",
+		"oauth_nonce":            "chapoH",
+	}
+
+	expected := "MdpQcU8iPSUjWoN/UDMsK2sui9I="
+	if got := hmacSignature(sha1.New, "kd94hf93k423kf44&pfkkdhi9sl3r4s00", baseString(r, params, nil)); got != expected {
+		t.Error(
+			"For", r.URL,
+			"expected", expected,
+			"got", got,
+		)
+	}
+}
+
+// TestWithOAuth1 tests requests sent with WithOAuth1 are signed and keep
+// their body
+func TestWithOAuth1(t *testing.T) {
+	t.Log("Sending signed request... (expected Authorization header and body)")
+
+	fixedNonce(t)
+
+	var auth, body string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := ioutil.ReadAll(r.Body)
+		auth, body = r.Header.Get("Authorization"), string(data)
+	}))
+	defer ts.Close()
+
+	config := twitterConfig
+	config.Realm = "api"
+	_, err := gohttp.NewRequest(WithOAuth1(config)).
+		FormData(map[string]string{"status": "hello"}).
+		Post(ts.URL + "/statuses/update.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasPrefix(auth, `OAuth realm="api", oauth_consumer_key="xvz1evFS4wEEPTGEFPHBog", `) ||
+		!strings.Contains(auth, "oauth_signature=") || body != "status=hello" {
+		t.Error(
+			"For", "WithOAuth1",
+			"expected", "signed request",
+			"got", auth, body,
+		)
+	}
+}
@@ -0,0 +1,195 @@
+package gohttp
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrOAuthToken is matched by the errors of OAuthTokenRequest, either an
+// *OAuthError or a token response which can't be used
+var ErrOAuthToken = errors.New("gohttp: OAuth token request failed")
+
+// Grant types of TokenRequest
+const (
+	GrantAuthorizationCode = "authorization_code"
+	GrantRefreshToken      = "refresh_token"
+	GrantClientCredentials = "client_credentials"
+)
+
+// TokenRequest is the form of an OAuth 2.0 token request, see
+// OAuthTokenRequest. The empty fields are not sent.
+type TokenRequest struct {
+	// GrantType defaults to authorization_code with a Code, refresh_token
+	// with a RefreshToken and client_credentials otherwise
+	GrantType   string
+	Code        string
+	RedirectURI string
+	// ClientID and ClientSecret authenticate the client with basic auth,
+	// or in the body when AuthInBody is set. A public client, without
+	// secret, always sends its ClientID in the body.
+	ClientID     string
+	ClientSecret string
+	AuthInBody   bool
+	// CodeVerifier is the PKCE code verifier of the authorization code
+	CodeVerifier string
+	RefreshToken string
+	Scopes       []string
+}
+
+// grantType returns the grant type of r
+func (r TokenRequest) grantType() string {
+	switch {
+	case r.GrantType != "":
+		return r.GrantType
+	case r.Code != "":
+		return GrantAuthorizationCode
+	case r.RefreshToken != "":
+		return GrantRefreshToken
+	}
+	return GrantClientCredentials
+}
+
+// form returns the form values of r
+func (r TokenRequest) form() map[string]string {
+	form := map[string]string{"grant_type": r.grantType()}
+	set := func(key, val string) {
+		if val != "" {
+			form[key] = val
+		}
+	}
+	set("code", r.Code)
+	set("redirect_uri", r.RedirectURI)
+	set("code_verifier", r.CodeVerifier)
+	set("refresh_token", r.RefreshToken)
+	set("scope", strings.Join(r.Scopes, " "))
+	if r.AuthInBody || r.ClientSecret == "" {
+		set("client_id", r.ClientID)
+		set("client_secret", r.ClientSecret)
+	}
+	return form
+}
+
+// Token is the access token of a successful token response. Expiry is zero
+// when the server gives no expires_in. Scopes is empty when the server
+// doesn't say them, i.e. they are the requested ones. Raw holds every
+// member of the response, e.g. an OpenID Connect id_token.
+type Token struct {
+	AccessToken  string
+	TokenType    string
+	RefreshToken string
+	Expiry       time.Time
+	Scopes       []string
+	Raw          map[string]interface{}
+}
+
+// Expired reports whether the token has an expiry which is passed
+func (t *Token) Expired() bool {
+	return !t.Expiry.IsZero() && !time.Now().Before(t.Expiry)
+}
+
+// OAuthError is the error response of a token endpoint, e.g. Code
+// invalid_grant for an expired authorization code
+type OAuthError struct {
+	StatusCode  int
+	Code        string
+	Description string
+	URI         string
+}
+
+func (e *OAuthError) Error() string {
+	msg := fmt.Sprintf("%v: %s", ErrOAuthToken, e.Code)
+	if e.Description != "" {
+		msg += ": " + e.Description
+	}
+	return msg
+}
+
+// Is reports whether target is ErrOAuthToken
+func (e *OAuthError) Is(target error) bool {
+	return target == ErrOAuthToken
+}
+
+// OAuthTokenRequest posts the form encoded token request tr to tokenURL as
+// described by RFC 6749, with the authorization code, PKCE, refresh token
+// or client credentials grant. Client may be nil to use a new request. The
+// token is returned for a successful response, an *OAuthError for an error
+// response and an error matching ErrOAuthToken for a malformed one.
+func OAuthTokenRequest(client *Client, tokenURL string, tr TokenRequest) (*Token, error) {
+	req := NewRequest()
+	if client != nil {
+		req = client.R()
+	}
+	if tr.ClientSecret != "" && !tr.AuthInBody {
+		// the credentials are form encoded before basic auth, RFC 6749 2.3.1
+		req.BasicAuth(url.QueryEscape(tr.ClientID), url.QueryEscape(tr.ClientSecret))
+	}
+	req.Headers(map[string]string{"Accept": "application/json"})
+
+	resp, err := req.FormData(tr.form()).Post(tokenURL)
+	if err != nil {
+		return nil, err
+	}
+	body, err := resp.GetBodyAsByte()
+	if err != nil {
+		return nil, err
+	}
+	return parseTokenResponse(resp.GetStatusCode(), body)
+}
+
+// parseTokenResponse returns the token of a token response with status
+// and body
+func parseTokenResponse(status int, body []byte) (*Token, error) {
+	var raw map[string]interface{}
+	if err := json.Unmarshal(body, &raw); err != nil {
+		return nil, fmt.Errorf("%w: malformed response with status %d: %q", ErrOAuthToken, status, snippet(body))
+	}
+	str := func(key string) string {
+		s, _ := raw[key].(string)
+		return s
+	}
+
+	if status < 200 || status > 299 {
+		if str("error") == "" {
+			return nil, fmt.Errorf("%w: status %d without error code: %q", ErrOAuthToken, status, snippet(body))
+		}
+		return nil, &OAuthError{
+			StatusCode:  status,
+			Code:        str("error"),
+			Description: str("error_description"),
+			URI:         str("error_uri"),
+		}
+	}
+
+	token := &Token{
+		AccessToken:  str("access_token"),
+		TokenType:    str("token_type"),
+		RefreshToken: str("refresh_token"),
+		Scopes:       strings.Fields(str("scope")),
+		Raw:          raw,
+	}
+	if token.AccessToken == "" || token.TokenType == "" {
+		return nil, fmt.Errorf("%w: response without access_token or token_type", ErrOAuthToken)
+	}
+
+	// some servers send expires_in as a string
+	var expiresIn float64
+	switch v := raw["expires_in"].(type) {
+	case float64:
+		expiresIn = v
+	case string:
+		n, err := strconv.ParseFloat(v, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid expires_in %q", ErrOAuthToken, v)
+		}
+		expiresIn = n
+	}
+	if expiresIn > 0 {
+		token.Expiry = time.Now().Add(time.Duration(expiresIn) * time.Second)
+	}
+	return token, nil
+}
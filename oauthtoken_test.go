@@ -0,0 +1,155 @@
+package gohttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+// newFakeIdP returns a token endpoint checking the client credentials and
+// the grants, it records the last form and basic auth it received
+func newFakeIdP(t *testing.T, form *url.Values, basic *string) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseForm(); err != nil || r.Header.Get("Content-Type") != "application/x-www-form-urlencoded" {
+			http.Error(w, "bad form", http.StatusBadRequest)
+			return
+		}
+		*form = r.PostForm
+		user, pass, _ := r.BasicAuth()
+		*basic = user + ":" + pass
+
+		w.Header().Set("Content-Type", "application/json")
+		switch r.URL.Path {
+		case "/html":
+			w.Header().Set("Content-Type", "text/html")
+			w.Write([]byte("<html>login</html>"))
+		case "/crash":
+			w.WriteHeader(http.StatusInternalServerError)
+			w.Write([]byte("oops"))
+		case "/no-token":
+			w.Write([]byte(`{"token_type":"Bearer"}`))
+		default:
+			if r.PostForm.Get("code") == "expired" || r.PostForm.Get("refresh_token") == "revoked" {
+				w.WriteHeader(http.StatusBadRequest)
+				w.Write([]byte(`{"error":"invalid_grant","error_description":"grant expired"}`))
+				return
+			}
+			w.Write([]byte(`{"access_token":"at","token_type":"Bearer","refresh_token":"rt","expires_in":"3600","scope":"read write","id_token":"it"}`))
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// TestOAuthTokenRequest tests the token requests sent for every grant and
+// the parsing of the token responses
+func TestOAuthTokenRequest(t *testing.T) {
+	t.Log("Requesting tokens from fake IdP... (expected encoded forms, tokens and errors)")
+
+	var form url.Values
+	var basic string
+	ts := newFakeIdP(t, &form, &basic)
+
+	tests := []struct {
+		name  string
+		path  string
+		req   TokenRequest
+		form  string
+		basic string
+		err   error
+	}{
+		{
+			"authorization code with PKCE",
+			"/token",
+			TokenRequest{Code: "c0de", RedirectURI: "https://app/cb", ClientID: "my app", ClientSecret: "s3:cret", CodeVerifier: "v3rifier"},
+			"code=c0de&code_verifier=v3rifier&grant_type=authorization_code&redirect_uri=https%3A%2F%2Fapp%2Fcb",
+			"my+app:s3%3Acret",
+			nil,
+		},
+		{
+			"refresh token in body",
+			"/token",
+			TokenRequest{RefreshToken: "rt", ClientID: "app", ClientSecret: "secret", AuthInBody: true, Scopes: []string{"read", "write"}},
+			"client_id=app&client_secret=secret&grant_type=refresh_token&refresh_token=rt&scope=read+write",
+			":",
+			nil,
+		},
+		{
+			"public client",
+			"/token",
+			TokenRequest{Code: "c0de", ClientID: "app", CodeVerifier: "v"},
+			"client_id=app&code=c0de&code_verifier=v&grant_type=authorization_code",
+			":",
+			nil,
+		},
+		{"client credentials", "/token", TokenRequest{ClientID: "app", ClientSecret: "secret"}, "grant_type=client_credentials", "app:secret", nil},
+		{"invalid grant", "/token", TokenRequest{Code: "expired"}, "code=expired&grant_type=authorization_code", ":", &OAuthError{}},
+		{"html", "/html", TokenRequest{}, "grant_type=client_credentials", ":", ErrOAuthToken},
+		{"server error", "/crash", TokenRequest{}, "grant_type=client_credentials", ":", ErrOAuthToken},
+		{"no access token", "/no-token", TokenRequest{}, "grant_type=client_credentials", ":", ErrOAuthToken},
+	}
+
+	for _, tt := range tests {
+		form, basic = nil, ""
+		token, err := OAuthTokenRequest(nil, ts.URL+tt.path, tt.req)
+		if form.Encode() != tt.form || basic != tt.basic {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.form, tt.basic,
+				"got", form.Encode(), basic,
+			)
+		}
+
+		if tt.err == nil {
+			if err != nil || token.AccessToken != "at" || token.TokenType != "Bearer" || token.RefreshToken != "rt" ||
+				fmt.Sprint(token.Scopes) != "[read write]" || token.Raw["id_token"] != "it" ||
+				time.Until(token.Expiry) < 59*time.Minute || token.Expired() {
+				t.Error(
+					"For", tt.name,
+					"expected", "token at expiring in an hour",
+					"got", token, err,
+				)
+			}
+			continue
+		}
+		if oauthErr := (*OAuthError)(nil); errors.As(tt.err, &oauthErr) {
+			if !errors.As(err, &oauthErr) || oauthErr.StatusCode != 400 || oauthErr.Code != "invalid_grant" || oauthErr.Description != "grant expired" {
+				t.Error(
+					"For", tt.name,
+					"expected", "invalid_grant",
+					"got", err,
+				)
+			}
+		}
+		if !errors.Is(err, ErrOAuthToken) || token != nil {
+			t.Error(
+				"For", tt.name,
+				"expected", ErrOAuthToken,
+				"got", token, err,
+			)
+		}
+	}
+}
+
+// TestOAuthTokenRequestClient tests the token request is sent with the
+// client defaults
+func TestOAuthTokenRequestClient(t *testing.T) {
+	t.Log("Requesting token with client and base URL... (expected token)")
+
+	var form url.Values
+	var basic string
+	ts := newFakeIdP(t, &form, &basic)
+
+	token, err := OAuthTokenRequest(NewClient(WithBaseURL(ts.URL)), "/token", TokenRequest{ClientID: "app", ClientSecret: "secret"})
+	if err != nil || token.AccessToken != "at" || basic != "app:secret" {
+		t.Error(
+			"For", "client",
+			"expected", "at", "app:secret",
+			"got", token, err, basic,
+		)
+	}
+}
@@ -1,7 +1,13 @@
 package gohttp
 
 import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
 	"net/http"
+	"net/http/cookiejar"
+	"net/url"
 	"time"
 )
 
@@ -17,6 +23,16 @@ func (fn OptionFunc) apply(r *Request) {
 	fn(r)
 }
 
+// CombineOptions returns an option applying opts in order, e.g. to keep
+// the options of an API together
+func CombineOptions(opts ...Option) OptionFunc {
+	return func(r *Request) {
+		for _, o := range opts {
+			o.apply(r)
+		}
+	}
+}
+
 // SetClient option sets client c for request
 func SetClient(c *http.Client) OptionFunc {
 	return func(r *Request) {
@@ -31,6 +47,15 @@ func SetTransport(t *http.Transport) OptionFunc {
 	}
 }
 
+// WithTransport option sends the request with t, e.g. a MockTransport.
+// Options configuring the transport, like WithProxy, have no effect, it is
+// still wrapped by middlewares.
+func WithTransport(t http.RoundTripper) OptionFunc {
+	return func(r *Request) {
+		r.roundTripper = t
+	}
+}
+
 // SetCookieJar option sets cookie c for request
 func SetCookieJar(c http.CookieJar) OptionFunc {
 	return func(r *Request) {
@@ -38,9 +63,352 @@ func SetCookieJar(c http.CookieJar) OptionFunc {
 	}
 }
 
+// WithCookieJar option stores the cookies of the responses in jar and
+// sends them with the requests, like SetCookieJar
+func WithCookieJar(jar http.CookieJar) OptionFunc {
+	return SetCookieJar(jar)
+}
+
+// WithDefaultCookieJar option stores cookies in a new in-memory jar, see
+// net/http/cookiejar. A Session has one unless another jar is given.
+func WithDefaultCookieJar() OptionFunc {
+	return func(r *Request) {
+		jar, err := cookiejar.New(nil)
+		if err != nil {
+			r.setErr(err)
+			return
+		}
+		r.cookie = jar
+	}
+}
+
 // SetTimeout option sets timeout t for request
 func SetTimeout(t time.Duration) OptionFunc {
 	return func(r *Request) {
 		r.timeout = t
 	}
 }
+
+// WithConnectTimeout option limits the time to connect to the server,
+// including the TLS handshake, to d. It fails with ErrDialTimeout. Like
+// WithReadTimeout it applies along with SetTimeout and the deadline of the
+// context of SetContext, whichever expires first.
+func WithConnectTimeout(d time.Duration) OptionFunc {
+	return func(r *Request) {
+		r.connectTimeout = d
+	}
+}
+
+// WithReadTimeout option limits the wait for the response headers, and
+// then for every read of the response body, to d. A server sending a large
+// body slowly but steadily doesn't time out. It fails with
+// ErrRequestTimeout, along with SetTimeout and the deadline of the context
+// of SetContext, whichever expires first.
+func WithReadTimeout(d time.Duration) OptionFunc {
+	return func(r *Request) {
+		r.readTimeout = d
+	}
+}
+
+// WithRequestTimeout option limits every send of the request to d, derived
+// from the context of SetContext, like the Timeout method. With NewClient
+// and R it varies the timeout per call while the client keeps the timeout
+// of SetTimeout.
+func WithRequestTimeout(d time.Duration) OptionFunc {
+	return func(r *Request) {
+		r.callTimeout = d
+	}
+}
+
+// WithHeaders option sets headers sent with every request, a header given
+// with Headers replaces the one of the same name
+func WithHeaders(headers map[string]string) OptionFunc {
+	return func(r *Request) {
+		r.defaultHeaders = make(map[string]string, len(r.defaultHeaders)+len(headers))
+		for key, val := range r.defaultHeaders {
+			r.defaultHeaders[key] = val
+		}
+		for key, val := range headers {
+			r.defaultHeaders[key] = val
+		}
+	}
+}
+
+// WithBaseURL option resolves the URLs given to Get, Post and the others
+// against base, unless they are absolute. Paths are appended to the path of
+// base, with or without a leading slash. An invalid base is returned as
+// error when the request is sent.
+func WithBaseURL(base string) OptionFunc {
+	return func(r *Request) {
+		u, err := url.Parse(base)
+		if err == nil && !u.IsAbs() {
+			err = fmt.Errorf("gohttp: base URL %q is not absolute", base)
+		}
+		if err != nil {
+			r.setErr(err)
+			return
+		}
+		r.baseURL = u
+	}
+}
+
+// WithRedirectPolicy option sets which redirects are followed with
+// NoRedirect, MaxRedirects and OnRedirect. The Authorization header is
+// never sent to other hosts unless KeepAuthOnRedirect is given. It has no
+// effect when a client is given with SetClient.
+func WithRedirectPolicy(policies ...RedirectPolicy) OptionFunc {
+	return func(r *Request) {
+		for _, policy := range policies {
+			policy(&r.redirect)
+		}
+	}
+}
+
+// WithProxy option sends the request through the proxy at proxyURL, see
+// Request.Proxy. The transport is copied before the proxy is set, so a
+// transport given with SetTransport is never modified. It has no effect
+// when a client is given with SetClient.
+func WithProxy(proxyURL string) OptionFunc {
+	return func(r *Request) {
+		r.Proxy(proxyURL)
+	}
+}
+
+// WithProxyFunc option sets fn to pick the proxy for each request, a nil URL
+// means no proxy. See WithProxy for how it interacts with SetTransport.
+func WithProxyFunc(fn func(*http.Request) (*url.URL, error)) OptionFunc {
+	return func(r *Request) {
+		r.proxy = fn
+		r.noProxy = false
+	}
+}
+
+// WithNoProxy option connects directly, ignoring the proxy environment
+// variables and a proxy of the transport given with SetTransport
+func WithNoProxy() OptionFunc {
+	return func(r *Request) {
+		r.proxy = nil
+		r.noProxy = true
+	}
+}
+
+// WithHTTP1Only option disables HTTP/2 and chunked transfer encoding for
+// servers which don't support them. Every body is sent with a
+// Content-Length, a BodyWriterTo body is written to memory first.
+func WithHTTP1Only() OptionFunc {
+	return func(r *Request) {
+		r.http1Only = true
+		r.http2 = nil
+	}
+}
+
+// WithHTTP2 option negotiates HTTP/2 with TLS servers when enabled is true,
+// even with a transport given with SetTransport or a TLS config, which
+// disable it by default, e.g. for HTTP/2 only gRPC gateways. When enabled
+// is false HTTP/1.1 is always used, for servers with a broken HTTP/2
+// implementation.
+func WithHTTP2(enabled bool) OptionFunc {
+	return func(r *Request) {
+		r.http2 = &enabled
+		r.http1Only = false
+	}
+}
+
+// WithUnixSocket option connects to the Unix domain socket at socketPath
+// instead of the host of the URL, e.g. for the API of a local daemon. The
+// URL still needs a host, which is sent in the Host header, e.g.
+// "http://localhost/containers/json". Proxies are not used.
+func WithUnixSocket(socketPath string) OptionFunc {
+	return func(r *Request) {
+		r.unixSocket = socketPath
+	}
+}
+
+// WithStreamingUploads option streams the multipart body of Upload and
+// UploadFromReader, which is the default, it undoes WithBufferedUploads
+func WithStreamingUploads() OptionFunc {
+	return func(r *Request) {
+		r.bufferUploads = false
+	}
+}
+
+// WithBufferedUploads option builds the multipart body of Upload and
+// UploadFromReader in memory when they are called, instead of streaming it
+// while the request is sent. The body is sent with a Content-Length, for
+// servers which require one, and the files are read only once.
+func WithBufferedUploads() OptionFunc {
+	return func(r *Request) {
+		r.bufferUploads = true
+	}
+}
+
+// WithTLSConfig option replaces the TLS configuration of the transport
+// with a copy of cfg, the other TLS options are applied on top of it. The
+// transport is copied before it is configured.
+func WithTLSConfig(cfg *tls.Config) OptionFunc {
+	return func(r *Request) {
+		r.tlsConfig = cfg
+		r.skipTLSVerify = nil
+	}
+}
+
+// WithRootCAs option verifies servers with the certificates of pool
+// instead of the system ones
+func WithRootCAs(pool *x509.CertPool) OptionFunc {
+	return func(r *Request) {
+		r.tlsOptions = append(r.tlsOptions, func(cfg *tls.Config) {
+			cfg.RootCAs = pool
+		})
+	}
+}
+
+// WithRootCAFile option verifies servers with the PEM certificates in the
+// file at path instead of the system ones. An error reading the file is
+// returned when the request is sent.
+func WithRootCAFile(path string) OptionFunc {
+	return func(r *Request) {
+		data, err := ioutil.ReadFile(path)
+		if err != nil {
+			r.setErr(err)
+			return
+		}
+
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			r.setErr(fmt.Errorf("gohttp: no certificates found in %s", path))
+			return
+		}
+		WithRootCAs(pool)(r)
+	}
+}
+
+// WithClientCert option presents the certificate in certFile with the key
+// in keyFile to servers asking for one, both PEM encoded. An error loading
+// them is returned when the request is sent.
+func WithClientCert(certFile, keyFile string) OptionFunc {
+	return func(r *Request) {
+		cert, err := tls.LoadX509KeyPair(certFile, keyFile)
+		if err != nil {
+			r.setErr(err)
+			return
+		}
+		r.tlsOptions = append(r.tlsOptions, func(cfg *tls.Config) {
+			cfg.Certificates = append(cfg.Certificates[:len(cfg.Certificates):len(cfg.Certificates)], cert)
+		})
+	}
+}
+
+// WithInsecureSkipVerify option accepts any server certificate, it should
+// only be used for testing
+func WithInsecureSkipVerify() OptionFunc {
+	return func(r *Request) {
+		r.tlsOptions = append(r.tlsOptions, func(cfg *tls.Config) {
+			cfg.InsecureSkipVerify = true
+		})
+	}
+}
+
+// WithSkipTLSVerify option accepts any server certificate when skip is
+// true, for development and testing only. It overrides the setting of
+// WithTLSConfig given before it, a WithTLSConfig given after it wins. A
+// warning is logged when the client is built, see WithLogger.
+func WithSkipTLSVerify(skip bool) OptionFunc {
+	return func(r *Request) {
+		r.skipTLSVerify = &skip
+	}
+}
+
+// WithStrictTLS option checks every TLS connection against policy, a
+// violation fails the request with a *TLSPolicyError unless the policy is
+// report only. Only the checks the policy sets are run, e.g.
+// StrictTLSPolicy{RequireOCSPStaple: true, MinRSABits: 2048, RejectSHA1: true}
+// for all of them. The transport is copied before it is configured.
+func WithStrictTLS(policy StrictTLSPolicy) OptionFunc {
+	return func(r *Request) {
+		r.strictTLS = &policy
+	}
+}
+
+// WithDevShaping option simulates a slow network for local development. The
+// request is dispatched after latency plus a random duration up to jitter,
+// and the response body is read at no more than bandwidth bytes per
+// second, if positive. Only timing changes, never the exchanged data. It is
+// ignored unless AllowDevShaping is given too or the environment variable
+// named by DevShapingEnv is set, so it can't slow down production by
+// accident.
+func WithDevShaping(latency, jitter time.Duration, bandwidth int64) OptionFunc {
+	return func(r *Request) {
+		r.devShaping = newDevShaping(latency, jitter, bandwidth)
+	}
+}
+
+// AllowDevShaping option allows WithDevShaping without the environment
+// variable
+func AllowDevShaping() OptionFunc {
+	return func(r *Request) {
+		r.allowDevShaping = true
+	}
+}
+
+// WithUserAgent option sets the User-Agent header, see Request.UserAgent
+func WithUserAgent(ua string) OptionFunc {
+	return func(r *Request) {
+		r.userAgent = ua
+	}
+}
+
+// WithUserAgentFunc option sets fn to pick the User-Agent every time the
+// request is sent, it overrides WithUserAgent. A User-Agent given with
+// Headers still wins.
+func WithUserAgentFunc(fn func(req *Request) string) OptionFunc {
+	return func(r *Request) {
+		r.userAgentFunc = fn
+	}
+}
+
+// WithUploadProgress option sets fn to be called while the request body is
+// sent, with the bytes sent so far and the body size, and a final time once
+// it is complete. The size is -1 when it is unknown, e.g. for BodyWriterTo.
+func WithUploadProgress(fn func(written, total int64)) OptionFunc {
+	return func(r *Request) {
+		r.uploadProgress = fn
+	}
+}
+
+// WithDownloadProgress option sets fn to be called while the response body
+// is read, with the bytes read so far and the Content-Length, and a final
+// time once it is complete. The length is -1 when the server sent none.
+func WithDownloadProgress(fn func(written, total int64)) OptionFunc {
+	return func(r *Request) {
+		r.downloadProgress = fn
+	}
+}
+
+// WithRetry option retries up to count times when the server responds with
+// 429 Too Many Requests or 503 Service Unavailable. The wait is taken from
+// the Retry-After header when present, otherwise it starts at backoff and
+// doubles with every attempt.
+func WithRetry(count int, backoff time.Duration) OptionFunc {
+	return func(r *Request) {
+		r.retryCount = count
+		r.retryBackoff = backoff
+	}
+}
+
+// WithMaxRetryAfter option sets the longest Retry-After wait that is
+// honored, a longer wait aborts the retries with ErrRetryAfterTooLong. It
+// is 30 seconds by default, 0 honors any wait.
+func WithMaxRetryAfter(max time.Duration) OptionFunc {
+	return func(r *Request) {
+		r.maxRetryAfter = max
+	}
+}
+
+// WithMiddleware option wraps the transport of the request client with mw,
+// middlewares see a request in the order they are given. It has no effect
+// when a client is given with SetClient.
+func WithMiddleware(mw func(http.RoundTripper) http.RoundTripper) OptionFunc {
+	return func(r *Request) {
+		r.middlewares = append(r.middlewares, mw)
+	}
+}
@@ -0,0 +1,486 @@
+package gohttp
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/cookiejar"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"runtime"
+	"sync/atomic"
+	"testing"
+)
+
+// newFakeProxy returns a server acting as a forward proxy, it answers every
+// request itself and records the requested absolute URLs
+func newFakeProxy(t *testing.T) (*httptest.Server, *[]string) {
+	var forwarded []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		forwarded = append(forwarded, r.URL.String())
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &forwarded
+}
+
+// TestWithProxy tests WithProxy option
+func TestWithProxy(t *testing.T) {
+	t.Log("Sending GET request through proxy... (expected proxied url)")
+
+	proxy, forwarded := newFakeProxy(t)
+
+	resp, err := NewRequest(WithProxy(proxy.URL)).Get("http://example.com/get")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.GetStatusCode() != 200 || len(*forwarded) != 1 || (*forwarded)[0] != "http://example.com/get" {
+		t.Error(
+			"For", "WithProxy",
+			"expected", "http://example.com/get",
+			"got", *forwarded,
+		)
+	}
+}
+
+// TestWithProxyFunc tests WithProxyFunc option
+func TestWithProxyFunc(t *testing.T) {
+	t.Log("Sending GET request through proxy func... (expected proxied url)")
+
+	proxy, forwarded := newFakeProxy(t)
+	proxyURL, _ := url.Parse(proxy.URL)
+
+	req := NewRequest(WithProxyFunc(func(r *http.Request) (*url.URL, error) {
+		if r.URL.Host == "example.com" {
+			return proxyURL, nil
+		}
+		return nil, nil
+	}))
+
+	if _, err := req.Get("http://example.com/a"); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*forwarded) != 1 {
+		t.Error(
+			"For", "WithProxyFunc",
+			"expected", 1,
+			"got", len(*forwarded),
+		)
+	}
+}
+
+// TestWithProxyKeepsTransport tests WithProxy does not modify a given transport
+func TestWithProxyKeepsTransport(t *testing.T) {
+	t.Log("Combining SetTransport and WithProxy... (expected transport untouched)")
+
+	proxy, forwarded := newFakeProxy(t)
+	tr := &http.Transport{}
+
+	if _, err := NewRequest(SetTransport(tr), WithProxy(proxy.URL)).Get("http://example.com/"); err != nil {
+		t.Fatal(err)
+	}
+
+	if tr.Proxy != nil || len(*forwarded) != 1 {
+		t.Error(
+			"For", "SetTransport with WithProxy",
+			"expected", "transport without proxy",
+			"got", "modified transport",
+		)
+	}
+}
+
+// TestWithProxyInvalidURL tests an invalid proxy url is returned on send
+func TestWithProxyInvalidURL(t *testing.T) {
+	t.Log("Sending GET request with invalid proxy... (expected error)")
+
+	var hookErr error
+	req := NewRequest(WithProxy("http://[::1"))
+	req.OnError(func(_ *Request, err error) {
+		hookErr = err
+	})
+
+	_, err := req.Get("http://example.com/")
+	if err == nil || hookErr != err {
+		t.Error(
+			"For", "WithProxy",
+			"expected", "configuration error",
+			"got", err,
+		)
+	}
+}
+
+// TestWithHTTP1Only tests bodies are sent with a length instead of chunked
+func TestWithHTTP1Only(t *testing.T) {
+	t.Log("Sending POST request with streamed body in HTTP/1 only mode... (expected Content-Length)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%v %d", r.TransferEncoding, r.ContentLength)
+	}))
+	defer ts.Close()
+
+	body := lines{"first", "second"}
+	resp, err := NewRequest(WithHTTP1Only()).BodyWriterTo(body, "text/plain").Post(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if got, _ := resp.GetBodyAsString(); got != "[] 13" {
+		t.Error(
+			"For", "WithHTTP1Only",
+			"expected", "[] 13",
+			"got", got,
+		)
+	}
+}
+
+// TestWithHTTP2 tests the protocol negotiated with a TLS server supporting
+// HTTP/2
+func TestWithHTTP2(t *testing.T) {
+	t.Log("Sending GET requests to HTTP/2 server... (expected protocol chosen by WithHTTP2)")
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, r.Proto)
+	}))
+	ts.EnableHTTP2 = true
+	ts.StartTLS()
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		proto string
+	}{
+		{"default transport", nil, "HTTP/2.0"},
+		{"disabled", []Option{WithHTTP2(false)}, "HTTP/1.1"},
+		{"custom transport", []Option{SetTransport(&http.Transport{})}, "HTTP/1.1"},
+		{"custom transport enabled", []Option{SetTransport(&http.Transport{}), WithHTTP2(true)}, "HTTP/2.0"},
+		{"enabled after HTTP/1 only", []Option{WithHTTP1Only(), WithHTTP2(true)}, "HTTP/2.0"},
+	}
+
+	for _, tt := range tests {
+		opts := append([]Option{WithRootCAs(pool)}, tt.opts...)
+		resp, err := NewRequest(opts...).Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		body, _ := resp.GetBodyAsString()
+		if resp.Protocol() != tt.proto || body != tt.proto {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.proto,
+				"got", resp.Protocol(), body,
+			)
+		}
+	}
+}
+
+// TestProxyCredentials tests Proxy sends URL credentials to the proxy
+func TestProxyCredentials(t *testing.T) {
+	t.Log("Sending GET request through proxy with credentials... (expected Proxy-Authorization)")
+
+	proxy := newHeaderServer(t, "Proxy-Authorization")
+	proxyURL, _ := url.Parse(proxy.URL)
+	proxyURL.User = url.UserPassword("user", "pass")
+
+	resp, err := NewRequest().Proxy(proxyURL.String()).Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body, _ := resp.GetBodyAsString(); body != "Basic dXNlcjpwYXNz" {
+		t.Error(
+			"For", "Proxy",
+			"expected", "Basic dXNlcjpwYXNz",
+			"got", body,
+		)
+	}
+}
+
+// TestProxyScheme tests unsupported proxy schemes are returned on send
+func TestProxyScheme(t *testing.T) {
+	t.Log("Sending GET requests with proxy schemes... (expected error for unsupported)")
+
+	tests := []struct {
+		proxyURL string
+		fails    bool
+	}{
+		{"http://proxy:3128", false},
+		{"https://proxy:3128", false},
+		{"socks5://proxy:1080", false},
+		{"ftp://proxy:21", true},
+		{"proxy:3128", true},
+	}
+
+	for _, tt := range tests {
+		req := NewRequest().Proxy(tt.proxyURL)
+		if (req.err != nil) != tt.fails || tt.fails && !errors.Is(req.err, ErrUnsupportedProxyScheme) {
+			t.Error(
+				"For", tt.proxyURL,
+				"expected", "fails:", tt.fails,
+				"got", req.err,
+			)
+		}
+	}
+}
+
+// TestWithNoProxy tests WithNoProxy bypasses the transport proxy
+func TestWithNoProxy(t *testing.T) {
+	t.Log("Sending GET request with no proxy... (expected direct connection)")
+
+	proxy, forwarded := newFakeProxy(t)
+	proxyURL, _ := url.Parse(proxy.URL)
+	ts := newHeaderServer(t, "X-Unused")
+
+	tr := &http.Transport{Proxy: http.ProxyURL(proxyURL)}
+	if _, err := NewRequest(SetTransport(tr), WithNoProxy()).Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(*forwarded) != 0 || tr.Proxy == nil {
+		t.Error(
+			"For", "WithNoProxy",
+			"expected", "no proxied requests",
+			"got", *forwarded,
+		)
+	}
+}
+
+// TestWithUserAgentFunc tests the User-Agent is picked on every send
+func TestWithUserAgentFunc(t *testing.T) {
+	t.Log("Sending GET requests with rotating user agent... (expected different agents)")
+
+	ts := newHeaderServer(t, "User-Agent")
+
+	agents := []string{"agent-a", "agent-b"}
+	n := 0
+	req := NewRequest(WithUserAgentFunc(func(r *Request) string {
+		n++
+		return agents[(n-1)%len(agents)]
+	}))
+
+	for _, expected := range agents {
+		resp, err := req.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if body, _ := resp.GetBodyAsString(); body != expected {
+			t.Error(
+				"For", "WithUserAgentFunc",
+				"expected", expected,
+				"got", body,
+			)
+		}
+	}
+}
+
+// TestWithUserAgent tests the default and configured User-Agent
+func TestWithUserAgent(t *testing.T) {
+	t.Log("Sending GET requests with user agents... (expected outgoing User-Agent)")
+
+	ts := newHeaderServer(t, "User-Agent")
+
+	tests := []struct {
+		req      *Request
+		expected string
+	}{
+		{NewRequest(), "gohttp/" + Version},
+		{NewRequest(WithUserAgent("app/1.0")), "app/1.0"},
+		{NewRequest().UserAgent("app/2.0"), "app/2.0"},
+		{NewRequest(WithUserAgent("app/1.0")).Headers(map[string]string{"X-Other": "x"}), "app/1.0"},
+		{NewRequest(WithUserAgent("app/1.0")).Headers(map[string]string{"User-Agent": "explicit"}), "explicit"},
+		{NewRequest(WithUserAgent("app/1.0"), WithUserAgentFunc(func(*Request) string { return "dynamic" })), "dynamic"},
+	}
+
+	for _, tt := range tests {
+		resp, err := tt.req.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if body, _ := resp.GetBodyAsString(); body != tt.expected {
+			t.Error(
+				"For", "User-Agent",
+				"expected", tt.expected,
+				"got", body,
+			)
+		}
+	}
+}
+
+// TestWithProxyTLSConfig tests WithProxy composes with WithTLSConfig
+func TestWithProxyTLSConfig(t *testing.T) {
+	t.Log("Sending GET requests through proxy with TLS config... (expected proxied connection)")
+
+	var conns int32
+	proxy := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.String()))
+	}))
+	proxy.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	proxy.Start()
+	defer proxy.Close()
+
+	req := NewRequest(WithTLSConfig(&tls.Config{RootCAs: x509.NewCertPool()}), WithProxy(proxy.URL))
+	for i := 0; i < 2; i++ {
+		resp, err := req.Get("http://example.com/get")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := resp.GetBodyAsString(); body != "http://example.com/get" {
+			t.Error(
+				"For", "WithProxy",
+				"expected", "http://example.com/get",
+				"got", body,
+			)
+		}
+	}
+
+	if d := req.Describe(); atomic.LoadInt32(&conns) != 1 || d.Proxy != "custom" || d.TLS != "custom" {
+		t.Error(
+			"For", "WithProxy and WithTLSConfig",
+			"expected", "1 connection with custom proxy and TLS",
+			"got", conns, d.String(),
+		)
+	}
+
+	if _, err := NewRequest(WithProxy("ftp://proxy:21")).Get("http://example.com/"); !errors.Is(err, ErrUnsupportedProxyScheme) {
+		t.Error(
+			"For", "ftp proxy",
+			"expected", ErrUnsupportedProxyScheme,
+			"got", err,
+		)
+	}
+}
+
+// TestCombineOptions tests combined options are applied in order
+func TestCombineOptions(t *testing.T) {
+	t.Log("Applying combined options... (expected options applied in order)")
+
+	api := CombineOptions(
+		WithBaseURL("http://example.com/api"),
+		WithUserAgent("first"),
+		CombineOptions(WithUserAgent("api-client"), WithHeaders(map[string]string{"X-Key": "k"})),
+	)
+
+	tests := []struct {
+		opts     []Option
+		expected string
+	}{
+		{[]Option{api}, "api-client"},
+		{[]Option{api, WithUserAgent("override")}, "override"},
+		{[]Option{WithUserAgent("override"), api}, "api-client"},
+	}
+
+	for _, tt := range tests {
+		req := NewRequest(tt.opts...)
+		if req.userAgent != tt.expected || req.baseURL.String() != "http://example.com/api" || req.defaultHeaders["X-Key"] != "k" {
+			t.Error(
+				"For", tt.opts,
+				"expected", tt.expected,
+				"got", req.userAgent, req.baseURL, req.defaultHeaders,
+			)
+		}
+	}
+}
+
+// TestWithUnixSocket tests requests are sent to a server on a Unix socket
+// with the Host of the URL
+func TestWithUnixSocket(t *testing.T) {
+	t.Log("Sending GET request over Unix socket... (expected response with URL host)")
+
+	if runtime.GOOS == "windows" {
+		t.Skip("Unix sockets are not available")
+	}
+
+	dir, err := ioutil.TempDir("", "gohttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	socketPath := filepath.Join(dir, "api.sock")
+	l, err := net.Listen("unix", socketPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	srv := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s", r.Host, r.URL.Path)
+	})}
+	go srv.Serve(l)
+	defer srv.Close()
+
+	resp, err := NewRequest(WithUnixSocket(socketPath)).Get("http://tenant.local/containers/json")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := resp.GetBodyAsString(); got != "tenant.local /containers/json" {
+		t.Error(
+			"For", socketPath,
+			"expected", "tenant.local /containers/json",
+			"got", got,
+		)
+	}
+}
+
+// TestWithCookieJar tests cookies set by a response are sent with the next
+// requests sharing the jar
+func TestWithCookieJar(t *testing.T) {
+	t.Log("Sending requests with cookie jars... (expected cookie sent back only with a jar)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/"})
+			return
+		}
+		if c, err := r.Cookie("session"); err == nil {
+			w.Write([]byte(c.Value))
+		}
+	}))
+	defer ts.Close()
+
+	jar, _ := cookiejar.New(nil)
+	client := NewClient(WithDefaultCookieJar())
+	tests := []struct {
+		name     string
+		newReq   func() *Request
+		expected string
+	}{
+		{"WithCookieJar", func() *Request { return NewRequest(WithCookieJar(jar)) }, "s3cr3t"},
+		{"WithDefaultCookieJar", func() *Request { return client.R() }, "s3cr3t"},
+		{"new default jar", func() *Request { return NewRequest(WithDefaultCookieJar()) }, ""},
+		{"no jar", func() *Request { return NewRequest() }, ""},
+	}
+
+	for _, tt := range tests {
+		if _, err := tt.newReq().Get(ts.URL + "/login"); err != nil {
+			t.Fatal(err)
+		}
+		resp, err := tt.newReq().Get(ts.URL + "/profile")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := resp.GetBodyAsString(); got != tt.expected {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", got,
+			)
+		}
+	}
+}
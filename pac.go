@@ -0,0 +1,705 @@
+package gohttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+)
+
+// ErrPACScript is matched by the errors of PAC files which can't be parsed
+// or evaluated
+var ErrPACScript = errors.New("gohttp: invalid PAC file")
+
+// pacFetchTimeout bounds the fetch of a PAC file
+var pacFetchTimeout = 10 * time.Second
+
+// WithPACFile option picks the proxy of every request with the
+// FindProxyForURL function of the proxy auto-config file at pacURL. The
+// file is fetched directly, once for every use of the returned option, by
+// the first request sent. A fetch failing or taking longer than 10 seconds
+// fails the requests waiting for it, the next request fetches the file
+// again. A request whose context is done stops waiting for the fetch. The
+// first entry of the returned list is used: "PROXY host:port",
+// "HTTPS host:port", "SOCKS host:port" or "DIRECT".
+//
+// Only a subset of JavaScript is evaluated: if and else, return, var,
+// string literals, ==, !=, +, !, && and || and the functions shExpMatch,
+// dnsDomainIs, isPlainHostName, localHostOrDomainIs, dnsDomainLevels,
+// dnsResolve, isResolvable and isInNet. Another construct fails the
+// requests with ErrPACScript.
+func WithPACFile(pacURL string) OptionFunc {
+	pac := &pacFile{url: pacURL}
+	return func(r *Request) {
+		r.proxy = pac.proxy
+		r.noProxy = false
+	}
+}
+
+// pacFile is a PAC file fetched by the first request needing it, a fetch
+// which failed is tried again by the next request
+type pacFile struct {
+	url string
+
+	mu sync.Mutex
+	fn *pacFunction
+	// fetching is closed once the running fetch is done, nil without one
+	fetching chan struct{}
+	// err is the error of the last fetch, for the requests waiting for it
+	err error
+}
+
+func (p *pacFile) proxy(req *http.Request) (*url.URL, error) {
+	fn, err := p.function(req.Context())
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := fn.call(req.URL.String(), req.URL.Hostname())
+	if err != nil {
+		return nil, err
+	}
+	return parsePACResult(result)
+}
+
+// function returns the FindProxyForURL function of the file, fetching it
+// unless it was already. Concurrent requests wait for the same fetch, which
+// doesn't depend on their contexts.
+func (p *pacFile) function(ctx context.Context) (*pacFunction, error) {
+	p.mu.Lock()
+	if p.fn != nil {
+		defer p.mu.Unlock()
+		return p.fn, nil
+	}
+	done := p.fetching
+	if done == nil {
+		done = make(chan struct{})
+		p.fetching = done
+		go func() {
+			fn, err := fetchPACFile(p.url)
+			p.mu.Lock()
+			p.fn, p.err, p.fetching = fn, err, nil
+			p.mu.Unlock()
+			close(done)
+		}()
+	}
+	p.mu.Unlock()
+
+	select {
+	case <-done:
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.fn != nil {
+		return p.fn, nil
+	}
+	return nil, p.err
+}
+
+func fetchPACFile(pacURL string) (*pacFunction, error) {
+	res, err := NewRequest(WithNoProxy()).Timeout(pacFetchTimeout).Get(pacURL)
+	if err != nil {
+		return nil, err
+	}
+	body, err := res.GetBodyAsString()
+	if err != nil {
+		return nil, err
+	}
+	if res.GetStatusCode() >= 400 {
+		return nil, fmt.Errorf("%w: fetching PAC file: %s", ErrBadStatus, res.GetResp().Status)
+	}
+	return parsePACScript(body)
+}
+
+// parsePACResult returns the proxy of the first entry of a FindProxyForURL
+// result, nil for DIRECT
+func parsePACResult(result string) (*url.URL, error) {
+	entry := strings.TrimSpace(strings.SplitN(result, ";", 2)[0])
+	fields := strings.Fields(entry)
+	if len(fields) == 1 && strings.EqualFold(fields[0], "DIRECT") {
+		return nil, nil
+	}
+	if len(fields) != 2 {
+		return nil, fmt.Errorf("%w: unexpected result %q", ErrPACScript, result)
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case "PROXY", "HTTP":
+		return parseProxyURL("http://" + fields[1])
+	case "HTTPS":
+		return parseProxyURL("https://" + fields[1])
+	case "SOCKS", "SOCKS5":
+		return parseProxyURL("socks5://" + fields[1])
+	}
+	return nil, fmt.Errorf("%w: unsupported proxy type %q", ErrPACScript, fields[0])
+}
+
+// pacFunction is the parsed FindProxyForURL function of a PAC file
+type pacFunction struct {
+	params []string
+	body   []pacStmt
+}
+
+// call evaluates the function for rawURL and host and returns its result
+func (fn *pacFunction) call(rawURL, host string) (string, error) {
+	env := map[string]interface{}{}
+	args := []string{rawURL, host}
+	for i, name := range fn.params {
+		if i < len(args) {
+			env[name] = args[i]
+		}
+	}
+
+	v, returned, err := execPACStmts(fn.body, env)
+	if err != nil {
+		return "", err
+	}
+	s, ok := v.(string)
+	if !returned || !ok {
+		return "", fmt.Errorf("%w: FindProxyForURL returned no string", ErrPACScript)
+	}
+	return s, nil
+}
+
+// pacStmt is a statement of a PAC function, it returns the returned value
+// and whether the function returns
+type pacStmt func(env map[string]interface{}) (interface{}, bool, error)
+
+// pacExpr is an expression of a PAC function
+type pacExpr func(env map[string]interface{}) (interface{}, error)
+
+func execPACStmts(stmts []pacStmt, env map[string]interface{}) (interface{}, bool, error) {
+	for _, stmt := range stmts {
+		if v, returned, err := stmt(env); returned || err != nil {
+			return v, returned, err
+		}
+	}
+	return nil, false, nil
+}
+
+// pacToken is a token of a PAC file, kind is 's' for strings, 'n' for
+// numbers, 'i' for identifiers and 'p' for punctuation
+type pacToken struct {
+	kind byte
+	text string
+}
+
+func tokenizePAC(src string) ([]pacToken, error) {
+	var tokens []pacToken
+	for i := 0; i < len(src); {
+		c := src[i]
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r':
+			i++
+		case strings.HasPrefix(src[i:], "//"):
+			for i < len(src) && src[i] != '\n' {
+				i++
+			}
+		case strings.HasPrefix(src[i:], "/*"):
+			end := strings.Index(src[i+2:], "*/")
+			if end < 0 {
+				return nil, fmt.Errorf("%w: unterminated comment", ErrPACScript)
+			}
+			i += end + 4
+		case c == '"' || c == '\'':
+			var b strings.Builder
+			j := i + 1
+			for ; j < len(src) && src[j] != c; j++ {
+				if src[j] == '\\' && j+1 < len(src) {
+					j++
+				}
+				b.WriteByte(src[j])
+			}
+			if j >= len(src) {
+				return nil, fmt.Errorf("%w: unterminated string", ErrPACScript)
+			}
+			tokens = append(tokens, pacToken{'s', b.String()})
+			i = j + 1
+		case c >= '0' && c <= '9':
+			j := i
+			for j < len(src) && src[j] >= '0' && src[j] <= '9' {
+				j++
+			}
+			tokens = append(tokens, pacToken{'n', src[i:j]})
+			i = j
+		case c == '_' || c == '$' || unicode.IsLetter(rune(c)):
+			j := i
+			for j < len(src) && (src[j] == '_' || src[j] == '$' || unicode.IsLetter(rune(src[j])) || unicode.IsDigit(rune(src[j]))) {
+				j++
+			}
+			tokens = append(tokens, pacToken{'i', src[i:j]})
+			i = j
+		default:
+			op := ""
+			for _, candidate := range []string{"===", "!==", "==", "!=", "&&", "||"} {
+				if strings.HasPrefix(src[i:], candidate) {
+					op = candidate
+					break
+				}
+			}
+			if op == "" {
+				if !strings.ContainsRune("(){};,!+=", rune(c)) {
+					return nil, fmt.Errorf("%w: unexpected character %q", ErrPACScript, c)
+				}
+				op = string(c)
+			}
+			tokens = append(tokens, pacToken{'p', op})
+			i += len(op)
+		}
+	}
+	return tokens, nil
+}
+
+// pacParser parses the FindProxyForURL function of a PAC file
+type pacParser struct {
+	tokens []pacToken
+	pos    int
+}
+
+func parsePACScript(src string) (*pacFunction, error) {
+	tokens, err := tokenizePAC(src)
+	if err != nil {
+		return nil, err
+	}
+
+	p := &pacParser{tokens: tokens}
+	for !p.done() {
+		if !p.accept('i', "function") {
+			return nil, p.errorf("expected function")
+		}
+		name, ok := p.ident()
+		if !ok {
+			return nil, p.errorf("expected function name")
+		}
+		fn := &pacFunction{}
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		for !p.accept('p', ")") {
+			param, ok := p.ident()
+			if !ok {
+				return nil, p.errorf("expected parameter")
+			}
+			fn.params = append(fn.params, param)
+			p.accept('p', ",")
+		}
+		if fn.body, err = p.block(); err != nil {
+			return nil, err
+		}
+		if name == "FindProxyForURL" {
+			return fn, nil
+		}
+	}
+	return nil, fmt.Errorf("%w: no FindProxyForURL function", ErrPACScript)
+}
+
+func (p *pacParser) done() bool {
+	return p.pos >= len(p.tokens)
+}
+
+func (p *pacParser) peek(kind byte, text string) bool {
+	return !p.done() && p.tokens[p.pos].kind == kind && p.tokens[p.pos].text == text
+}
+
+func (p *pacParser) accept(kind byte, text string) bool {
+	if p.peek(kind, text) {
+		p.pos++
+		return true
+	}
+	return false
+}
+
+func (p *pacParser) expect(punct string) error {
+	if !p.accept('p', punct) {
+		return p.errorf("expected %q", punct)
+	}
+	return nil
+}
+
+func (p *pacParser) ident() (string, bool) {
+	if p.done() || p.tokens[p.pos].kind != 'i' {
+		return "", false
+	}
+	p.pos++
+	return p.tokens[p.pos-1].text, true
+}
+
+func (p *pacParser) errorf(format string, args ...interface{}) error {
+	at := "end of file"
+	if !p.done() {
+		at = strconv.Quote(p.tokens[p.pos].text)
+	}
+	return fmt.Errorf("%w: %s at %s", ErrPACScript, fmt.Sprintf(format, args...), at)
+}
+
+func (p *pacParser) block() ([]pacStmt, error) {
+	if err := p.expect("{"); err != nil {
+		return nil, err
+	}
+	var stmts []pacStmt
+	for !p.accept('p', "}") {
+		if p.done() {
+			return nil, p.errorf("expected %q", "}")
+		}
+		stmt, err := p.stmt()
+		if err != nil {
+			return nil, err
+		}
+		stmts = append(stmts, stmt)
+	}
+	return stmts, nil
+}
+
+func (p *pacParser) stmt() (pacStmt, error) {
+	switch {
+	case p.peek('p', "{"):
+		stmts, err := p.block()
+		if err != nil {
+			return nil, err
+		}
+		return func(env map[string]interface{}) (interface{}, bool, error) {
+			return execPACStmts(stmts, env)
+		}, nil
+
+	case p.accept('p', ";"):
+		return func(map[string]interface{}) (interface{}, bool, error) {
+			return nil, false, nil
+		}, nil
+
+	case p.accept('i', "if"):
+		if err := p.expect("("); err != nil {
+			return nil, err
+		}
+		cond, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		if err := p.expect(")"); err != nil {
+			return nil, err
+		}
+		then, err := p.stmt()
+		if err != nil {
+			return nil, err
+		}
+		var otherwise pacStmt
+		if p.accept('i', "else") {
+			if otherwise, err = p.stmt(); err != nil {
+				return nil, err
+			}
+		}
+		return func(env map[string]interface{}) (interface{}, bool, error) {
+			v, err := cond(env)
+			if err != nil {
+				return nil, false, err
+			}
+			if pacTruthy(v) {
+				return then(env)
+			}
+			if otherwise != nil {
+				return otherwise(env)
+			}
+			return nil, false, nil
+		}, nil
+
+	case p.accept('i', "return"):
+		value, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		p.accept('p', ";")
+		return func(env map[string]interface{}) (interface{}, bool, error) {
+			v, err := value(env)
+			return v, err == nil, err
+		}, nil
+
+	case p.accept('i', "var"):
+		name, ok := p.ident()
+		if !ok {
+			return nil, p.errorf("expected variable name")
+		}
+		if err := p.expect("="); err != nil {
+			return nil, err
+		}
+		value, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		p.accept('p', ";")
+		return func(env map[string]interface{}) (interface{}, bool, error) {
+			v, err := value(env)
+			env[name] = v
+			return nil, false, err
+		}, nil
+	}
+
+	value, err := p.expr()
+	if err != nil {
+		return nil, err
+	}
+	p.accept('p', ";")
+	return func(env map[string]interface{}) (interface{}, bool, error) {
+		_, err := value(env)
+		return nil, false, err
+	}, nil
+}
+
+func (p *pacParser) expr() (pacExpr, error) {
+	return p.binary(0)
+}
+
+// pacOperators are the binary operators by increasing precedence
+var pacOperators = [][]string{{"||"}, {"&&"}, {"==", "!=", "===", "!=="}, {"+"}}
+
+func (p *pacParser) binary(level int) (pacExpr, error) {
+	if level == len(pacOperators) {
+		return p.unary()
+	}
+	left, err := p.binary(level + 1)
+	if err != nil {
+		return nil, err
+	}
+
+	for {
+		op := ""
+		for _, candidate := range pacOperators[level] {
+			if p.accept('p', candidate) {
+				op = candidate
+				break
+			}
+		}
+		if op == "" {
+			return left, nil
+		}
+		right, err := p.binary(level + 1)
+		if err != nil {
+			return nil, err
+		}
+		left = pacBinary(op, left, right)
+	}
+}
+
+func pacBinary(op string, left, right pacExpr) pacExpr {
+	return func(env map[string]interface{}) (interface{}, error) {
+		l, err := left(env)
+		if err != nil {
+			return nil, err
+		}
+		switch {
+		case op == "||" && pacTruthy(l), op == "&&" && !pacTruthy(l):
+			return l, nil
+		}
+		r, err := right(env)
+		if err != nil {
+			return nil, err
+		}
+
+		switch op {
+		case "==", "===":
+			return l == r, nil
+		case "!=", "!==":
+			return l != r, nil
+		case "+":
+			ln, lok := l.(float64)
+			rn, rok := r.(float64)
+			if lok && rok {
+				return ln + rn, nil
+			}
+			return pacString(l) + pacString(r), nil
+		}
+		return r, nil
+	}
+}
+
+func (p *pacParser) unary() (pacExpr, error) {
+	if p.accept('p', "!") {
+		operand, err := p.unary()
+		if err != nil {
+			return nil, err
+		}
+		return func(env map[string]interface{}) (interface{}, error) {
+			v, err := operand(env)
+			return !pacTruthy(v), err
+		}, nil
+	}
+	return p.primary()
+}
+
+func (p *pacParser) primary() (pacExpr, error) {
+	if p.done() {
+		return nil, p.errorf("expected expression")
+	}
+	tok := p.tokens[p.pos]
+	p.pos++
+
+	switch tok.kind {
+	case 's':
+		return func(map[string]interface{}) (interface{}, error) {
+			return tok.text, nil
+		}, nil
+	case 'n':
+		n, _ := strconv.ParseFloat(tok.text, 64)
+		return func(map[string]interface{}) (interface{}, error) {
+			return n, nil
+		}, nil
+	case 'p':
+		if tok.text != "(" {
+			p.pos--
+			return nil, p.errorf("expected expression")
+		}
+		inner, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		return inner, p.expect(")")
+	}
+
+	switch tok.text {
+	case "true", "false":
+		b := tok.text == "true"
+		return func(map[string]interface{}) (interface{}, error) {
+			return b, nil
+		}, nil
+	}
+
+	if !p.accept('p', "(") {
+		return func(env map[string]interface{}) (interface{}, error) {
+			v, ok := env[tok.text]
+			if !ok {
+				return nil, fmt.Errorf("%w: undefined variable %q", ErrPACScript, tok.text)
+			}
+			return v, nil
+		}, nil
+	}
+
+	builtin, ok := pacBuiltins[tok.text]
+	if !ok {
+		p.pos -= 2
+		return nil, p.errorf("unsupported function")
+	}
+	var args []pacExpr
+	for !p.accept('p', ")") {
+		arg, err := p.expr()
+		if err != nil {
+			return nil, err
+		}
+		args = append(args, arg)
+		if !p.peek('p', ")") {
+			if err := p.expect(","); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return func(env map[string]interface{}) (interface{}, error) {
+		vals := make([]string, len(args))
+		for i, arg := range args {
+			v, err := arg(env)
+			if err != nil {
+				return nil, err
+			}
+			vals[i] = pacString(v)
+		}
+		return builtin(vals), nil
+	}, nil
+}
+
+func pacTruthy(v interface{}) bool {
+	switch v := v.(type) {
+	case bool:
+		return v
+	case string:
+		return v != ""
+	case float64:
+		return v != 0
+	}
+	return false
+}
+
+func pacString(v interface{}) string {
+	switch v := v.(type) {
+	case string:
+		return v
+	case bool:
+		return strconv.FormatBool(v)
+	case float64:
+		return strconv.FormatFloat(v, 'f', -1, 64)
+	}
+	return "null"
+}
+
+// pacArg returns the argument i of a PAC function call, empty if it is
+// missing
+func pacArg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+// pacBuiltins are the PAC functions available to FindProxyForURL
+var pacBuiltins = map[string]func(args []string) interface{}{
+	"shExpMatch": func(args []string) interface{} {
+		re, err := globRegexp(pacArg(args, 1))
+		return err == nil && re.MatchString(pacArg(args, 0))
+	},
+	"dnsDomainIs": func(args []string) interface{} {
+		return strings.HasSuffix(strings.ToLower(pacArg(args, 0)), strings.ToLower(pacArg(args, 1)))
+	},
+	"isPlainHostName": func(args []string) interface{} {
+		return !strings.Contains(pacArg(args, 0), ".")
+	},
+	"localHostOrDomainIs": func(args []string) interface{} {
+		host, hostdom := strings.ToLower(pacArg(args, 0)), strings.ToLower(pacArg(args, 1))
+		if strings.Contains(host, ".") {
+			return host == hostdom
+		}
+		return strings.HasPrefix(hostdom, host+".") || host == hostdom
+	},
+	"dnsDomainLevels": func(args []string) interface{} {
+		return float64(strings.Count(pacArg(args, 0), "."))
+	},
+	"dnsResolve": func(args []string) interface{} {
+		if ip := resolvePACHost(pacArg(args, 0)); ip != nil {
+			return ip.String()
+		}
+		return nil
+	},
+	"isResolvable": func(args []string) interface{} {
+		return resolvePACHost(pacArg(args, 0)) != nil
+	},
+	"isInNet": func(args []string) interface{} {
+		ip := resolvePACHost(pacArg(args, 0))
+		pattern, mask := net.ParseIP(pacArg(args, 1)).To4(), net.ParseIP(pacArg(args, 2)).To4()
+		if ip == nil || pattern == nil || mask == nil {
+			return false
+		}
+		return ip.Mask(net.IPMask(mask)).Equal(pattern.Mask(net.IPMask(mask)))
+	},
+}
+
+// resolvePACHost returns the IPv4 address of host, nil if it can't be
+// resolved
+func resolvePACHost(host string) net.IP {
+	if ip := net.ParseIP(host); ip != nil {
+		return ip.To4()
+	}
+	ips, err := net.LookupIP(host)
+	if err != nil {
+		return nil
+	}
+	for _, ip := range ips {
+		if ip4 := ip.To4(); ip4 != nil {
+			return ip4
+		}
+	}
+	return nil
+}
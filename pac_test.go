@@ -0,0 +1,183 @@
+package gohttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestPACScript tests the evaluation of FindProxyForURL
+func TestPACScript(t *testing.T) {
+	t.Log("Evaluating PAC file... (expected proxy per URL)")
+
+	fn, err := parsePACScript(`
+		/* proxy auto-config */
+		function FindProxyForURL(url, host) {
+			var proxy = "PROXY proxy.corp:3128";
+			// internal hosts are reached directly
+			if (isPlainHostName(host) || dnsDomainIs(host, ".corp") ||
+				isInNet(host, "10.0.0.0", "255.0.0.0"))
+				return "DIRECT";
+			if (shExpMatch(url, "https://*.example.com/*") && !localHostOrDomainIs(host, "www.example.com")) {
+				return 'SOCKS socks.corp:1080';
+			} else if (host == "example.org") {
+				return proxy + "; DIRECT";
+			}
+			if (dnsDomainLevels(host) == 3)
+				return "DIRECT";
+			return proxy;
+		}`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"http://intranet/", "<nil>"},
+		{"http://git.corp/", "<nil>"},
+		{"http://10.1.2.3/", "<nil>"},
+		{"https://api.example.com/users", "socks5://socks.corp:1080"},
+		{"https://www.example.com/users", "http://proxy.corp:3128"},
+		{"http://example.org/", "http://proxy.corp:3128"},
+		{"http://a.b.c.d/", "<nil>"},
+	}
+
+	for _, tt := range tests {
+		req, _ := http.NewRequest("GET", tt.url, nil)
+		result, err := fn.call(req.URL.String(), req.URL.Hostname())
+		if err != nil {
+			t.Fatal(err)
+		}
+		proxy, err := parsePACResult(result)
+		if err != nil || fmt.Sprint(proxy) != tt.expected {
+			t.Error(
+				"For", tt.url,
+				"expected", tt.expected,
+				"got", proxy, err,
+			)
+		}
+	}
+}
+
+// TestWithPACFile tests requests use the proxy returned by a fetched PAC
+// file, and unsupported scripts fail the request
+func TestWithPACFile(t *testing.T) {
+	t.Log("Sending requests with PAC file... (expected proxied request)")
+
+	proxy, forwarded := newFakeProxy(t)
+	fetched := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetched++
+		if r.URL.Path == "/invalid.pac" {
+			fmt.Fprint(w, `function FindProxyForURL(url, host) { return myIpAddress(); }`)
+			return
+		}
+		fmt.Fprintf(w, `function FindProxyForURL(url, host) {
+			if (dnsDomainIs(host, "example.com")) return "PROXY %s";
+			return "DIRECT";
+		}`, strings.TrimPrefix(proxy.URL, "http://"))
+	}))
+	defer ts.Close()
+
+	pac := WithPACFile(ts.URL + "/proxy.pac")
+	for _, u := range []string{"http://api.example.com/a", ts.URL + "/direct"} {
+		if _, err := NewRequest(pac).Get(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if fetched != 2 || len(*forwarded) != 1 || (*forwarded)[0] != "http://api.example.com/a" {
+		t.Error(
+			"For", "WithPACFile",
+			"expected", "1 fetch, 1 direct request and 1 proxied",
+			"got", fetched, *forwarded,
+		)
+	}
+
+	_, err := NewRequest(WithPACFile(ts.URL + "/invalid.pac")).Get("http://example.com")
+	if !errors.Is(err, ErrPACScript) {
+		t.Error(
+			"For", "unsupported function",
+			"expected", ErrPACScript,
+			"got", err,
+		)
+	}
+}
+
+// TestWithPACFileFailures tests a hanging or failing PAC server doesn't
+// block requests and that the file is fetched again after a failure
+func TestWithPACFileFailures(t *testing.T) {
+	t.Log("Sending requests with unavailable PAC file... (expected bounded failures, then proxied request)")
+
+	defer func(timeout time.Duration) { pacFetchTimeout = timeout }(pacFetchTimeout)
+	pacFetchTimeout = 200 * time.Millisecond
+
+	proxy, forwarded := newFakeProxy(t)
+	var fetched int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch atomic.AddInt32(&fetched, 1) {
+		case 1:
+			// hangs past the fetch timeout
+			select {
+			case <-r.Context().Done():
+			case <-time.After(2 * time.Second):
+			}
+		case 2:
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+		default:
+			fmt.Fprintf(w, `function FindProxyForURL(url, host) { return "PROXY %s"; }`, strings.TrimPrefix(proxy.URL, "http://"))
+		}
+	}))
+	defer ts.Close()
+
+	pac := WithPACFile(ts.URL + "/proxy.pac")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	_, err := NewRequest(pac).SetContext(ctx).Get("http://example.com/a")
+	if !errors.Is(err, context.DeadlineExceeded) || time.Since(start) > time.Second {
+		t.Error(
+			"For", "request context",
+			"expected", context.DeadlineExceeded,
+			"got", err, time.Since(start),
+		)
+	}
+
+	tests := []struct {
+		name     string
+		expected error
+	}{
+		{"hanging fetch", context.DeadlineExceeded},
+		{"failed fetch", ErrBadStatus},
+		{"fetched", nil},
+		{"cached", nil},
+	}
+
+	for _, tt := range tests {
+		_, err := NewRequest(pac).Get("http://example.com/a")
+		if !errors.Is(err, tt.expected) {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", err,
+			)
+		}
+	}
+
+	if fetched := atomic.LoadInt32(&fetched); fetched != 3 || len(*forwarded) != 2 {
+		t.Error(
+			"For", "WithPACFile",
+			"expected", "3 fetches and 2 proxied requests",
+			"got", fetched, *forwarded,
+		)
+	}
+}
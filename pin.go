@@ -0,0 +1,89 @@
+package gohttp
+
+import (
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/base64"
+	"errors"
+)
+
+// ErrCertNotPinned is matched by the error of a request to a server without
+// a pinned certificate
+var ErrCertNotPinned = errors.New("gohttp: certificate not pinned")
+
+// CertNotPinnedError is returned when no certificate of the server matches
+// a pin given with WithCertPins. GotFingerprint is the pin of the server
+// certificate, in the format of WithCertPins.
+type CertNotPinnedError struct {
+	GotFingerprint string
+}
+
+func (e *CertNotPinnedError) Error() string {
+	return "gohttp: no certificate of the server is pinned, got " + e.GotFingerprint
+}
+
+// Is reports whether target is ErrCertNotPinned
+func (e *CertNotPinnedError) Is(target error) bool {
+	return target == ErrCertNotPinned
+}
+
+// WithCertPins option only accepts servers with a certificate in their
+// verified chain matching one of pins, even when it is signed by a trusted
+// CA. A pin is the base64 encoded SHA-256 digest of a DER encoded
+// certificate. The certificates are still verified as usual too, when the
+// verification is skipped only the server certificate is checked.
+func WithCertPins(pins []string) OptionFunc {
+	return func(r *Request) {
+		r.tlsOptions = append(r.tlsOptions, func(cfg *tls.Config) {
+			cfg.VerifyPeerCertificate = certPinVerifier(pins, cfg.VerifyPeerCertificate)
+		})
+	}
+}
+
+// CertPin returns the pin of cert for WithCertPins
+func CertPin(cert *x509.Certificate) string {
+	return certPin(cert.Raw)
+}
+
+func certPin(der []byte) string {
+	sum := sha256.Sum256(der)
+	return base64.StdEncoding.EncodeToString(sum[:])
+}
+
+// certPinVerifier returns a VerifyPeerCertificate function checking the
+// chain against pins after next, if any
+func certPinVerifier(pins []string, next func([][]byte, [][]*x509.Certificate) error) func([][]byte, [][]*x509.Certificate) error {
+	pinned := make(map[string]bool, len(pins))
+	for _, pin := range pins {
+		pinned[pin] = true
+	}
+
+	return func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if next != nil {
+			if err := next(rawCerts, verifiedChains); err != nil {
+				return err
+			}
+		}
+
+		// the certificates sent but not part of a verified chain prove
+		// nothing, anyone can send a pinned certificate along with theirs
+		for _, chain := range verifiedChains {
+			for _, cert := range chain {
+				if pinned[certPin(cert.Raw)] {
+					return nil
+				}
+			}
+		}
+		if len(verifiedChains) == 0 && len(rawCerts) > 0 && pinned[certPin(rawCerts[0])] {
+			// the chain is not verified, only the leaf can be trusted
+			return nil
+		}
+
+		err := &CertNotPinnedError{}
+		if len(rawCerts) > 0 {
+			err.GotFingerprint = certPin(rawCerts[0])
+		}
+		return err
+	}
+}
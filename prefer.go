@@ -0,0 +1,189 @@
+package gohttp
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Preference is a preference of the Prefer header of RFC 7240, e.g.
+// return=minimal, with optional parameters
+type Preference struct {
+	Name   string
+	Value  string
+	Params map[string]string
+}
+
+// ReturnMinimal asks the server to respond without a representation
+func ReturnMinimal() Preference {
+	return Preference{Name: "return", Value: "minimal"}
+}
+
+// ReturnRepresentation asks the server to respond with a representation
+// of the resource
+func ReturnRepresentation() Preference {
+	return Preference{Name: "return", Value: "representation"}
+}
+
+// RespondAsync asks the server to respond 202 Accepted instead of waiting
+// for the request to be processed
+func RespondAsync() Preference {
+	return Preference{Name: "respond-async"}
+}
+
+// Wait asks the server to process the request within d, in seconds
+func Wait(d time.Duration) Preference {
+	return Preference{Name: "wait", Value: strconv.FormatInt(int64(d/time.Second), 10)}
+}
+
+// MaxPageSize asks an OData server for pages of at most n entries
+func MaxPageSize(n int) Preference {
+	return Preference{Name: "odata.maxpagesize", Value: strconv.Itoa(n)}
+}
+
+// String formats the preference for the Prefer header
+func (p Preference) String() string {
+	var b strings.Builder
+	b.WriteString(p.Name)
+	if p.Value != "" {
+		b.WriteByte('=')
+		b.WriteString(quoteIfNeeded(p.Value))
+	}
+
+	keys := make([]string, 0, len(p.Params))
+	for key := range p.Params {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		b.WriteString("; ")
+		b.WriteString(key)
+		if val := p.Params[key]; val != "" {
+			b.WriteByte('=')
+			b.WriteString(quoteIfNeeded(val))
+		}
+	}
+
+	return b.String()
+}
+
+// Prefer method adds prefs to the Prefer header. A Prefer header given
+// with Headers still wins.
+func (req *Request) Prefer(prefs ...Preference) *Request {
+	for _, p := range prefs {
+		if req.prefer != "" {
+			req.prefer += ", "
+		}
+		req.prefer += p.String()
+	}
+	return req
+}
+
+// PreferenceApplied returns the preferences the server honored according
+// to the Preference-Applied header, names are lower-cased. It is nil if
+// Response is not returned from a Request.
+func (res *Response) PreferenceApplied() []Preference {
+	if res.resp == nil {
+		return nil
+	}
+
+	var prefs []Preference
+	for _, header := range res.resp.Header.Values("Preference-Applied") {
+		for _, elem := range splitQuoted(header, ',') {
+			if p, ok := parsePreference(elem); ok {
+				prefs = append(prefs, p)
+			}
+		}
+	}
+	return prefs
+}
+
+// parsePreference parses a name[=value] *(; param[=value]) element
+func parsePreference(elem string) (Preference, bool) {
+	parts := splitQuoted(elem, ';')
+	if len(parts) == 0 {
+		return Preference{}, false
+	}
+
+	var p Preference
+	p.Name, p.Value = parsePreferenceParam(parts[0])
+	if p.Name == "" {
+		return p, false
+	}
+
+	for _, part := range parts[1:] {
+		key, val := parsePreferenceParam(part)
+		if key == "" {
+			continue
+		}
+		if p.Params == nil {
+			p.Params = map[string]string{}
+		}
+		p.Params[key] = val
+	}
+
+	return p, true
+}
+
+func parsePreferenceParam(s string) (key, val string) {
+	s = strings.TrimSpace(s)
+	if i := strings.IndexByte(s, '='); i >= 0 {
+		s, val = s[:i], unquoteString(strings.TrimSpace(s[i+1:]))
+	}
+	return strings.ToLower(strings.TrimSpace(s)), val
+}
+
+// splitQuoted splits s at sep outside of quoted strings, empty elements are
+// dropped
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	start, quoted := 0, false
+	for i := 0; i < len(s); i++ {
+		switch {
+		case quoted && s[i] == '\\':
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case !quoted && s[i] == sep:
+			parts = appendNonEmpty(parts, s[start:i])
+			start = i + 1
+		}
+	}
+	return appendNonEmpty(parts, s[start:])
+}
+
+func appendNonEmpty(parts []string, s string) []string {
+	if s = strings.TrimSpace(s); s != "" {
+		parts = append(parts, s)
+	}
+	return parts
+}
+
+// unquoteString returns the content of a quoted-string, other values are
+// returned as is
+func unquoteString(s string) string {
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// quoteIfNeeded quotes s unless it is a token
+func quoteIfNeeded(s string) string {
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if c <= ' ' || c >= 0x7f || strings.IndexByte(`"(),/:;<=>?@[\]{}`, c) >= 0 {
+			return quoteString(s)
+		}
+	}
+	return s
+}
@@ -0,0 +1,85 @@
+package gohttp
+
+import (
+	"fmt"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// TestPrefer tests the Prefer header built from preferences
+func TestPrefer(t *testing.T) {
+	t.Log("Sending GET requests with preferences... (expected Prefer header)")
+
+	ts := newHeaderServer(t, "Prefer")
+
+	tests := []struct {
+		prefs    []Preference
+		expected string
+	}{
+		{[]Preference{ReturnMinimal()}, "return=minimal"},
+		{[]Preference{ReturnRepresentation(), RespondAsync(), Wait(10 * time.Second)}, "return=representation, respond-async, wait=10"},
+		{[]Preference{MaxPageSize(50)}, "odata.maxpagesize=50"},
+		{[]Preference{{Name: "foo", Value: "a b", Params: map[string]string{"z": "1", "a": `say "hi"`, "flag": ""}}},
+			`foo="a b"; a="say \"hi\""; flag; z=1`},
+	}
+
+	for _, tt := range tests {
+		resp, err := NewRequest().Prefer(tt.prefs...).Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if body, _ := resp.GetBodyAsString(); body != tt.expected {
+			t.Error(
+				"For", "Prefer",
+				"expected", tt.expected,
+				"got", body,
+			)
+		}
+	}
+}
+
+// TestPreferenceApplied tests parsing the Preference-Applied header
+func TestPreferenceApplied(t *testing.T) {
+	t.Log("Parsing Preference-Applied headers... (expected honored preferences)")
+
+	tests := []struct {
+		header   []string
+		expected string
+	}{
+		{nil, "[]"},
+		{[]string{"return=minimal"}, "[return=minimal]"},
+		{[]string{"Return=minimal, respond-async", "wait=10"}, "[return=minimal respond-async wait=10]"},
+		{[]string{`foo="a, b; c"; X="say \"hi\"", bar`}, `[foo="a, b; c"; x="say \"hi\"" bar]`},
+		{[]string{", ;, =x"}, "[]"},
+	}
+
+	for _, tt := range tests {
+		res := newTestResponse(200, http.Header{"Preference-Applied": tt.header}, "")
+		if got := fmt.Sprint(res.PreferenceApplied()); got != tt.expected {
+			t.Error(
+				"For", tt.header,
+				"expected", tt.expected,
+				"got", got,
+			)
+		}
+	}
+
+	prefs := newTestResponse(200, http.Header{"Preference-Applied": {`foo="a, b"; x="say \"hi\""`}}, "").PreferenceApplied()
+	if len(prefs) != 1 || prefs[0].Value != "a, b" || prefs[0].Params["x"] != `say "hi"` {
+		t.Error(
+			"For", "quoted values",
+			"expected", `a, b and say "hi"`,
+			"got", prefs,
+		)
+	}
+
+	if prefs := (&Response{}).PreferenceApplied(); prefs != nil {
+		t.Error(
+			"For", "Response without http response",
+			"expected", nil,
+			"got", prefs,
+		)
+	}
+}
@@ -0,0 +1,36 @@
+package gohttp
+
+import "io"
+
+// progressReader reports the number of bytes read from r to fn after every
+// successful read, and once more when r is exhausted. total is -1 when the
+// size is unknown.
+type progressReader struct {
+	r       io.Reader
+	total   int64
+	written int64
+	done    bool
+	fn      func(written, total int64)
+}
+
+func (p *progressReader) Read(b []byte) (int, error) {
+	n, err := p.r.Read(b)
+	if n > 0 {
+		p.written += int64(n)
+		p.fn(p.written, p.total)
+	}
+	if err == io.EOF && !p.done {
+		p.done = true
+		p.fn(p.written, p.total)
+	}
+	return n, err
+}
+
+// Close closes r when it is an io.Closer, so a wrapped request or response
+// body is still closed
+func (p *progressReader) Close() error {
+	if c, ok := p.r.(io.Closer); ok {
+		return c.Close()
+	}
+	return nil
+}
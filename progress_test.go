@@ -0,0 +1,156 @@
+package gohttp
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// slowReader returns at most three bytes per read
+type slowReader struct {
+	r *strings.Reader
+}
+
+func (s *slowReader) Read(b []byte) (int, error) {
+	time.Sleep(time.Millisecond)
+	if len(b) > 3 {
+		b = b[:3]
+	}
+	return s.r.Read(b)
+}
+
+// progressCalls records the calls of a progress callback
+type progressCalls struct {
+	written []int64
+	total   int64
+}
+
+func (p *progressCalls) record(written, total int64) {
+	p.written = append(p.written, written)
+	p.total = total
+}
+
+// check reports whether progress increased up to size and was reported a
+// final time
+func (p *progressCalls) check(size int64) bool {
+	n := len(p.written)
+	if n < 2 || p.written[n-1] != size || p.written[n-2] != size {
+		return false
+	}
+	for i := 1; i < n-1; i++ {
+		if p.written[i] <= p.written[i-1] {
+			return false
+		}
+	}
+	return true
+}
+
+// TestUploadFromReaderProgress tests WithUploadProgress with a reader
+func TestUploadFromReaderProgress(t *testing.T) {
+	t.Log("Uploading from slow reader... (expected increasing progress up to the body size)")
+
+	ts := newEchoServer(t)
+
+	var calls progressCalls
+	// the length of a slow reader is unknown until it is buffered
+	req := NewRequest(WithUploadProgress(calls.record), WithBufferedUploads())
+
+	resp, err := req.UploadFromReader(MultipartParam{
+		FieldName: "file",
+		FileName:  "file.txt",
+		FileBody:  &slowReader{strings.NewReader("hello progress")},
+	}).Post(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := resp.GetBodyAsByte()
+	if size := int64(len(body)); !calls.check(size) || calls.total != size {
+		t.Error(
+			"For", "UploadFromReader",
+			"expected", "progress ending twice at", size,
+			"got", calls.written, calls.total,
+		)
+	}
+}
+
+// TestUploadProgress tests WithUploadProgress with a file
+func TestUploadProgress(t *testing.T) {
+	t.Log("Uploading file... (expected total from body size)")
+
+	ts := newEchoServer(t)
+
+	file := filepath.Join(t.TempDir(), "upload.txt")
+	if err := ioutil.WriteFile(file, []byte("file content"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	var calls progressCalls
+	resp, err := NewRequest(WithUploadProgress(calls.record)).Upload("file", file).Post(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := resp.GetBodyAsByte()
+	if size := int64(len(body)); !calls.check(size) || calls.total != size {
+		t.Error(
+			"For", "Upload",
+			"expected", "progress ending twice at", size,
+			"got", calls.written, calls.total,
+		)
+	}
+}
+
+// TestUploadProgressUnknownSize tests WithUploadProgress with BodyWriterTo
+func TestUploadProgressUnknownSize(t *testing.T) {
+	t.Log("Uploading streamed body... (expected total -1)")
+
+	ts := newEchoServer(t)
+
+	var calls progressCalls
+	_, err := NewRequest(WithUploadProgress(calls.record)).BodyWriterTo(lines{"a", "b"}, "text/plain").Post(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if !calls.check(4) || calls.total != -1 {
+		t.Error(
+			"For", "BodyWriterTo",
+			"expected", "progress ending twice at 4 of -1",
+			"got", calls.written, calls.total,
+		)
+	}
+}
+
+// TestDownloadProgress tests WithDownloadProgress
+func TestDownloadProgress(t *testing.T) {
+	t.Log("Downloading slowly written body... (expected total from Content-Length)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Length", "9")
+		for _, part := range []string{"abc", "def", "ghi"} {
+			w.Write([]byte(part))
+			w.(http.Flusher).Flush()
+			time.Sleep(5 * time.Millisecond)
+		}
+	}))
+	defer ts.Close()
+
+	var calls progressCalls
+	resp, err := NewRequest(WithDownloadProgress(calls.record)).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body, _ := resp.GetBodyAsString(); body != "abcdefghi" || !calls.check(9) || calls.total != 9 {
+		t.Error(
+			"For", "WithDownloadProgress",
+			"expected", "progress ending twice at 9 of 9",
+			"got", calls.written, calls.total,
+		)
+	}
+}
@@ -0,0 +1,102 @@
+package gohttp
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// ProxyRule picks the proxy of the requests matching Pattern, see
+// WithProxyRouter
+type ProxyRule struct {
+	// Pattern is either a glob, where * matches any characters and ? one
+	// character, or a regular expression between slashes, e.g.
+	// "/^https://.*\.internal/". A glob is matched against the host of the
+	// request, or against its whole URL when it contains "://". A regular
+	// expression is matched against the whole URL.
+	Pattern string
+	// ProxyURL is the http, https or socks5 proxy, empty to connect
+	// directly
+	ProxyURL string
+}
+
+// compiledProxyRule is a ProxyRule ready to be matched
+type compiledProxyRule struct {
+	re       *regexp.Regexp
+	matchURL bool
+	proxy    *url.URL
+}
+
+// WithProxyRouter option sends every request through the proxy of the first
+// rule matching it. Requests matching no rule use the proxy environment
+// variables. An invalid pattern or proxy URL is returned when the request
+// is sent.
+func WithProxyRouter(rules []ProxyRule) OptionFunc {
+	return func(r *Request) {
+		compiled := make([]compiledProxyRule, 0, len(rules))
+		for _, rule := range rules {
+			c, err := compileProxyRule(rule)
+			if err != nil {
+				r.setErr(err)
+				return
+			}
+			compiled = append(compiled, c)
+		}
+
+		r.proxy = func(req *http.Request) (*url.URL, error) {
+			for _, rule := range compiled {
+				target := req.URL.Hostname()
+				if rule.matchURL {
+					target = req.URL.String()
+				}
+				if rule.re.MatchString(target) {
+					return rule.proxy, nil
+				}
+			}
+			return http.ProxyFromEnvironment(req)
+		}
+		r.noProxy = false
+	}
+}
+
+func compileProxyRule(rule ProxyRule) (compiledProxyRule, error) {
+	var c compiledProxyRule
+	var err error
+
+	if len(rule.Pattern) > 1 && strings.HasPrefix(rule.Pattern, "/") && strings.HasSuffix(rule.Pattern, "/") {
+		c.matchURL = true
+		c.re, err = regexp.Compile(rule.Pattern[1 : len(rule.Pattern)-1])
+	} else {
+		c.matchURL = strings.Contains(rule.Pattern, "://")
+		c.re, err = globRegexp(rule.Pattern)
+	}
+	if err != nil {
+		return c, fmt.Errorf("gohttp: invalid proxy rule pattern %q: %w", rule.Pattern, err)
+	}
+
+	if rule.ProxyURL != "" {
+		c.proxy, err = parseProxyURL(rule.ProxyURL)
+	}
+	return c, err
+}
+
+// globRegexp returns the regular expression of a glob where * matches any
+// characters and ? one character, matched case insensitively
+func globRegexp(glob string) (*regexp.Regexp, error) {
+	var b strings.Builder
+	b.WriteString("(?i)^")
+	for _, r := range glob {
+		switch r {
+		case '*':
+			b.WriteString(".*")
+		case '?':
+			b.WriteString(".")
+		default:
+			b.WriteString(regexp.QuoteMeta(string(r)))
+		}
+	}
+	b.WriteString("$")
+	return regexp.Compile(b.String())
+}
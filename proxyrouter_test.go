@@ -0,0 +1,61 @@
+package gohttp
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+// TestWithProxyRouter tests requests use the proxy of the first matching rule
+func TestWithProxyRouter(t *testing.T) {
+	t.Log("Sending requests with proxy rules... (expected proxied and direct requests)")
+
+	proxy, forwarded := newFakeProxy(t)
+	direct := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		direct++
+	}))
+	defer ts.Close()
+
+	router := WithProxyRouter([]ProxyRule{
+		{Pattern: "/^http://127\\.0\\.0\\.1:/"},
+		{Pattern: "*.EXAMPLE.com", ProxyURL: proxy.URL},
+		{Pattern: "http://example.org/private/*", ProxyURL: proxy.URL},
+	})
+
+	for _, u := range []string{"http://api.example.com/a", ts.URL + "/b", "http://example.org/private/c"} {
+		if _, err := NewRequest(router).Get(u); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	if direct != 1 || len(*forwarded) != 2 || (*forwarded)[0] != "http://api.example.com/a" {
+		t.Error(
+			"For", "WithProxyRouter",
+			"expected", "1 direct request and 2 proxied",
+			"got", direct, *forwarded,
+		)
+	}
+}
+
+// TestWithProxyRouterInvalidRule tests invalid rules are returned when the
+// request is sent
+func TestWithProxyRouterInvalidRule(t *testing.T) {
+	t.Log("Sending request with invalid proxy rules... (expected errors)")
+
+	tests := []ProxyRule{
+		{Pattern: "/(/", ProxyURL: "http://proxy:3128"},
+		{Pattern: "*", ProxyURL: "ftp://proxy:21"},
+	}
+
+	for _, rule := range tests {
+		_, err := NewRequest(WithProxyRouter([]ProxyRule{rule})).Get("http://example.com")
+		if err == nil {
+			t.Error(
+				"For", rule,
+				"expected", "error",
+				"got", err,
+			)
+		}
+	}
+}
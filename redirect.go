@@ -0,0 +1,93 @@
+package gohttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+// defaultMaxRedirects is the number of redirects followed without a
+// MaxRedirects policy
+const defaultMaxRedirects = 10
+
+// ErrTooManyRedirects is returned when a request is redirected more often
+// than allowed
+var ErrTooManyRedirects = errors.New("gohttp: too many redirects")
+
+// RedirectPolicy configures which redirects are followed, see
+// WithRedirectPolicy
+type RedirectPolicy func(*redirectPolicy)
+
+type redirectPolicy struct {
+	disabled bool
+	max      int
+	maxSet   bool
+	keepAuth bool
+	hooks    []func(req *http.Request, via []*http.Request) error
+}
+
+// NoRedirect policy follows no redirect, the 3xx response is returned as
+// response instead
+func NoRedirect() RedirectPolicy {
+	return func(p *redirectPolicy) {
+		p.disabled = true
+	}
+}
+
+// MaxRedirects policy follows at most n redirects instead of 10, the
+// request fails with ErrTooManyRedirects after that
+func MaxRedirects(n int) RedirectPolicy {
+	return func(p *redirectPolicy) {
+		p.max, p.maxSet = n, true
+	}
+}
+
+// OnRedirect policy calls fn before every redirect is followed, e.g. to
+// log it, via holds the requests made so far, oldest first. An error
+// returned by fn stops the request with it.
+func OnRedirect(fn func(req *http.Request, via []*http.Request) error) RedirectPolicy {
+	return func(p *redirectPolicy) {
+		p.hooks = append(p.hooks[:len(p.hooks):len(p.hooks)], fn)
+	}
+}
+
+// KeepAuthOnRedirect policy sends the Authorization header of the first
+// request to other hosts too, by default it is removed
+func KeepAuthOnRedirect() RedirectPolicy {
+	return func(p *redirectPolicy) {
+		p.keepAuth = true
+	}
+}
+
+// checkRedirect is the http.Client.CheckRedirect of a client built for the
+// request
+func (req *Request) checkRedirect(r *http.Request, via []*http.Request) error {
+	p := req.redirect
+	if p.disabled {
+		return http.ErrUseLastResponse
+	}
+
+	max := defaultMaxRedirects
+	if p.maxSet {
+		max = p.max
+	}
+	if len(via) > max {
+		return fmt.Errorf("%w: stopped after %d", ErrTooManyRedirects, max)
+	}
+
+	// http.Client only removes it for other domains, not other subdomains
+	// or ports
+	if r.URL.Host != via[0].URL.Host {
+		r.Header.Del("Authorization")
+		if auth := via[0].Header.Get("Authorization"); p.keepAuth && auth != "" {
+			r.Header.Set("Authorization", auth)
+		}
+	}
+
+	for _, hook := range p.hooks {
+		if err := hook(r, via); err != nil {
+			return err
+		}
+	}
+	return nil
+}
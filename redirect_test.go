@@ -0,0 +1,147 @@
+package gohttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+)
+
+// newRedirectServer returns a server redirecting /n to /n-1 until /0
+func newRedirectServer(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n, _ := strconv.Atoi(strings.TrimPrefix(r.URL.Path, "/"))
+		if n > 0 {
+			http.Redirect(w, r, fmt.Sprintf("/%d", n-1), http.StatusFound)
+			return
+		}
+		w.Write([]byte("done"))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// TestRedirectPolicy tests the redirect limits
+func TestRedirectPolicy(t *testing.T) {
+	t.Log("Following redirect chains with policies... (expected limits)")
+
+	ts := newRedirectServer(t)
+
+	tests := []struct {
+		name     string
+		path     string
+		policies []RedirectPolicy
+		status   int
+		err      error
+	}{
+		{"default", "/10", nil, 200, nil},
+		{"default exceeded", "/11", nil, 0, ErrTooManyRedirects},
+		{"no redirect", "/3", []RedirectPolicy{NoRedirect()}, 302, nil},
+		{"max", "/2", []RedirectPolicy{MaxRedirects(2)}, 200, nil},
+		{"max exceeded", "/3", []RedirectPolicy{MaxRedirects(2)}, 0, ErrTooManyRedirects},
+		{"max above default", "/12", []RedirectPolicy{MaxRedirects(12)}, 200, nil},
+	}
+
+	for _, tt := range tests {
+		resp, err := NewRequest(WithRedirectPolicy(tt.policies...)).Get(ts.URL + tt.path)
+		if tt.err != nil {
+			if !errors.Is(err, tt.err) {
+				t.Error(
+					"For", tt.name,
+					"expected", tt.err,
+					"got", err,
+				)
+			}
+			continue
+		}
+
+		if err != nil || resp.GetStatusCode() != tt.status {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.status,
+				"got", err,
+			)
+		}
+	}
+
+	resp, err := NewRequest(WithRedirectPolicy(NoRedirect())).Get(ts.URL + "/1")
+	if err != nil || resp.GetResp().Header.Get("Location") != "/0" {
+		t.Error(
+			"For", "NoRedirect Location",
+			"expected", "/0",
+			"got", err,
+		)
+	}
+}
+
+// TestOnRedirect tests OnRedirect observes and refuses redirects
+func TestOnRedirect(t *testing.T) {
+	t.Log("Following redirects with hook... (expected hops and refusal)")
+
+	ts := newRedirectServer(t)
+
+	var hops []string
+	refused := errors.New("refused")
+	_, err := NewRequest(WithRedirectPolicy(OnRedirect(func(r *http.Request, via []*http.Request) error {
+		hops = append(hops, r.URL.Path)
+		if r.URL.Path == "/1" {
+			return refused
+		}
+		return nil
+	}))).Get(ts.URL + "/3")
+
+	if !errors.Is(err, refused) || strings.Join(hops, ",") != "/2,/1" {
+		t.Error(
+			"For", "OnRedirect",
+			"expected", "/2,/1 and refused",
+			"got", hops, err,
+		)
+	}
+}
+
+// TestRedirectAuthorization tests Authorization is only kept for the same host
+func TestRedirectAuthorization(t *testing.T) {
+	t.Log("Redirecting to other host with Authorization... (expected stripped header)")
+
+	other := newHeaderServer(t, "Authorization")
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/same" {
+			http.Redirect(w, r, "/echo", http.StatusFound)
+			return
+		}
+		if r.URL.Path == "/echo" {
+			w.Write([]byte(r.Header.Get("Authorization")))
+			return
+		}
+		http.Redirect(w, r, other.URL, http.StatusFound)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		path     string
+		policies []RedirectPolicy
+		expected string
+	}{
+		{"/same", nil, "Bearer secret"},
+		{"/other", nil, ""},
+		{"/other", []RedirectPolicy{KeepAuthOnRedirect()}, "Bearer secret"},
+	}
+
+	for _, tt := range tests {
+		resp, err := NewRequest(WithRedirectPolicy(tt.policies...)).AuthToken("secret").Get(ts.URL + tt.path)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if body, _ := resp.GetBodyAsString(); body != tt.expected {
+			t.Error(
+				"For", tt.path, len(tt.policies),
+				"expected", tt.expected,
+				"got", body,
+			)
+		}
+	}
+}
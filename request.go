@@ -3,51 +3,127 @@ package gohttp
 import (
 	"bytes"
 	"context"
+	"crypto/tls"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/ioutil"
+	"mime"
 	"mime/multipart"
 	"net/http"
+	"net/textproto"
 	"net/url"
 	"os"
+	"path/filepath"
+	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 )
 
+// Version is the version of the package, it is sent in the default
+// User-Agent
+const Version = "0.1.0"
+
+// defaultUserAgent is sent when no User-Agent is set
+const defaultUserAgent = "gohttp/" + Version
+
 type (
-	BeforeRequestHook func(*Request) error
-	AfterResponseHook func(*Request, *Response) error
-	ErrorHook         func(*Request, error)
+	BeforeRequestHook     func(*Request) error
+	BeforeHTTPRequestHook func(*http.Request) error
+	AfterResponseHook     func(*Request, *Response) error
+	ErrorHook             func(*Request, error)
+	ClientCreatedHook     func(*http.Client)
+	ConnClosedHook        func(PoolStats)
 )
 
-// Request is a request type
+// Request is a request type. A configured request can be sent many times,
+// and concurrently, since every send builds its body anew. The readers
+// given to BodyReader and UploadsFromReader are the exception, they are
+// shared by every send, so a request with one must not be sent
+// concurrently, see BodyReaderFunc. Configuring it while it is being sent
+// is not safe, use Clone to send variations.
 type Request struct {
 	transport              *http.Transport
+	roundTripper           http.RoundTripper
+	baseURL                *url.URL
+	redirect               redirectPolicy
+	devShaping             *devShaping
+	allowDevShaping        bool
 	client                 *http.Client
 	cookie                 http.CookieJar
 	timeout                time.Duration
+	callTimeout            time.Duration
+	connectTimeout         time.Duration
+	readTimeout            time.Duration
 	formVals               *bytes.Buffer
+	bodyWriterTo           io.WriterTo
+	bodyReader             *readerBody
+	compressBody           string
+	expectContinue         bool
+	http1Only              bool
+	http2                  *bool
+	strictTLS              *StrictTLSPolicy
+	tlsConfig              *tls.Config
+	tlsOptions             []func(*tls.Config)
+	skipTLSVerify          *bool
+	logger                 Logger
+	events                 chan<- Event
 	multipartBuffer        bytes.Buffer
-	queryVals              string
+	queryVals              url.Values
+	queryEncoding          *QueryEncodingOptions
 	headers                map[string]string
-	writer                 *multipart.Writer
+	defaultHeaders         map[string]string
+	boundary               string
+	bufferUploads          bool
+	multipart              *multipartStream
 	contentType            string
 	basicUser, basicPasswd string
-	beforeRequestHooks     []BeforeRequestHook
-	afterResponseHooks     []AfterResponseHook
-	errorHooks             []ErrorHook
+	digestUser             string
+	digestPasswd           string
+	authToken              string
+	authTokenProvider      func(context.Context) (string, error)
+	apiKeyHeader, apiKey   string
+	proxy                  func(*http.Request) (*url.URL, error)
+	noProxy                bool
+	unixSocket             string
+	uploadProgress         func(written, total int64)
+	downloadProgress       func(written, total int64)
+	userAgent              string
+	userAgentFunc          func(*Request) string
+	prefer                 string
+	priority               string
+	ifMatch                string
+	middlewares            []Middleware
+	retryCount             int
+	retryBackoff           time.Duration
+	maxRetryAfter          time.Duration
+	attrs                  map[string]interface{}
+	expectations           []expectation
+	contentTypePolicy      contentTypePolicy
+	err                    error
+	state                  *requestState
+	clientSent             *int32
+	stats                  *StatsRecorder
 	ctx                    context.Context
 }
 
-// MultipartParam is a multipart param type
+// MultipartParam is a multipart param type. The Content-Type of the part is
+// ContentType, or else the one of Header, application/octet-stream by
+// default. Header holds other headers of the part, e.g. Content-ID.
 type MultipartParam struct {
-	FieldName string
-	FileName  string
-	FileBody  io.Reader
+	FieldName   string
+	FileName    string
+	FileBody    io.Reader
+	ContentType string
+	Header      textproto.MIMEHeader
 }
 
 // NewRequest returns a new request
 func NewRequest(opts ...Option) *Request {
-	r := &Request{}
+	r := &Request{state: &requestState{}, stats: &StatsRecorder{}, maxRetryAfter: defaultMaxRetryAfter}
+	r.state.hooks.Store(&hookSet{})
 	for _, o := range opts {
 		o.apply(r)
 	}
@@ -56,20 +132,95 @@ func NewRequest(opts ...Option) *Request {
 
 // createClient create request client
 func (req *Request) createClient() *http.Client {
-	tr := req.transport
-	if tr == nil {
-		tr = http.DefaultTransport.(*http.Transport)
+	req.state.mu.Lock()
+	created := req.client == nil
+	if created {
+		req.state.transport = req.baseTransport()
+		req.client = &http.Client{
+			Transport:     req.wrapMiddlewares(req.state.transport),
+			Timeout:       req.timeout,
+			Jar:           req.cookie,
+			CheckRedirect: req.checkRedirect,
+		}
 	}
+	client := req.client
+	req.state.mu.Unlock()
 
-	if req.client == nil {
-		req.client = &http.Client{
-			Transport: tr,
-			Timeout:   req.timeout,
-			Jar:       req.cookie,
+	if created {
+		req.hooks().executeClientCreated(client)
+	}
+
+	return client
+}
+
+// Clone returns a copy of the request to be configured and sent without
+// affecting req, e.g. to send variations of a template concurrently. The
+// headers, body, multipart form and attributes are copied, hooks
+// registered so far are kept. The copy shares the http client of req, which
+// is built if it wasn't yet, so connections are pooled. Options building
+// the client have no effect on the copy. The readers of BodyReader and
+// UploadsFromReader are shared too, a copy can't be sent once req was.
+func (req *Request) Clone() *Request {
+	client := req.createClient()
+	c := req.clone()
+	c.client = client
+	return c
+}
+
+// clone returns a copy of the request which can be configured and sent
+// without affecting req. Hooks registered so far are kept, a client built
+// by req is not, so the copy builds its own from its configuration.
+func (req *Request) clone() *Request {
+	req.state.mu.Lock()
+	c := *req
+	if req.state.transport != nil {
+		c.client = nil
+	}
+	req.state.mu.Unlock()
+
+	c.state = &requestState{}
+	c.state.hooks.Store(req.hooks())
+
+	c.multipartBuffer = bytes.Buffer{}
+	c.multipartBuffer.Write(req.multipartBuffer.Bytes())
+
+	if req.formVals == &req.multipartBuffer {
+		c.formVals = &c.multipartBuffer
+	} else if req.formVals != nil {
+		c.formVals = bytes.NewBuffer(append([]byte(nil), req.formVals.Bytes()...))
+	}
+
+	if req.queryVals != nil {
+		c.queryVals = copyValues(req.queryVals)
+	}
+	if req.headers != nil {
+		c.headers = make(map[string]string, len(req.headers))
+		for key, val := range req.headers {
+			c.headers[key] = val
 		}
 	}
+	if req.attrs != nil {
+		c.attrs = make(map[string]interface{}, len(req.attrs))
+		for key, val := range req.attrs {
+			c.attrs[key] = val
+		}
+	}
+
+	// appending to the copy must not write into req's arrays
+	c.middlewares = req.middlewares[:len(req.middlewares):len(req.middlewares)]
+	c.expectations = req.expectations[:len(req.expectations):len(req.expectations)]
+	c.tlsOptions = req.tlsOptions[:len(req.tlsOptions):len(req.tlsOptions)]
+	c.redirect.hooks = req.redirect.hooks[:len(req.redirect.hooks):len(req.redirect.hooks)]
+
+	return &c
+}
 
-	return req.client
+// setErr records a configuration error, it is returned when the request is
+// sent. Only the first error is kept.
+func (req *Request) setErr(err error) {
+	if req.err == nil {
+		req.err = err
+	}
 }
 
 // JSON set json data with request
@@ -80,18 +231,57 @@ func (req *Request) JSON(jsonBody map[string]interface{}) *Request {
 		panic(err)
 	}
 
+	req.resetBody()
 	req.formVals = bytes.NewBuffer(data)
 	req.contentType = "application/json"
 	return req
 }
 
-// FormData set Post request form parameters
+// JSONCanonical set json data with request, encoded following the JSON
+// Canonicalization Scheme of RFC 8785 so the body bytes are reproducible,
+// e.g. for signing them. The bytes can be read with BodyBytes from a hook.
+func (req *Request) JSONCanonical(v interface{}) *Request {
+
+	data, err := canonicalJSON(v)
+	if err != nil {
+		req.setErr(err)
+		return req
+	}
+
+	req.resetBody()
+	req.formVals = bytes.NewBuffer(data)
+	req.contentType = "application/json"
+	return req
+}
+
+// BodyBytes returns the request body which will be sent, it is nil for
+// streamed bodies set with BodyWriterTo or BodyReader
+func (req *Request) BodyBytes() []byte {
+	if req.formVals == nil || req.bodyWriterTo != nil || req.bodyReader != nil {
+		return nil
+	}
+	return req.formVals.Bytes()
+}
+
+// resetBody drops the body set before, whether buffered, streamed or a
+// multipart form, so the last body setter wins
+func (req *Request) resetBody() {
+	req.formVals = nil
+	req.bodyWriterTo = nil
+	req.bodyReader = nil
+	req.multipart = nil
+	req.multipartBuffer.Reset()
+}
+
+// FormData set Post request form parameters, for GET, HEAD and OPTIONS
+// requests they are added to the query string instead
 func (req *Request) FormData(formValues map[string]string) *Request {
 	vals := url.Values{}
 	for key, val := range formValues {
 		vals.Add(key, val)
 	}
 
+	req.resetBody()
 	req.formVals = bytes.NewBuffer([]byte(vals.Encode()))
 	req.contentType = "application/x-www-form-urlencoded"
 
@@ -101,40 +291,238 @@ func (req *Request) FormData(formValues map[string]string) *Request {
 // Body set Post request as body
 func (req *Request) Body(formValues []byte) *Request {
 
+	req.resetBody()
 	req.formVals = bytes.NewBuffer(formValues)
 	req.contentType = "application/octet-stream"
 
 	return req
 }
 
+// BodyWriterTo set request body written by wt when the request is sent,
+// the body is streamed to the server without being buffered. wt is written
+// again for every retry and for 307 and 308 redirects.
+func (req *Request) BodyWriterTo(wt io.WriterTo, contentType string) *Request {
+
+	req.resetBody()
+	req.bodyWriterTo = wt
+	req.contentType = contentType
+
+	return req
+}
+
+// BodyReader set request body read from r when the request is sent, the
+// body is streamed to the server without being buffered. contentLength is
+// sent as Content-Length, a negative one means it is unknown and the body
+// is sent with chunked encoding. A reader implementing io.Seeker is rewound
+// for every retry and for 307 and 308 redirects, another reader can only
+// be sent once and fails with ErrBodyNotReplayable after, see
+// BodyReaderFunc. The Content-Type is application/octet-stream, see
+// ContentType.
+func (req *Request) BodyReader(r io.Reader, contentLength int64) *Request {
+	if contentLength < 0 {
+		contentLength = -1
+	}
+	req.resetBody()
+	req.bodyReader = newReaderBody(r, contentLength)
+	req.contentType = "application/octet-stream"
+
+	return req
+}
+
+// BodyReaderFunc set request body streamed like the one of BodyReader from
+// a reader returned by newBody, which is called for every attempt so the
+// body can be sent again for retries and redirects, and concurrently, e.g.
+// to reopen a file. A reader implementing io.Closer is closed once it was
+// sent.
+func (req *Request) BodyReaderFunc(newBody func() io.Reader, contentLength int64) *Request {
+	req.BodyReader(nil, contentLength)
+	req.bodyReader.newBody = func() (io.ReadCloser, error) {
+		r := newBody()
+		if rc, ok := r.(io.ReadCloser); ok {
+			return rc, nil
+		}
+		return ioutil.NopCloser(r), nil
+	}
+
+	return req
+}
+
+// BodyFile set request body streamed from the file at path, like the one
+// of BodyReader. The file is opened for every attempt and closed once it
+// was sent, so the request can be sent again, and concurrently. Its size
+// is sent as Content-Length, an error getting it is returned when the
+// request is sent.
+func (req *Request) BodyFile(path string) *Request {
+	info, err := os.Stat(path)
+	if err != nil {
+		req.setErr(err)
+		return req
+	}
+
+	req.BodyReader(nil, info.Size())
+	req.bodyReader.newBody = func() (io.ReadCloser, error) {
+		return os.Open(path)
+	}
+
+	return req
+}
+
+// ContentType set the Content-Type of the request body, e.g. after
+// BodyReader or Body
+func (req *Request) ContentType(contentType string) *Request {
+	req.contentType = contentType
+	return req
+}
+
 // Text is send text data with post request
 func (req *Request) Text(formValues string) *Request {
 
+	req.resetBody()
 	req.formVals = bytes.NewBuffer([]byte(formValues))
 	req.contentType = "text/plain"
 
 	return req
 }
 
-// Query set request query param
+// CompressBody method gzips the body when the request is sent, with a
+// Content-Encoding header. Streamed bodies, e.g. of BodyReader or uploads,
+// are compressed while they are sent, without Content-Length. Requests
+// without a body, e.g. GET and HEAD, are sent as they are. See
+// WithRequestCompression for other encodings.
+func (req *Request) CompressBody() *Request {
+	req.compressBody = "gzip"
+	return req
+}
+
+// Priority method sets the Priority header of RFC 9218, e.g. "u=1, i".
+// urgency goes from 0, the highest priority, to 7, the default is 3. An
+// incremental response is useful to the client before it is complete. An
+// urgency out of range is returned as error when the request is sent.
+func (req *Request) Priority(urgency int, incremental bool) *Request {
+	if urgency < 0 || urgency > 7 {
+		req.setErr(fmt.Errorf("gohttp: priority urgency %d out of range 0 to 7", urgency))
+		return req
+	}
+
+	req.priority = "u=" + strconv.Itoa(urgency)
+	if incremental {
+		req.priority += ", i"
+	}
+	return req
+}
+
+// ExpectContinue sends the request with "Expect: 100-continue", the body is
+// only sent once the server agreed to receive it. A server rejecting the
+// request, e.g. because of missing auth or a too large upload, answers
+// before the body is sent and that response is returned.
+func (req *Request) ExpectContinue() *Request {
+	req.expectContinue = true
+
+	return req
+}
+
+// Query set request query param. They are appended to the query of the
+// URL the request is sent to, a key present in both is sent with the value
+// of the URL first and then the one of Query. Calling it again merges the
+// params, a key given again replaces its values.
 func (req *Request) Query(formValues map[string]string) *Request {
-	vals := url.Values{}
+	if req.queryVals == nil {
+		req.queryVals = url.Values{}
+	}
 	for key, val := range formValues {
-		vals.Add(key, val)
+		req.queryVals.Set(key, val)
 	}
 
-	req.queryVals = vals.Encode()
-	req.contentType = "application/x-www-form-urlencoded"
+	return req
+}
+
+// QueryValues adds all the values of vals to the request query params,
+// keeping the values already set, e.g. for ?tag=a&tag=b
+func (req *Request) QueryValues(vals url.Values) *Request {
+	for key, val := range vals {
+		req.QuerySlice(key, val)
+	}
+
+	return req
+}
+
+// AddQuery adds value to the values of the query param key
+func (req *Request) AddQuery(key, value string) *Request {
+	return req.QuerySlice(key, []string{value})
+}
+
+// QuerySlice adds values, in order, to the values of the query param key
+func (req *Request) QuerySlice(key string, values []string) *Request {
+	if req.queryVals == nil {
+		req.queryVals = url.Values{}
+	}
+	req.queryVals[key] = append(req.queryVals[key], values...)
 
 	return req
 }
 
+// QueryStruct adds the fields of the struct v to the request query params,
+// keeping the values already set, so AddQuery can add more. A field is sent
+// with the name of its url tag, or query tag, like the form tag of
+// FormStruct: nil pointers are omitted, empty values too with omitempty,
+// slices send repeated keys or one comma separated value with the comma
+// option, e.g. `url:"id,comma"`, and time.Time is sent as RFC 3339 or with
+// the layout of a layout tag, e.g. `url:"since" layout:"2006-01-02"`. A
+// field which can't be encoded is returned as error naming it when the
+// request is sent.
+func (req *Request) QueryStruct(v interface{}) *Request {
+	vals, err := structEncoder{tags: []string{"url", "query"}, what: "query"}.structValues(v)
+	if err != nil {
+		req.setErr(err)
+		return req
+	}
+
+	return req.QueryValues(vals)
+}
+
 // Headers set header information
 func (req *Request) Headers(headerVals map[string]string) *Request {
 	req.headers = headerVals
 	return req
 }
 
+// UserAgent method sets the User-Agent header, by default gohttp/Version
+// is sent. A User-Agent given with Headers still wins.
+func (req *Request) UserAgent(ua string) *Request {
+	req.userAgent = ua
+	return req
+}
+
+// ErrUnsupportedProxyScheme is returned for proxy URLs with a scheme other
+// than http, https or socks5
+var ErrUnsupportedProxyScheme = errors.New("gohttp: unsupported proxy scheme")
+
+// Proxy method sends the request through the proxy at proxyURL. http, https
+// and socks5 proxies are supported, credentials in the URL are sent to http
+// and https proxies in the Proxy-Authorization header. An invalid URL is
+// returned as error when the request is sent. It must be called before the
+// request is first sent.
+func (req *Request) Proxy(proxyURL string) *Request {
+	u, err := parseProxyURL(proxyURL)
+	if err != nil {
+		req.setErr(err)
+		return req
+	}
+
+	req.proxy = http.ProxyURL(u)
+	req.noProxy = false
+	return req
+}
+
+// parseProxyURL parses the URL of a http, https or socks5 proxy
+func parseProxyURL(proxyURL string) (*url.URL, error) {
+	u, err := url.Parse(proxyURL)
+	if err == nil && u.Scheme != "http" && u.Scheme != "https" && u.Scheme != "socks5" {
+		err = fmt.Errorf("%w %q", ErrUnsupportedProxyScheme, u.Scheme)
+	}
+	return u, err
+}
+
 // BasicAuth make basic authentication
 func (req *Request) BasicAuth(username, password string) *Request {
 	req.basicUser = username
@@ -143,96 +531,230 @@ func (req *Request) BasicAuth(username, password string) *Request {
 	return req
 }
 
+// DigestAuth make digest authentication. The request is first sent without
+// credentials, when the server answers 401 with a Digest challenge it is
+// sent once more with the computed Authorization header. Other 401
+// responses are returned as they are.
+func (req *Request) DigestAuth(username, password string) *Request {
+	req.digestUser = username
+	req.digestPasswd = password
+
+	return req
+}
+
+// AuthToken make bearer token authentication. An Authorization header set
+// with Headers takes precedence over it.
+func (req *Request) AuthToken(token string) *Request {
+	req.authToken = token
+
+	return req
+}
+
+// AuthTokenProvider make bearer token authentication with a token returned
+// by provider. The provider is called for every attempt, so retries pick up
+// refreshed tokens, and a provider error aborts the request. An
+// Authorization header set with Headers takes precedence over it.
+func (req *Request) AuthTokenProvider(provider func(ctx context.Context) (string, error)) *Request {
+	req.authTokenProvider = provider
+
+	return req
+}
+
+// APIKeyAuth make API key authentication, sending key in the header, e.g.
+// X-API-Key. It can be used along with BasicAuth or AuthToken. A header
+// with the same name set with Headers takes precedence over it.
+func (req *Request) APIKeyAuth(header, key string) *Request {
+	req.apiKeyHeader = header
+	req.apiKey = key
+
+	return req
+}
+
+// APIKeyQuery make API key authentication, sending key in the query
+// param, e.g. api_key. The key replaces the values of param set so far.
+func (req *Request) APIKeyQuery(param, key string) *Request {
+	return req.Query(map[string]string{param: key})
+}
+
+// Do sends the request with method, which is upper-cased. Any method is
+// allowed, e.g. PROPFIND or MKCOL for WebDAV.
+func (req *Request) Do(method, url string) (*Response, error) {
+	return req.makeRequest(strings.ToUpper(method), url, req.formVals)
+}
+
 // Get is a get http request
 func (req *Request) Get(url string) (*Response, error) {
-	return req.makeRequest(http.MethodGet, url, req.formVals)
+	return req.Do(http.MethodGet, url)
 }
 
 // Post is a post http request
 func (req *Request) Post(url string) (*Response, error) {
-	return req.makeRequest(http.MethodPost, url, req.formVals)
+	return req.Do(http.MethodPost, url)
 }
 
 // Put is a put http request
 func (req *Request) Put(url string) (*Response, error) {
-	return req.makeRequest(http.MethodPut, url, req.formVals)
+	return req.Do(http.MethodPut, url)
 }
 
 // Patch is a patch http request
 func (req *Request) Patch(url string) (*Response, error) {
-	return req.makeRequest(http.MethodPatch, url, req.formVals)
+	return req.Do(http.MethodPatch, url)
 }
 
 // Delete is a delete http request
 func (req *Request) Delete(url string) (*Response, error) {
-	return req.makeRequest(http.MethodDelete, url, req.formVals)
+	return req.Do(http.MethodDelete, url)
 }
 
 // Head is a head http request
 func (req *Request) Head(url string) (*Response, error) {
-	return req.makeRequest(http.MethodHead, url, req.formVals)
+	return req.Do(http.MethodHead, url)
 }
 
 // Options is a options http request
 func (req *Request) Options(url string) (*Response, error) {
-	return req.makeRequest(http.MethodOptions, url, req.formVals)
+	return req.Do(http.MethodOptions, url)
+}
+
+// MultipartBoundary sets the boundary of the multipart form, e.g. for
+// services requiring a known one or golden file tests, instead of a random
+// one. It must be called before any part is added and be a valid boundary,
+// 1 to 70 characters allowed by RFC 2046, otherwise the error is returned
+// when the request is sent.
+func (req *Request) MultipartBoundary(boundary string) *Request {
+	if req.multipart != nil || req.multipartBuffer.Len() > 0 {
+		req.setErr(fmt.Errorf("gohttp: multipart boundary %q set after parts were added", boundary))
+		return req
+	}
+	if err := multipart.NewWriter(ioutil.Discard).SetBoundary(boundary); err != nil {
+		req.setErr(fmt.Errorf("gohttp: multipart boundary %q: %w", boundary, err))
+		return req
+	}
+	req.boundary = boundary
+	return req
 }
 
 // MultipartFormData add form data in multipart request
 func (req *Request) MultipartFormData(formData map[string]string) *Request {
-	if req.writer == nil {
-		req.writer = multipart.NewWriter(&req.multipartBuffer)
+	if !req.bufferUploads {
+		parts := make([]multipartPart, 0, len(formData))
+		for key, val := range formData {
+			parts = append(parts, multipartPart{fieldName: key, value: val})
+		}
+		req.addStreamedParts(parts...)
+		return req
 	}
 
 	for key, val := range formData {
-		req.writer.WriteField(key, val)
+		req.startPart(&multipartPart{fieldName: key})
+		req.multipartBuffer.WriteString(val)
+	}
+	return req
+}
+
+// MultipartField adds a field with value to the multipart form, with a
+// Content-Type header unless contentType is empty, e.g. for a JSON
+// metadata part
+func (req *Request) MultipartField(fieldName, value, contentType string) *Request {
+	part := multipartPart{fieldName: fieldName, contentType: contentType, value: value}
+	if !req.bufferUploads {
+		req.addStreamedParts(part)
+		return req
 	}
+
+	req.startPart(&part)
+	req.multipartBuffer.WriteString(value)
 	return req
 }
 
-// Upload upload a single file
+// Upload upload a single file, like UploadFile with the base name of file
+// and a detected Content-Type. It panics when the file can't be read.
 func (req *Request) Upload(name, file string) *Request {
-	if req.writer == nil {
-		req.writer = multipart.NewWriter(&req.multipartBuffer)
+	if err := req.uploadFile(name, file, "", ""); err != nil {
+		panic(err)
 	}
+	return req
+}
+
+// UploadFile uploads the file at filePath as fileName, or as the base name
+// of filePath when fileName is empty so local directories are not sent.
+// The Content-Type of the part is contentType, when empty it is detected
+// from the extension of the name or else from the first 512 bytes of the
+// file. The file is opened and read while the request is sent, every time
+// it is sent, unless WithBufferedUploads is used. An error reading the file
+// is returned when the request is sent.
+func (req *Request) UploadFile(fieldName, filePath, fileName, contentType string) *Request {
+	if err := req.uploadFile(fieldName, filePath, fileName, contentType); err != nil {
+		req.setErr(err)
+	}
+	return req
+}
 
-	f, err := os.Open(file)
+func (req *Request) uploadFile(fieldName, filePath, fileName, contentType string) error {
+	if fileName == "" {
+		fileName = filepath.Base(filePath)
+	}
+	if contentType == "" {
+		contentType = mime.TypeByExtension(filepath.Ext(fileName))
+	}
+
+	f, err := os.Open(filePath)
 	if err != nil {
-		panic(err)
+		return err
 	}
 	defer f.Close()
 
-	// Add file
-	fw, err := req.writer.CreateFormFile(name, file)
+	if !req.bufferUploads {
+		if contentType == "" {
+			head := make([]byte, 512)
+			n, err := io.ReadFull(f, head)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return err
+			}
+			contentType = http.DetectContentType(head[:n])
+		}
+		req.addStreamedParts(multipartPart{fieldName: fieldName, fileName: fileName, file: true, contentType: contentType, path: filePath})
+		return nil
+	}
+
+	data, err := ioutil.ReadAll(f)
 	if err != nil {
-		panic(err)
+		return err
 	}
-	if _, err = io.Copy(fw, f); err != nil {
-		panic(err)
+	if contentType == "" {
+		contentType = http.DetectContentType(data)
 	}
-
-	req.contentType = req.writer.FormDataContentType()
-	req.formVals = &req.multipartBuffer
-	return req
+	req.startPart(&multipartPart{fieldName: fieldName, fileName: fileName, file: true, contentType: contentType})
+	req.multipartBuffer.Write(data)
+	return nil
 }
 
-// UploadFromReader upload a single file
+// UploadFromReader upload a single file. param.FileBody is read while the
+// request is sent, so it can only be sent once, unless WithBufferedUploads
+// is used.
 func (req *Request) UploadFromReader(param MultipartParam) *Request {
-	if req.writer == nil {
-		req.writer = multipart.NewWriter(&req.multipartBuffer)
+	part := multipartPart{fieldName: param.FieldName, fileName: param.FileName, file: true, contentType: param.ContentType}
+	var contentType string
+	part.header, contentType = partHeader(param.Header)
+	if part.contentType == "" {
+		part.contentType = contentType
+	}
+	if part.contentType == "" {
+		part.contentType = defaultFileContentType
 	}
 
-	// Add file
-	fw, err := req.writer.CreateFormFile(param.FieldName, param.FileName)
-	if err != nil {
-		panic(err)
+	if !req.bufferUploads {
+		part.r, part.read = param.FileBody, new(int32)
+		req.addStreamedParts(part)
+		return req
 	}
-	if _, err = io.Copy(fw, param.FileBody); err != nil {
+
+	// Add file
+	req.startPart(&part)
+	if _, err := io.Copy(&req.multipartBuffer, param.FileBody); err != nil {
 		panic(err)
 	}
-
-	req.contentType = req.writer.FormDataContentType()
-	req.formVals = &req.multipartBuffer
 	return req
 }
 
@@ -256,37 +778,57 @@ func (req *Request) UploadsFromReader(params []MultipartParam) *Request {
 	return req
 }
 
+// OnBeforeRequest registers a hook executed before the request is sent.
+// It is safe to call while other sends are in flight, the hook applies to
+// sends started after it was registered. The first error returned by a hook
+// stops the remaining ones and the request is not sent.
 func (req *Request) OnBeforeRequest(hook BeforeRequestHook) *Request {
-	req.beforeRequestHooks = append(req.beforeRequestHooks, hook)
+	req.registerHook(func(h *hookSet) {
+		h.beforeRequestHooks = append(h.beforeRequestHooks, hook)
+	})
 	return req
 }
 
-func (req *Request) OnAfterResponse(hook AfterResponseHook) *Request {
+// OnBeforeHTTPRequest registers a hook executed with the http request right
+// before it is sent, e.g. to sign it. It is executed for every attempt,
+// including retries. The first error returned by a hook stops the
+// remaining ones and the request is not sent.
+func (req *Request) OnBeforeHTTPRequest(hook BeforeHTTPRequestHook) *Request {
+	req.registerHook(func(h *hookSet) {
+		h.beforeHTTPRequestHooks = append(h.beforeHTTPRequestHooks, hook)
+	})
+	return req
+}
 
-	req.afterResponseHooks = append(req.afterResponseHooks, hook)
+// OnAfterResponse registers a hook executed after a response is received.
+// Hooks may modify the response, e.g. to replace its body. The first error
+// returned by a hook stops the remaining ones and is returned by the send
+// along with the response.
+func (req *Request) OnAfterResponse(hook AfterResponseHook) *Request {
+	req.registerHook(func(h *hookSet) {
+		h.afterResponseHooks = append(h.afterResponseHooks, hook)
+	})
 	return req
 }
+
+// OnError registers a hook executed when the request fails
 func (req *Request) OnError(errorHook ErrorHook) *Request {
-	req.errorHooks = append(req.errorHooks, errorHook)
+	req.registerHook(func(h *hookSet) {
+		h.errorHooks = append(h.errorHooks, errorHook)
+	})
 	return req
 }
 
-func (req *Request) ExecuteBeforeRequestHooks() {
-	for _, beforeReqHook := range req.beforeRequestHooks {
-		beforeReqHook(req)
-	}
+func (req *Request) ExecuteBeforeRequestHooks() error {
+	return req.hooks().executeBeforeRequest(req)
 }
 
-func (req *Request) ExecuteAfterResponseHooks(response Response) {
-	for _, afterResponseHook := range req.afterResponseHooks {
-		afterResponseHook(req, &response)
-	}
+func (req *Request) ExecuteAfterResponseHooks(response *Response) error {
+	return req.hooks().executeAfterResponse(req, response)
 }
 
 func (req *Request) ExecuteOnErrorHooks(err error) {
-	for _, errorHooks := range req.errorHooks {
-		errorHooks(req, err)
-	}
+	req.hooks().executeOnError(req, err)
 }
 
 // Context method returns the Context if it is already set in the [Request]
@@ -302,66 +844,416 @@ func (r *Request) Context() context.Context {
 // to interrupt the request execution if `ctx.Done()` channel is closed.
 // See https://blog.golang.org/context article and the package [context]
 // documentation.
+//
+// Before request hooks receive a copy of the request, calling SetContext
+// from a hook, e.g. to add values, only affects the send that runs it.
 func (r *Request) SetContext(ctx context.Context) *Request {
 	r.ctx = ctx
 	return r
 }
 
-// makeRequest makes a http request
-func (req *Request) makeRequest(verb, url string, payloads *bytes.Buffer) (*Response, error) {
-	req.ExecuteBeforeRequestHooks()
-
-	response := Response{}
-	verb = strings.ToUpper(verb)
-	var request *http.Request
-	var err error
-	client := req.createClient()
+// Timeout method limits every send of the request to d, including reading
+// the response body, while the client keeps the timeout of SetTimeout, e.g.
+// for a latency sensitive call with a client shared by slower ones. The
+// shorter of both wins. The deadline is released when the response body is
+// closed.
+func (r *Request) Timeout(d time.Duration) *Request {
+	r.callTimeout = d
+	return r
+}
 
-	if req.writer != nil {
-		req.writer.Close()
-	}
-	if req.queryVals != "" {
-		url += "?" + req.queryVals
+// SetAttr method stores val under key for the hooks of the request. Like
+// SetContext, attributes set from a hook are only seen by the same send.
+func (r *Request) SetAttr(key string, val interface{}) *Request {
+	if r.attrs == nil {
+		r.attrs = map[string]interface{}{}
 	}
+	r.attrs[key] = val
+	return r
+}
 
-	if payloads == nil {
-		payloads = bytes.NewBuffer([]byte(``))
-	}
+// Attr method returns the attribute stored under key
+func (r *Request) Attr(key string) (interface{}, bool) {
+	val, ok := r.attrs[key]
+	return val, ok
+}
+
+// writerToBody streams the BodyWriterTo body through a pipe. The transport
+// closes the returned reader once it is done, which stops the writer. The
+// pipe is closed with the context error too when ctx is done, so the writer
+// can't be left blocked on a pipe nobody reads.
+func (req *Request) writerToBody(ctx context.Context) io.ReadCloser {
+	pr, pw := io.Pipe()
+	done := make(chan struct{})
+	go func(wt io.WriterTo) {
+		defer close(done)
+		_, err := wt.WriteTo(pw)
+		pw.CloseWithError(err)
+	}(req.bodyWriterTo)
+
+	go func() {
+		select {
+		case <-ctx.Done():
+			pw.CloseWithError(ctx.Err())
+		case <-done:
+		}
+	}()
+
+	return pr
+}
+
+// multipartBody returns the multipart form written so far followed by its
+// closing boundary. The form is left open so a request can be sent again
+// or get more parts.
+func (req *Request) multipartBody() *bytes.Buffer {
+	body := bytes.NewBuffer(make([]byte, 0, req.multipartBuffer.Len()+len(req.boundary)+8))
+	body.Write(req.multipartBuffer.Bytes())
+	writeClosingBoundary(body, req.boundary, body.Len() == 0)
+	return body
+}
+
+// newHTTPRequest builds the http request for a single attempt, the body is
+// rebuilt every time so an attempt can be repeated
+func (req *Request) newHTTPRequest(verb, url string, payloads *bytes.Buffer) (*http.Request, error) {
+	var request *http.Request
+	var err error
+	ctx := req.Context()
 
 	if verb == "GET" {
-		request, err = http.NewRequest(verb, url, nil)
+		request, err = http.NewRequestWithContext(ctx, verb, url, nil)
+	} else if req.bodyWriterTo != nil && req.http1Only {
+		// without chunked encoding the length must be known up front
+		var buf bytes.Buffer
+		if _, err = req.bodyWriterTo.WriteTo(&buf); err != nil {
+			return nil, err
+		}
+		request, err = http.NewRequestWithContext(ctx, verb, url, bytes.NewReader(buf.Bytes()))
+	} else if req.bodyWriterTo != nil {
+		body := req.writerToBody(ctx)
+		request, err = http.NewRequestWithContext(ctx, verb, url, body)
+		if err != nil {
+			body.Close()
+		} else {
+			// lets the client send the body again for a 307 or 308 redirect
+			request.GetBody = func() (io.ReadCloser, error) {
+				return req.writerToBody(ctx), nil
+			}
+			if req.multipart != nil && req.bodyWriterTo == req.multipart {
+				if n := req.multipart.contentLength(); n > 0 {
+					request.ContentLength = n
+				}
+			}
+		}
+	} else if req.bodyReader != nil {
+		request, err = req.newReaderRequest(ctx, verb, url)
 	} else {
-		request, err = http.NewRequest(verb, url, payloads)
+		request, err = http.NewRequestWithContext(ctx, verb, url, bytes.NewReader(payloads.Bytes()))
 	}
 
 	if err != nil {
-		req.ExecuteOnErrorHooks(err)
 		return nil, err
 	}
 
+	if req.http1Only {
+		request.ProtoMajor, request.ProtoMinor = 1, 0
+	}
+
 	request.Header.Set("Content-Type", req.contentType)
 
 	if req.basicUser != "" && req.basicPasswd != "" {
 		request.SetBasicAuth(req.basicUser, req.basicPasswd)
 	}
 
-	// set headers from Headers method
+	token := req.authToken
+	if req.authTokenProvider != nil {
+		if token, err = req.authTokenProvider(ctx); err != nil {
+			if request.Body != nil {
+				request.Body.Close()
+			}
+			return nil, err
+		}
+	}
+	if token != "" {
+		request.Header.Set("Authorization", "Bearer "+token)
+	}
+	if req.apiKeyHeader != "" {
+		request.Header.Set(req.apiKeyHeader, req.apiKey)
+	}
+
+	userAgent := req.userAgent
+	if req.userAgentFunc != nil {
+		userAgent = req.userAgentFunc(req)
+	}
+	if userAgent == "" {
+		userAgent = defaultUserAgent
+	}
+	request.Header.Set("User-Agent", userAgent)
+
+	if req.prefer != "" {
+		request.Header.Set("Prefer", req.prefer)
+	}
+	if req.priority != "" {
+		request.Header.Set("Priority", req.priority)
+	}
+	if req.ifMatch != "" {
+		request.Header.Set("If-Match", req.ifMatch)
+	}
+
+	// set headers from WithHeaders, then from Headers method
+	for key, val := range req.defaultHeaders {
+		request.Header.Set(key, val)
+	}
 	for key, val := range req.headers {
 		request.Header.Set(key, val)
 	}
 
+	if req.compressBody != "" {
+		request.Header.Set("Content-Encoding", req.compressBody)
+	}
+
+	if val, ok := req.defaultHeaders["Host"]; ok {
+		request.Host = val
+	}
 	if val, ok := req.headers["Host"]; ok {
 		request.Host = val
 	}
-	//request.Close = true
-	resp, err := client.Do(request)
 
+	if req.expectContinue && request.Body != nil {
+		request.Header.Set("Expect", "100-continue")
+	}
+
+	if req.uploadProgress != nil && request.Body != nil && request.Body != http.NoBody {
+		total := request.ContentLength
+		if total <= 0 {
+			total = -1
+		}
+		request.Body = &progressReader{r: request.Body, total: total, fn: req.uploadProgress}
+	}
+
+	return request, nil
+}
+
+// newReaderRequest builds the http request of an attempt with the
+// BodyReader body
+func (req *Request) newReaderRequest(ctx context.Context, verb, url string) (*http.Request, error) {
+	body, err := req.bodyReader.open()
+	if err != nil {
+		return nil, err
+	}
+
+	length := req.bodyReader.length
+	if length < 0 && req.http1Only {
+		// without chunked encoding the length must be known up front
+		data, err := ioutil.ReadAll(body)
+		body.Close()
+		if err != nil {
+			return nil, err
+		}
+		body, length = ioutil.NopCloser(bytes.NewReader(data)), int64(len(data))
+	}
+	if length == 0 {
+		return http.NewRequestWithContext(ctx, verb, url, http.NoBody)
+	}
+
+	request, err := http.NewRequestWithContext(ctx, verb, url, body)
+	if err != nil {
+		return nil, err
+	}
+	request.ContentLength = length
+	// lets the client send the body again for a 307 or 308 redirect
+	request.GetBody = req.bodyReader.open
+	return request, nil
+}
+
+// makeRequest makes a http request
+func (req *Request) makeRequest(verb, url string, payloads *bytes.Buffer) (*Response, error) {
+	atomic.StoreInt32(&req.state.sent, 1)
+	if req.clientSent != nil {
+		atomic.StoreInt32(req.clientSent, 1)
+	}
+	hooks := req.hooks()
+	if req.err != nil {
+		hooks.executeOnError(req, req.err)
+		return nil, req.err
+	}
+	url, err := req.resolveURL(url)
+	if err != nil {
+		hooks.executeOnError(req, err)
+		return nil, err
+	}
+	client := req.createClient()
+	if req.boundary != "" && payloads == &req.multipartBuffer {
+		payloads = req.multipartBody()
+	}
+
+	// hooks work on a copy of the request, a context or attributes they
+	// set are used for this send without replacing the caller's ones
+	call := *req
+	call.ctx = req.Context()
+	if req.attrs != nil {
+		call.attrs = make(map[string]interface{}, len(req.attrs))
+		for key, val := range req.attrs {
+			call.attrs[key] = val
+		}
+	}
+	if bodylessMethod(verb) && call.contentType == "application/x-www-form-urlencoded" && payloads != nil && payloads.Len() > 0 {
+		// a GET body is ignored by most servers, FormData is sent in the
+		// query string instead
+		call.queryVals = mergeQuery(call.queryVals, payloads.String())
+		call.contentType = ""
+		payloads = nil
+	}
+	if err := hooks.executeBeforeRequest(&call); err != nil {
+		hooks.executeOnError(&call, err)
+		return nil, err
+	}
+	streamed := call.bodyWriterTo != nil || call.bodyReader != nil
+	if bodylessMethod(verb) || (!streamed && (payloads == nil || payloads.Len() == 0)) {
+		call.compressBody = ""
+	}
+	if call.compressBody != "" {
+		if streamed {
+			err = call.encodeStream(call.compressBody)
+		} else {
+			payloads, err = encodeBuffer(call.compressBody, payloads)
+		}
+		if err != nil {
+			hooks.executeOnError(&call, err)
+			return nil, err
+		}
+	}
+
+	if call.callTimeout <= 0 {
+		return call.send(client, hooks, verb, url, payloads)
+	}
+
+	ctx, cancel := context.WithTimeout(call.Context(), call.callTimeout)
+	call.ctx = ctx
+	resp, err := call.send(client, hooks, verb, url, payloads)
+	if resp == nil {
+		cancel()
+	} else {
+		resp.resp.Body = &cancelBody{ReadCloser: resp.resp.Body, cancel: cancel}
+	}
+	return resp, err
+}
+
+// send sends the request built by makeRequest with client
+func (req *Request) send(client *http.Client, hooks *hookSet, verb, url string, payloads *bytes.Buffer) (*Response, error) {
+	verb = strings.ToUpper(verb)
+
+	query, err := req.encodeQuery(url)
 	if err != nil {
-		req.ExecuteOnErrorHooks(err)
+		hooks.executeOnError(req, err)
 		return nil, err
 	}
-	response.resp = resp
-	req.ExecuteAfterResponseHooks(response)
+	url = appendQuery(url, query)
 
-	return &response, nil
+	if payloads == nil {
+		payloads = bytes.NewBuffer([]byte(``))
+	}
+
+	req.emit(Event{Kind: EventStart, Method: verb, URL: url})
+
+	var digest *digestChallenge
+	for attempt := 0; ; {
+		request, err := req.newHTTPRequest(verb, url, payloads)
+		if err != nil {
+			hooks.executeOnError(req, err)
+			return nil, err
+		}
+		if digest != nil {
+			digest.authorize(request, req.digestUser, req.digestPasswd)
+		}
+
+		trace := connTrace{pool: &req.state.pool}
+		request = trace.attach(request)
+
+		shaping := req.devShapingActive()
+		if shaping {
+			if err := req.devShaping.delay(request.Context()); err != nil {
+				if request.Body != nil {
+					request.Body.Close()
+				}
+				hooks.executeOnError(req, err)
+				return nil, err
+			}
+		}
+
+		if err := hooks.executeBeforeHTTPRequest(request); err != nil {
+			if request.Body != nil {
+				request.Body.Close()
+			}
+			hooks.executeOnError(req, err)
+			return nil, err
+		}
+
+		req.countSent(request)
+		atomic.AddInt64(&req.stats.requests, 1)
+
+		//request.Close = true
+		resp, err := client.Do(request)
+		if err != nil {
+			err = withCancelCause(request.Context(), trace.classify(err))
+			hooks.executeOnError(req, err)
+			return nil, err
+		}
+		if req.readTimeout > 0 {
+			resp.Body = &readTimeoutBody{body: resp.Body, timeout: req.readTimeout}
+		}
+		resp.Body = &classifiedBody{ReadCloser: resp.Body, ctx: request.Context()}
+		resp.Body = countingBody{ReadCloser: resp.Body, n: &req.stats.bytesReceived}
+
+		if digest == nil && req.digestUser != "" && resp.StatusCode == http.StatusUnauthorized {
+			if digest, err = parseDigestChallenge(resp.Header); err != nil {
+				resp.Body.Close()
+				hooks.executeOnError(req, err)
+				return nil, err
+			}
+			if digest != nil {
+				io.Copy(ioutil.Discard, resp.Body)
+				resp.Body.Close()
+				continue
+			}
+		}
+
+		if attempt < req.retryCount && isRetryableStatus(resp.StatusCode) {
+			wait, err := req.retryDelay(resp, attempt)
+			io.Copy(ioutil.Discard, resp.Body)
+			resp.Body.Close()
+			if err == nil {
+				err = sleepContext(request.Context(), wait)
+			}
+			if err != nil {
+				hooks.executeOnError(req, err)
+				return nil, err
+			}
+			attempt++
+			req.emit(Event{Kind: EventRetry, Method: verb, URL: url, Attempt: attempt, StatusCode: resp.StatusCode})
+			continue
+		}
+
+		req.emit(Event{Kind: EventResponse, Method: verb, URL: url, Attempt: attempt, StatusCode: resp.StatusCode})
+
+		if shaping {
+			resp.Body = req.devShaping.throttle(request.Context(), resp.Body)
+		}
+		if req.downloadProgress != nil {
+			resp.Body = &progressReader{r: resp.Body, total: resp.ContentLength, fn: req.downloadProgress}
+		}
+
+		// the response is returned with the error so it can be inspected
+		response := Response{resp: resp, contentTypePolicy: req.contentTypePolicy}
+		response.contentTypePolicy.warnf = req.warnf
+		if err := hooks.executeAfterResponse(req, &response); err != nil {
+			hooks.executeOnError(req, err)
+			return &response, err
+		}
+		if err := req.checkExpectations(&response); err != nil {
+			hooks.executeOnError(req, err)
+			return &response, err
+		}
+
+		return &response, nil
+	}
 }
@@ -1,6 +1,37 @@
 package gohttp
 
-import "testing"
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/goleak"
+)
+
+// newEchoServer returns a server which responds with the request body and
+// copies the request content type into the response
+func newEchoServer(t *testing.T) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", r.Header.Get("Content-Type"))
+		io.Copy(w, r.Body)
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
 
 // TestGetRequest tests GET request
 func TestGetRequest(t *testing.T) {
@@ -122,3 +153,954 @@ func TestDeleteRequest(t *testing.T) {
 		)
 	}
 }
+
+// lines is an io.WriterTo writing one line per element
+type lines []string
+
+func (l lines) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, line := range l {
+		m, err := fmt.Fprintln(w, line)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// TestBodyWriterTo tests BodyWriterTo request body
+func TestBodyWriterTo(t *testing.T) {
+	t.Log("Sending POST request with io.WriterTo body... (expected echoed body)")
+
+	ts := newEchoServer(t)
+
+	resp, err := NewRequest().
+		BodyWriterTo(lines{"first", "second"}, "text/plain").
+		Post(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	body, _ := ioutil.ReadAll(resp.GetBody())
+	if string(body) != "first\nsecond\n" || resp.GetResp().Header.Get("Content-Type") != "text/plain" {
+		t.Error(
+			"For", "BodyWriterTo",
+			"expected", "first\\nsecond\\n",
+			"got", string(body),
+		)
+	}
+}
+
+// TestBodySetterPrecedence tests the last body setter wins over the bodies
+// set before
+func TestBodySetterPrecedence(t *testing.T) {
+	t.Log("Setting a body after another one... (expected only the last one sent)")
+
+	ts := newEchoServer(t)
+
+	tests := []struct {
+		name        string
+		req         *Request
+		contentType string
+		body        string
+	}{
+		{"multipart then Body", NewRequest().MultipartFormData(map[string]string{"a": "1"}).Body([]byte("raw")), "application/octet-stream", "raw"},
+		{"buffered multipart then JSON", NewRequest(WithBufferedUploads()).MultipartFormData(map[string]string{"a": "1"}).JSON(map[string]interface{}{"b": 2}), "application/json", `{"b":2}`},
+		{"upload then Text", NewRequest().UploadFromReader(MultipartParam{FieldName: "f", FileName: "f.txt", FileBody: strings.NewReader("file")}).Text("text"), "text/plain", "text"},
+		{"BodyWriterTo then JSON", NewRequest().BodyWriterTo(lines{"streamed"}, "text/plain").JSON(map[string]interface{}{"b": 2}), "application/json", `{"b":2}`},
+		{"JSON then BodyWriterTo", NewRequest().JSON(map[string]interface{}{"b": 2}).BodyWriterTo(lines{"streamed"}, "text/plain"), "text/plain", "streamed\n"},
+		{"BodyWriterTo then buffered multipart", NewRequest(WithBufferedUploads()).BodyWriterTo(lines{"streamed"}, "text/plain").MultipartBoundary("b").MultipartField("a", "1", ""), "multipart/form-data; boundary=b", "--b\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\n1\r\n--b--\r\n"},
+		{"BodyReader then Text", NewRequest().BodyReader(strings.NewReader("read"), 4).Text("x"), "text/plain", "x"},
+		{"Text then BodyReader", NewRequest().Text("x").BodyReader(strings.NewReader("read"), 4), "application/octet-stream", "read"},
+		{"BodyReader then buffered multipart", NewRequest(WithBufferedUploads()).BodyReader(strings.NewReader("read"), 4).MultipartBoundary("b").MultipartField("a", "1", ""), "multipart/form-data; boundary=b", "--b\r\nContent-Disposition: form-data; name=\"a\"\r\n\r\n1\r\n--b--\r\n"},
+		{"multipart then FormDataMulti", NewRequest().MultipartField("a", "1", "").FormDataMulti(url.Values{"b": {"2"}}), "application/x-www-form-urlencoded", "b=2"},
+	}
+
+	for _, test := range tests {
+		resp, err := test.req.Post(ts.URL)
+		if err != nil {
+			t.Error("For", test.name, "expected", test.body, "got", err)
+			continue
+		}
+		body, _ := resp.GetBodyAsString()
+		if contentType := resp.GetResp().Header.Get("Content-Type"); body != test.body || contentType != test.contentType {
+			t.Error(
+				"For", test.name,
+				"expected", test.contentType+" "+test.body,
+				"got", contentType+" "+body,
+			)
+		}
+	}
+}
+
+// newHeaderServer returns a server which responds with the value of the
+// given request header
+func newHeaderServer(t *testing.T, header string) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get(header)))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// TestAuthToken tests AuthToken and its precedence
+func TestAuthToken(t *testing.T) {
+	t.Log("Sending GET request with bearer token... (expected Authorization header)")
+
+	ts := newHeaderServer(t, "Authorization")
+
+	tests := []struct {
+		req      *Request
+		expected string
+	}{
+		{NewRequest().AuthToken("secret"), "Bearer secret"},
+		{NewRequest().BasicAuth("user", "pass").AuthToken("secret"), "Bearer secret"},
+		{NewRequest().AuthToken("secret").Headers(map[string]string{"Authorization": "Custom x"}), "Custom x"},
+	}
+
+	for _, tt := range tests {
+		resp, err := tt.req.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if body, _ := resp.GetBodyAsString(); body != tt.expected {
+			t.Error(
+				"For", "AuthToken",
+				"expected", tt.expected,
+				"got", body,
+			)
+		}
+	}
+}
+
+// TestAuthTokenProvider tests AuthTokenProvider is called for every attempt
+func TestAuthTokenProvider(t *testing.T) {
+	t.Log("Sending GET request with token provider and retry... (expected refreshed token)")
+
+	var tokens []string
+	calls := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		tokens = append(tokens, r.Header.Get("Authorization"))
+		if len(tokens) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+		}
+	}))
+	defer ts.Close()
+
+	_, err := NewRequest(WithRetry(1, time.Millisecond)).
+		AuthTokenProvider(func(ctx context.Context) (string, error) {
+			calls++
+			return fmt.Sprintf("token-%d", calls), nil
+		}).
+		Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(tokens) != 2 || tokens[0] != "Bearer token-1" || tokens[1] != "Bearer token-2" {
+		t.Error(
+			"For", "AuthTokenProvider",
+			"expected", "[Bearer token-1 Bearer token-2]",
+			"got", tokens,
+		)
+	}
+}
+
+// TestAuthTokenProviderError tests a failing token provider aborts the request
+func TestAuthTokenProviderError(t *testing.T) {
+	t.Log("Sending GET request with failing token provider... (expected error)")
+
+	providerErr := errors.New("no token")
+	ts := newHeaderServer(t, "Authorization")
+
+	var hookErr error
+	_, err := NewRequest().
+		AuthTokenProvider(func(ctx context.Context) (string, error) {
+			return "", providerErr
+		}).
+		OnError(func(_ *Request, err error) {
+			hookErr = err
+		}).
+		Get(ts.URL)
+
+	if !errors.Is(err, providerErr) || !errors.Is(hookErr, providerErr) {
+		t.Error(
+			"For", "AuthTokenProvider",
+			"expected", providerErr,
+			"got", err, hookErr,
+		)
+	}
+}
+
+// TestAPIKey tests API keys sent in a header or the query, along with
+// other auth
+func TestAPIKey(t *testing.T) {
+	t.Log("Sending GET request with API keys... (expected key header or param)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.Header.Get("X-Api-Key") + "|" + r.Header.Get("Authorization") + "|" + r.URL.RawQuery))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		req      *Request
+		expected string
+	}{
+		{"header", NewRequest().APIKeyAuth("X-API-Key", "k3y"), "k3y||"},
+		{"header with token", NewRequest().AuthToken("secret").APIKeyAuth("X-API-Key", "k3y"), "k3y|Bearer secret|"},
+		{"header with basic auth", NewRequest().BasicAuth("user", "pass").APIKeyAuth("X-API-Key", "k3y"), "k3y|Basic dXNlcjpwYXNz|"},
+		{"header overridden", NewRequest().APIKeyAuth("X-API-Key", "k3y").Headers(map[string]string{"X-API-Key": "other"}), "other||"},
+		{"query", NewRequest().AddQuery("page", "2").APIKeyQuery("api_key", "k3y&x"), "||api_key=k3y%26x&page=2"},
+		{"query replaced", NewRequest().APIKeyQuery("api_key", "old").APIKeyQuery("api_key", "new").AuthToken("secret"), "|Bearer secret|api_key=new"},
+	}
+
+	for _, tt := range tests {
+		resp, err := tt.req.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if body, _ := resp.GetBodyAsString(); body != tt.expected {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", body,
+			)
+		}
+	}
+}
+
+// TestExpectContinue tests a rejected expectation does not send the body
+func TestExpectContinue(t *testing.T) {
+	t.Log("Uploading to server rejecting the expectation... (expected no body sent)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Expect") != "100-continue" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		if r.Header.Get("Authorization") == "" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		n, _ := io.Copy(ioutil.Discard, r.Body)
+		fmt.Fprint(w, n)
+	}))
+	defer ts.Close()
+
+	var sent int64
+	counter := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			r.Body = countingBody{r.Body, &sent}
+			return next.RoundTrip(r)
+		})
+	}
+
+	upload := MultipartParam{
+		FieldName: "file",
+		FileName:  "big.bin",
+		FileBody:  strings.NewReader(strings.Repeat("x", 1<<20)),
+	}
+
+	resp, err := NewRequest(WithMiddleware(counter)).
+		ExpectContinue().
+		UploadFromReader(upload).
+		Post(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.GetStatusCode() != http.StatusUnauthorized || atomic.LoadInt64(&sent) != 0 {
+		t.Error(
+			"For", "rejected ExpectContinue",
+			"expected", "401 without body",
+			"got", resp.GetStatusCode(), atomic.LoadInt64(&sent),
+		)
+	}
+
+	upload.FileBody = strings.NewReader(strings.Repeat("x", 1<<20))
+	resp, err = NewRequest(WithMiddleware(counter)).
+		ExpectContinue().
+		AuthToken("token").
+		UploadFromReader(upload).
+		Post(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.GetStatusCode() != http.StatusOK || atomic.LoadInt64(&sent) < 1<<20 {
+		t.Error(
+			"For", "accepted ExpectContinue",
+			"expected", "200 with body",
+			"got", resp.GetStatusCode(), atomic.LoadInt64(&sent),
+		)
+	}
+}
+
+type hookCtxKey struct{}
+
+// TestBeforeRequestHookContext tests a hook context is used for the send only
+func TestBeforeRequestHookContext(t *testing.T) {
+	t.Log("Setting a context value in a before hook... (expected in http request only)")
+
+	ts := newHeaderServer(t, "X-Hook-Value")
+
+	fromContext := func(next http.RoundTripper) http.RoundTripper {
+		return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+			if val, ok := r.Context().Value(hookCtxKey{}).(string); ok {
+				r.Header.Set("X-Hook-Value", val)
+			}
+			return next.RoundTrip(r)
+		})
+	}
+
+	ctx := context.Background()
+	req := NewRequest(WithMiddleware(fromContext)).SetContext(ctx)
+	req.OnBeforeRequest(func(r *Request) error {
+		r.SetContext(context.WithValue(r.Context(), hookCtxKey{}, "from hook"))
+		return nil
+	})
+
+	resp, err := req.Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body, _ := resp.GetBodyAsString(); body != "from hook" {
+		t.Error(
+			"For", "hook context",
+			"expected", "from hook",
+			"got", body,
+		)
+	}
+
+	if req.Context() != ctx || req.Context().Value(hookCtxKey{}) != nil {
+		t.Error(
+			"For", "caller context",
+			"expected", "unchanged",
+			"got", "modified",
+		)
+	}
+}
+
+// TestBeforeRequestHookError tests a before hook aborting the request
+func TestBeforeRequestHookError(t *testing.T) {
+	t.Log("Aborting request in before hook... (expected hook error, request not sent)")
+
+	var sent int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&sent, 1)
+	}))
+	defer ts.Close()
+
+	errUnsigned := errors.New("unsigned")
+	var calls int
+	var hookErr error
+	req := NewRequest()
+	req.OnBeforeRequest(func(r *Request) error {
+		calls++
+		return errUnsigned
+	})
+	req.OnBeforeRequest(func(r *Request) error {
+		calls++
+		return nil
+	})
+	req.OnError(func(r *Request, err error) {
+		hookErr = err
+	})
+
+	resp, err := req.Get(ts.URL)
+	if err != errUnsigned || resp != nil || hookErr != errUnsigned || calls != 1 || atomic.LoadInt32(&sent) != 0 {
+		t.Error(
+			"For", "failing hook",
+			"expected", errUnsigned, "and nothing sent",
+			"got", err, resp, hookErr, calls, sent,
+		)
+	}
+}
+
+// TestBeforeHTTPRequestHook tests a hook signing the final http request
+func TestBeforeHTTPRequestHook(t *testing.T) {
+	t.Log("Signing http request in hook... (expected signature of final URL, abort on error)")
+
+	var signatures []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		signatures = append(signatures, r.Header.Get("X-Signature"))
+	}))
+	defer ts.Close()
+
+	req := NewRequest(WithBaseURL(ts.URL)).Query(map[string]string{"page": "1"})
+	req.OnBeforeHTTPRequest(func(r *http.Request) error {
+		r.Header.Set("X-Signature", r.Method+" "+r.URL.RequestURI())
+		return nil
+	})
+	if _, err := req.Get("/users"); err != nil {
+		t.Fatal(err)
+	}
+
+	errSign := errors.New("no key")
+	req.OnBeforeHTTPRequest(func(r *http.Request) error {
+		return errSign
+	})
+	if _, err := req.Get("/users"); err != errSign {
+		t.Error(
+			"For", "failing hook",
+			"expected", errSign,
+			"got", err,
+		)
+	}
+
+	expected := "[GET /users?page=1]"
+	if fmt.Sprint(signatures) != expected {
+		t.Error(
+			"For", "signatures",
+			"expected", expected,
+			"got", signatures,
+		)
+	}
+}
+
+// TestReplayBody tests bodies are sent again on reuse and 307 redirects
+func TestReplayBody(t *testing.T) {
+	t.Log("Sending bodies twice and through 307 redirects... (expected same body every time)")
+
+	var bodies []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		bodies = append(bodies, string(body))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	req := NewRequest().Text("hello")
+	for i := 0; i < 2; i++ {
+		if _, err := req.Post(ts.URL + "/echo"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if _, err := req.Post(ts.URL + "/redirect"); err != nil {
+		t.Fatal(err)
+	}
+
+	wt := NewRequest().BodyWriterTo(lines{"streamed"}, "text/plain")
+	if _, err := wt.Post(ts.URL + "/redirect"); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "[hello hello hello streamed\n]"
+	if fmt.Sprint(bodies) != expected {
+		t.Error(
+			"For", "bodies",
+			"expected", expected,
+			"got", bodies,
+		)
+	}
+
+	oneShot := NewRequest().UploadFromReader(MultipartParam{
+		FieldName: "file",
+		FileName:  "file.txt",
+		FileBody:  strings.NewReader("once"),
+	})
+	if _, err := oneShot.Post(ts.URL + "/redirect"); !errors.Is(err, ErrBodyNotReplayable) {
+		t.Error(
+			"For", "one shot body",
+			"expected", ErrBodyNotReplayable,
+			"got", err,
+		)
+	}
+}
+
+// TestBodyReader tests bodies streamed from readers and files, with and
+// without known length
+func TestBodyReader(t *testing.T) {
+	t.Log("Sending bodies from readers... (expected streamed bodies and Content-Length when known)")
+
+	var got []string
+	mux := http.NewServeMux()
+	mux.HandleFunc("/redirect", func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+		http.Redirect(w, r, "/echo", http.StatusTemporaryRedirect)
+	})
+	mux.HandleFunc("/echo", func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		got = append(got, fmt.Sprintf("%s %d %v %s", body, r.ContentLength, r.TransferEncoding, r.Header.Get("Content-Type")))
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	f, err := ioutil.TempFile("", "gohttp")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("from file")
+	f.Close()
+
+	seekable := strings.NewReader("skip:a,b")
+	seekable.Seek(5, io.SeekStart)
+	opened := 0
+	newBody := func() io.Reader {
+		opened++
+		return io.MultiReader(strings.NewReader("fresh"))
+	}
+	requests := []struct {
+		req  *Request
+		path string
+	}{
+		{NewRequest().BodyReader(seekable, 3).ContentType("text/csv"), "/redirect"},
+		{NewRequest().BodyReader(io.MultiReader(strings.NewReader("chunked")), -1), "/echo"},
+		{NewRequest().BodyFile(f.Name()), "/redirect"},
+		{NewRequest().BodyReaderFunc(newBody, -1), "/redirect"},
+	}
+	for _, tt := range requests {
+		if _, err := tt.req.Post(ts.URL + tt.path); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	expected := "[a,b 3 [] text/csv chunked -1 [chunked] application/octet-stream from file 9 [] application/octet-stream fresh -1 [chunked] application/octet-stream]"
+	if fmt.Sprint(got) != expected || opened != 2 {
+		t.Error(
+			"For", "bodies",
+			"expected", expected, "opened twice",
+			"got", got, opened,
+		)
+	}
+
+	oneShot := NewRequest().BodyReader(io.MultiReader(strings.NewReader("once")), 4)
+	if _, err := oneShot.Post(ts.URL + "/redirect"); !errors.Is(err, ErrBodyNotReplayable) {
+		t.Error(
+			"For", "one shot reader",
+			"expected", ErrBodyNotReplayable,
+			"got", err,
+		)
+	}
+}
+
+// TestBodyFileResend tests a BodyFile request can be sent again, cloned
+// and sent concurrently
+func TestBodyFileResend(t *testing.T) {
+	t.Log("Sending a file body many times... (expected the whole file every time)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %d", body, r.ContentLength)
+	}))
+	defer ts.Close()
+
+	path := filepath.Join(t.TempDir(), "body.txt")
+	if err := ioutil.WriteFile(path, []byte("from file"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	req := NewRequest().BodyFile(path)
+	client := NewClient()
+	template := client.R().BodyFile(path)
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 30)
+	for i := 0; i < 10; i++ {
+		for _, send := range []func() (*Response, error){
+			func() (*Response, error) { return req.Post(ts.URL) },
+			func() (*Response, error) { return req.Clone().Post(ts.URL) },
+			func() (*Response, error) { return template.Clone().Post(ts.URL) },
+		} {
+			wg.Add(1)
+			go func(send func() (*Response, error)) {
+				defer wg.Done()
+				resp, err := send()
+				if err == nil {
+					var body string
+					if body, err = resp.GetBodyAsString(); err == nil && body != "from file 9" {
+						err = fmt.Errorf("got %q", body)
+					}
+				}
+				errs <- err
+			}(send)
+		}
+	}
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		if err != nil {
+			t.Error(
+				"For", "BodyFile",
+				"expected", "from file 9",
+				"got", err,
+			)
+		}
+	}
+}
+
+// TestAttr tests an attribute set in a before hook is read in an after hook
+func TestAttr(t *testing.T) {
+	t.Log("Passing attributes between hooks... (expected attributes in after hook)")
+
+	ts := newHeaderServer(t, "X-Unused")
+
+	var got []interface{}
+	req := NewRequest().SetAttr("route", "users")
+	req.OnBeforeRequest(func(r *Request) error {
+		r.SetAttr("start", 42)
+		return nil
+	})
+	req.OnAfterResponse(func(r *Request, resp *Response) error {
+		route, _ := r.Attr("route")
+		start, ok := r.Attr("start")
+		got = append(got, route, start, ok)
+		return nil
+	})
+
+	if _, err := req.Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	expected := []interface{}{"users", 42, true}
+	if fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Error(
+			"For", "Attr",
+			"expected", expected,
+			"got", got,
+		)
+	}
+
+	if _, ok := req.Attr("start"); ok {
+		t.Error(
+			"For", "caller attributes",
+			"expected", "unchanged",
+			"got", "modified",
+		)
+	}
+}
+
+// TestAfterResponseHookError tests an after hook rejecting a response
+func TestAfterResponseHookError(t *testing.T) {
+	t.Log("Rejecting non-2xx responses in after hook... (expected hook error with response)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer ts.Close()
+
+	errNotOK := errors.New("not ok")
+	var calls, errorHooks int
+	req := NewRequest()
+	req.OnAfterResponse(func(r *Request, resp *Response) error {
+		calls++
+		if resp.GetStatusCode()/100 != 2 {
+			return errNotOK
+		}
+		return nil
+	})
+	req.OnAfterResponse(func(r *Request, resp *Response) error {
+		calls++
+		return nil
+	})
+	req.OnError(func(r *Request, err error) {
+		errorHooks++
+	})
+
+	resp, err := req.Get(ts.URL)
+	if err != errNotOK || resp == nil || resp.GetStatusCode() != http.StatusNotFound || calls != 1 || errorHooks != 1 {
+		t.Error(
+			"For", "404",
+			"expected", errNotOK, "with response, 1 hook call, 1 error hook call",
+			"got", err, resp, calls, errorHooks,
+		)
+	}
+}
+
+// TestDo tests Do with extension methods
+func TestDo(t *testing.T) {
+	t.Log("Sending requests with WebDAV methods... (expected upper-cased method)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s", r.Method, r.Header.Get("Depth"), body)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		method   string
+		expected string
+	}{
+		{"PROPFIND", "PROPFIND 1 <propfind/>"},
+		{"mkcol", "MKCOL 1 <propfind/>"},
+		{"Report", "REPORT 1 <propfind/>"},
+	}
+
+	for _, tt := range tests {
+		resp, err := NewRequest().Headers(map[string]string{"Depth": "1"}).Text("<propfind/>").Do(tt.method, ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if body, _ := resp.GetBodyAsString(); body != tt.expected {
+			t.Error(
+				"For", tt.method,
+				"expected", tt.expected,
+				"got", body,
+			)
+		}
+	}
+}
+
+// endlessBody is an io.WriterTo writing chunks until the writer fails
+type endlessBody struct {
+	written chan struct{}
+}
+
+func (e endlessBody) WriteTo(w io.Writer) (int64, error) {
+	chunk := make([]byte, 64<<10)
+	var n int64
+	for {
+		m, err := w.Write(chunk)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+		select {
+		case e.written <- struct{}{}:
+		default:
+		}
+	}
+}
+
+// TestBodyWriterToCancel tests a cancelled streaming upload leaks no goroutine
+func TestBodyWriterToCancel(t *testing.T) {
+	t.Log("Cancelling a streaming upload... (expected no leaked goroutines)")
+
+	defer goleak.VerifyNone(t, goleak.IgnoreCurrent())
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.Copy(ioutil.Discard, r.Body)
+	}))
+	defer ts.Close()
+
+	tr := &http.Transport{}
+	defer tr.CloseIdleConnections()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	body := endlessBody{written: make(chan struct{})}
+	go func() {
+		<-body.written
+		cancel()
+	}()
+
+	_, err := NewRequest(SetTransport(tr)).SetContext(ctx).BodyWriterTo(body, "application/octet-stream").Post(ts.URL)
+	if !errors.Is(err, context.Canceled) {
+		t.Error(
+			"For", "cancelled upload",
+			"expected", context.Canceled,
+			"got", err,
+		)
+	}
+}
+
+// TestClone tests concurrent sends of clones of a template request
+func TestClone(t *testing.T) {
+	t.Log("Sending clones concurrently... (expected own headers and body, shared client)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		fmt.Fprintf(w, "%s %s %s %s", r.Header.Get("X-Id"), r.Header.Get("X-Team"), r.URL.RawQuery, body)
+	}))
+	defer ts.Close()
+
+	template := NewRequest().
+		Headers(map[string]string{"X-Team": "core"}).
+		FormData(map[string]string{"name": "gohttp"})
+
+	const n = 50
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func(i int) {
+			clone := template.Clone()
+			if clone.client != template.client {
+				errs <- errors.New("client not shared")
+				return
+			}
+			clone.Headers(map[string]string{"X-Id": fmt.Sprint(i), "X-Team": "core"}).
+				Query(map[string]string{"page": fmt.Sprint(i)})
+
+			resp, err := clone.Post(ts.URL)
+			if err != nil {
+				errs <- err
+				return
+			}
+			got, _ := resp.GetBodyAsString()
+			if expected := fmt.Sprintf("%d core page=%d name=gohttp", i, i); got != expected {
+				errs <- fmt.Errorf("expected %q, got %q", expected, got)
+				return
+			}
+			errs <- nil
+		}(i)
+	}
+
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Error(
+				"For", "clone",
+				"expected", "no error",
+				"got", err,
+			)
+		}
+	}
+
+	if len(template.queryVals) != 0 || len(template.headers) != 1 || string(template.BodyBytes()) != "name=gohttp" {
+		t.Error(
+			"For", "template",
+			"expected", "unchanged",
+			"got", template.queryVals, template.headers, string(template.BodyBytes()),
+		)
+	}
+}
+
+// TestCloneReusesConnections tests clones of a request reuse the
+// connections of its transport
+func TestCloneReusesConnections(t *testing.T) {
+	t.Log("Sending clones one after the other... (expected one connection)")
+
+	var conns int32
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := ioutil.ReadAll(r.Body)
+		w.Write(body)
+	}))
+	ts.Config.ConnState = func(c net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			atomic.AddInt32(&conns, 1)
+		}
+	}
+	ts.Start()
+	defer ts.Close()
+
+	template := NewRequest(SetTransport(&http.Transport{}))
+	var reused []bool
+	for _, body := range []string{"first", "second", "third"} {
+		ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				reused = append(reused, info.Reused)
+			},
+		})
+		resp, err := template.Clone().SetContext(ctx).Text(body).Post(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := resp.GetBodyAsString(); got != body {
+			t.Error(
+				"For", body,
+				"expected", body,
+				"got", got,
+			)
+		}
+	}
+
+	if fmt.Sprint(reused) != "[false true true]" || atomic.LoadInt32(&conns) != 1 {
+		t.Error(
+			"For", "clones",
+			"expected", "[false true true]", 1,
+			"got", reused, conns,
+		)
+	}
+}
+
+// TestConcurrentSend tests a multipart request sent concurrently and again
+// after more parts are added
+func TestConcurrentSend(t *testing.T) {
+	t.Log("Sending multipart request concurrently... (expected complete form every time)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := r.ParseMultipartForm(1 << 20); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		fmt.Fprint(w, r.MultipartForm.Value)
+	}))
+	defer ts.Close()
+
+	req := NewRequest().MultipartFormData(map[string]string{"name": "gohttp"})
+
+	const n = 20
+	errs := make(chan error, n)
+	for i := 0; i < n; i++ {
+		go func() {
+			resp, err := req.Post(ts.URL)
+			if err == nil {
+				var got string
+				got, err = resp.GetBodyAsString()
+				if err == nil && got != "map[name:[gohttp]]" {
+					err = fmt.Errorf("expected %q, got %q", "map[name:[gohttp]]", got)
+				}
+			}
+			errs <- err
+		}()
+	}
+	for i := 0; i < n; i++ {
+		if err := <-errs; err != nil {
+			t.Error(
+				"For", "concurrent send",
+				"expected", "no error",
+				"got", err,
+			)
+		}
+	}
+
+	resp, err := req.MultipartFormData(map[string]string{"team": "core"}).Post(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got, _ := resp.GetBodyAsString(); got != "map[name:[gohttp] team:[core]]" {
+		t.Error(
+			"For", "part added after send",
+			"expected", "map[name:[gohttp] team:[core]]",
+			"got", got,
+		)
+	}
+}
+
+// TestPriority tests the Priority header
+func TestPriority(t *testing.T) {
+	t.Log("Sending GET requests with priority... (expected Priority header)")
+
+	ts := newHeaderServer(t, "Priority")
+
+	tests := []struct {
+		urgency     int
+		incremental bool
+		expected    string
+		fails       bool
+	}{
+		{0, false, "u=0", false},
+		{3, true, "u=3, i", false},
+		{7, true, "u=7, i", false},
+		{8, false, "", true},
+		{-1, true, "", true},
+	}
+
+	for _, tt := range tests {
+		resp, err := NewRequest().Priority(tt.urgency, tt.incremental).Get(ts.URL)
+		if (err != nil) != tt.fails {
+			t.Fatal(tt.urgency, err)
+		}
+		if tt.fails {
+			continue
+		}
+
+		if body, _ := resp.GetBodyAsString(); body != tt.expected {
+			t.Error(
+				"For", tt.urgency, tt.incremental,
+				"expected", tt.expected,
+				"got", body,
+			)
+		}
+	}
+}
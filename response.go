@@ -1,16 +1,81 @@
 package gohttp
 
 import (
+	"archive/zip"
+	"bytes"
 	"encoding/json"
+	"errors"
+	"fmt"
 	"io"
+	"io/fs"
 	"io/ioutil"
+	"mime"
 	"net/http"
 	"net/url"
+	"os"
+	"strings"
 )
 
+// ErrNotJSON is returned when a response body is expected to be JSON but
+// is not
+var ErrNotJSON = errors.New("gohttp: response body is not valid JSON")
+
+// NotJSONError is returned when a response body is not valid JSON, it
+// holds the beginning of the body for diagnosis. ContentType is set when
+// the response was refused for its Content-Type.
+type NotJSONError struct {
+	Snippet     []byte
+	ContentType string
+}
+
+// newNotJSONError keeps up to the first 100 bytes of body
+func newNotJSONError(body []byte) *NotJSONError {
+	return &NotJSONError{Snippet: snippet(body)}
+}
+
+// snippet returns up to the first 100 bytes of body for diagnosis
+func snippet(body []byte) []byte {
+	if len(body) > 100 {
+		body = body[:100]
+	}
+	return body
+}
+
+func (e *NotJSONError) Error() string {
+	if e.ContentType != "" {
+		return fmt.Sprintf("%v: Content-Type %q: %q", ErrNotJSON, e.ContentType, e.Snippet)
+	}
+	return fmt.Sprintf("%v: %q", ErrNotJSON, e.Snippet)
+}
+
+// Is reports whether target is ErrNotJSON
+func (e *NotJSONError) Is(target error) bool {
+	return target == ErrNotJSON
+}
+
+// ErrBadStatus is returned when a response with an error status is used
+// where a successful one is expected
+var ErrBadStatus = errors.New("gohttp: unexpected error status")
+
+// DownloadError is returned when saving a response body fails
+type DownloadError struct {
+	StatusCode int
+	URL        string
+	Err        error
+}
+
+func (e *DownloadError) Error() string {
+	return fmt.Sprintf("gohttp: saving response of %s (status %d): %v", e.URL, e.StatusCode, e.Err)
+}
+
+func (e *DownloadError) Unwrap() error {
+	return e.Err
+}
+
 // Response is a http response struct
 type Response struct {
-	resp *http.Response
+	resp              *http.Response
+	contentTypePolicy contentTypePolicy
 }
 
 // AsyncResponse is a response struct for asynchronous request
@@ -34,6 +99,30 @@ func (res *Response) GetStatusCode() int {
 	return res.resp.StatusCode
 }
 
+// Header returns the first value of the response header key, e.g.
+// Location or X-RateLimit-Remaining, empty if there is none
+func (res *Response) Header(key string) string {
+	return res.Headers().Get(key)
+}
+
+// Headers returns the response headers, nil if Response is not returned
+// from a Request
+func (res *Response) Headers() http.Header {
+	if res.resp == nil {
+		return nil
+	}
+	return res.resp.Header
+}
+
+// Cookies returns the cookies set by the Set-Cookie headers of the
+// response
+func (res *Response) Cookies() []*http.Cookie {
+	if res.resp == nil {
+		return nil
+	}
+	return res.resp.Cookies()
+}
+
 // GetBody returns response body
 // It is the caller's responsibility to close Body
 func (res *Response) GetBody() io.ReadCloser {
@@ -43,6 +132,14 @@ func (res *Response) GetBody() io.ReadCloser {
 	return res.resp.Body
 }
 
+// Stream returns the unbuffered response body for reading it as it
+// arrives, e.g. for downloads too large to hold in memory. The caller must
+// close it. The body can only be consumed once, so Stream is mutually
+// exclusive with GetBodyAsByte, GetBodyAsString, RawJSON and UnmarshalBody.
+func (res *Response) Stream() io.ReadCloser {
+	return res.GetBody()
+}
+
 // GetBodyAsByte returns response body as byte
 func (res *Response) GetBodyAsByte() ([]byte, error) {
 	body := res.GetBody()
@@ -79,12 +176,99 @@ func (res *Response) GetBodyAsJSONRawMessage() (json.RawMessage, error) {
 	return json.RawMessage(body), nil
 }
 
+// RawJSON returns response body as json.RawMessage after checking it is
+// valid JSON, a *NotJSONError is returned otherwise
+func (res *Response) RawJSON() (json.RawMessage, error) {
+	body, err := res.GetBodyAsByte()
+	if err != nil || body == nil {
+		return nil, err
+	}
+
+	if err := res.sniffDecoded(body); err != nil {
+		return nil, err
+	}
+	if !json.Valid(body) {
+		return nil, newNotJSONError(body)
+	}
+
+	return json.RawMessage(body), nil
+}
+
+// JSON unmarshals the JSON response body into v. A *NotJSONError is
+// returned when the Content-Type isn't a JSON media type, parameters like
+// charset are ignored and +json types like application/problem+json are
+// accepted. A response without Content-Type is decoded as is, unless a
+// default is set with WithDefaultContentType. See Response.ContentType for
+// responses with several Content-Type headers.
+func (res *Response) JSON(v interface{}) error {
+	body, err := res.GetBodyAsByte()
+	if err != nil {
+		return err
+	}
+
+	ct, err := res.ContentType()
+	if err != nil {
+		return err
+	}
+	if err := res.sniffBody(ct, body); err != nil {
+		return err
+	}
+	if ct != "" && !isJSONContentType(ct) {
+		e := newNotJSONError(body)
+		e.ContentType = ct
+		return e
+	}
+
+	if !json.Valid(body) {
+		return newNotJSONError(body)
+	}
+	return json.Unmarshal(body, v)
+}
+
+// isJSONContentType reports whether the media type of ct is JSON, e.g.
+// application/json, application/problem+json or application/json+fhir
+func isJSONContentType(ct string) bool {
+	mediaType, _, err := mime.ParseMediaType(ct)
+	if err != nil {
+		return false
+	}
+
+	typ, subtype := mediaType, ""
+	if i := strings.IndexByte(mediaType, '/'); i >= 0 {
+		typ, subtype = mediaType[:i], mediaType[i+1:]
+	}
+	if typ != "application" && typ != "text" {
+		return false
+	}
+
+	return subtype == "json" || strings.HasSuffix(subtype, "+json") || strings.HasPrefix(subtype, "json+")
+}
+
+// AsFS returns the zip archive in response body as a file system, e.g. for
+// fs.ReadFile or fs.WalkDir. The body is read into memory since zip needs
+// random access, entries are decompressed when they are opened.
+func (res *Response) AsFS() (fs.FS, error) {
+	body, err := res.GetBodyAsByte()
+	if err != nil {
+		return nil, err
+	}
+
+	zr, err := zip.NewReader(bytes.NewReader(body), int64(len(body)))
+	if err != nil {
+		return nil, err
+	}
+	return zr, nil
+}
+
 // UnmarshalBody unmarshal response body
 func (res *Response) UnmarshalBody(v interface{}) error {
 	body, err := res.GetBodyAsByte()
 	if err != nil || body == nil {
 		return err
 	}
+	if err := res.sniffDecoded(body); err != nil {
+		return err
+	}
 
 	return json.Unmarshal(body, &v)
 }
@@ -97,4 +281,61 @@ func (res *Response) Protocol() string{
 //URL returns response Location
 func (res *Response) URL() (*url.URL, error)  {
 	return res.resp.Location()
-}
\ No newline at end of file
+}
+
+// SaveToFile streams response body into the file at path, the file is
+// created or truncated. Both the body and the file are closed on return.
+// It returns the number of bytes written. A response with a status of 400
+// or above is not saved, ErrBadStatus is returned instead.
+func (res *Response) SaveToFile(path string) (int64, error) {
+	return res.saveToFile(path, nil)
+}
+
+// SaveToFileWithProgress is like SaveToFile and calls fn with the number of
+// bytes written so far
+func (res *Response) SaveToFileWithProgress(path string, fn func(written int64)) (int64, error) {
+	return res.saveToFile(path, fn)
+}
+
+func (res *Response) saveToFile(path string, fn func(written int64)) (int64, error) {
+	body := res.GetBody()
+	if body == nil {
+		return 0, res.downloadError(errors.New("no response body"))
+	}
+	defer body.Close()
+
+	if res.resp.StatusCode >= 400 {
+		return 0, res.downloadError(ErrBadStatus)
+	}
+
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, res.downloadError(err)
+	}
+
+	var r io.Reader = body
+	if fn != nil {
+		r = &progressReader{r: body, total: res.resp.ContentLength, fn: func(written, _ int64) {
+			fn(written)
+		}}
+	}
+
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		return n, res.downloadError(err)
+	}
+
+	return n, nil
+}
+
+// downloadError wraps err with the response status and url
+func (res *Response) downloadError(err error) *DownloadError {
+	dErr := &DownloadError{StatusCode: res.GetStatusCode(), Err: err}
+	if res.resp != nil && res.resp.Request != nil {
+		dErr.URL = res.resp.Request.URL.String()
+	}
+	return dErr
+}
@@ -1,6 +1,29 @@
 package gohttp
 
-import "testing"
+import (
+	"archive/zip"
+	"bytes"
+	"errors"
+	"io/fs"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+// newTestResponse returns a Response with the given status and body
+func newTestResponse(status int, header http.Header, body string) *Response {
+	if header == nil {
+		header = http.Header{}
+	}
+	return &Response{resp: &http.Response{
+		StatusCode: status,
+		Header:     header,
+		Body:       ioutil.NopCloser(strings.NewReader(body)),
+	}}
+}
 
 // TestGetRespResponse tests GetResp response
 func TestGetRespResponse(t *testing.T) {
@@ -52,3 +75,281 @@ func TestGetBodyResponse(t *testing.T) {
 		)
 	}
 }
+
+// TestRawJSONResponse tests RawJSON response
+func TestRawJSONResponse(t *testing.T) {
+	t.Log("(RawJSON expected valid JSON or ErrNotJSON)")
+
+	raw, err := newTestResponse(200, nil, `{"name":"gohttp"}`).RawJSON()
+	if err != nil || string(raw) != `{"name":"gohttp"}` {
+		t.Error(
+			"For", "RawJSON",
+			"expected", `{"name":"gohttp"}`,
+			"got", string(raw), err,
+		)
+	}
+
+	body := "<html>" + strings.Repeat("x", 200)
+	_, err = newTestResponse(200, nil, body).RawJSON()
+
+	var notJSON *NotJSONError
+	if !errors.Is(err, ErrNotJSON) || !errors.As(err, &notJSON) || string(notJSON.Snippet) != body[:100] {
+		t.Error(
+			"For", "RawJSON",
+			"expected", ErrNotJSON,
+			"got", err,
+		)
+	}
+}
+
+// TestSaveToFileResponse tests SaveToFile response
+func TestSaveToFileResponse(t *testing.T) {
+	t.Log("(SaveToFile expected body in file)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("downloaded content"))
+	}))
+	defer ts.Close()
+
+	resp, err := NewRequest().Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var written int64
+	path := filepath.Join(t.TempDir(), "download.txt")
+	n, err := resp.SaveToFileWithProgress(path, func(w int64) { written = w })
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, _ := ioutil.ReadFile(path)
+	if string(data) != "downloaded content" || written != 18 || n != 18 {
+		t.Error(
+			"For", "SaveToFile",
+			"expected", "downloaded content",
+			"got", string(data), written,
+		)
+	}
+
+	resp, err = NewRequest().Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	_, err = resp.SaveToFile(filepath.Join(t.TempDir(), "missing", "download.txt"))
+
+	var dErr *DownloadError
+	if !errors.As(err, &dErr) || dErr.StatusCode != 200 || dErr.URL != ts.URL {
+		t.Error(
+			"For", "SaveToFile",
+			"expected", "DownloadError",
+			"got", err,
+		)
+	}
+}
+
+// TestStreamResponse tests Stream response
+func TestStreamResponse(t *testing.T) {
+	t.Log("(Stream expected body as it is written)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < 3; i++ {
+			w.Write([]byte("chunk\n"))
+			w.(http.Flusher).Flush()
+		}
+	}))
+	defer ts.Close()
+
+	resp, err := NewRequest().Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stream := resp.Stream()
+	defer stream.Close()
+
+	data, err := ioutil.ReadAll(stream)
+	if err != nil || string(data) != strings.Repeat("chunk\n", 3) {
+		t.Error(
+			"For", "Stream",
+			"expected", "3 chunks",
+			"got", string(data), err,
+		)
+	}
+
+	if (&Response{}).Stream() != nil {
+		t.Error(
+			"For", "Stream",
+			"expected", "nil",
+			"got", "value",
+		)
+	}
+}
+
+// TestSaveToFileErrorStatus tests SaveToFile refuses error responses
+func TestSaveToFileErrorStatus(t *testing.T) {
+	t.Log("(SaveToFile expected ErrBadStatus for 404)")
+
+	path := filepath.Join(t.TempDir(), "download.txt")
+	n, err := newTestResponse(404, nil, "not found").SaveToFile(path)
+
+	var dErr *DownloadError
+	if !errors.Is(err, ErrBadStatus) || !errors.As(err, &dErr) || dErr.StatusCode != 404 || n != 0 {
+		t.Error(
+			"For", "SaveToFile",
+			"expected", ErrBadStatus,
+			"got", err,
+		)
+	}
+
+	if _, err := ioutil.ReadFile(path); err == nil {
+		t.Error(
+			"For", "SaveToFile",
+			"expected", "no file",
+			"got", "file",
+		)
+	}
+}
+
+// TestAsFS tests AsFS with a zip response
+func TestAsFS(t *testing.T) {
+	t.Log("Opening zip response as file system... (expected entry content)")
+
+	var buf bytes.Buffer
+	zw := zip.NewWriter(&buf)
+	for name, content := range map[string]string{"readme.txt": "hello", "dir/data.json": `{"a":1}`} {
+		w, err := zw.Create(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		w.Write([]byte(content))
+	}
+	zw.Close()
+
+	fsys, err := newTestResponse(200, nil, buf.String()).AsFS()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := fs.ReadFile(fsys, "dir/data.json")
+	if err != nil || string(data) != `{"a":1}` {
+		t.Error(
+			"For", "AsFS",
+			"expected", `{"a":1}`,
+			"got", string(data), err,
+		)
+	}
+
+	if _, err := newTestResponse(200, nil, "not a zip").AsFS(); !errors.Is(err, zip.ErrFormat) {
+		t.Error(
+			"For", "AsFS of plain body",
+			"expected", zip.ErrFormat,
+			"got", err,
+		)
+	}
+}
+
+// TestResponseJSON tests JSON with Content-Type variants
+func TestResponseJSON(t *testing.T) {
+	t.Log("Decoding JSON responses... (expected JSON media types accepted)")
+
+	tests := []struct {
+		contentType string
+		body        string
+		ok          bool
+	}{
+		{"application/json", `{"id":1}`, true},
+		{"application/json; charset=utf-8", `{"id":1}`, true},
+		{"application/json;charset=utf-8", `{"id":1}`, true},
+		{"Application/JSON", `{"id":1}`, true},
+		{"application/json+fhir", `{"id":1}`, true},
+		{"application/problem+json", `{"id":1}`, true},
+		{"", `{"id":1}`, true},
+		{"text/html; charset=utf-8", `{"id":1}`, false},
+		{"application/jsonp", `{"id":1}`, false},
+		{"application/json; charset", `{"id":1}`, false},
+		{"application/json", `<html>`, false},
+	}
+
+	for _, tt := range tests {
+		var v struct{ ID int }
+		err := newTestResponse(200, http.Header{"Content-Type": {tt.contentType}}, tt.body).JSON(&v)
+
+		if tt.ok && (err != nil || v.ID != 1) || !tt.ok && !errors.Is(err, ErrNotJSON) {
+			t.Error(
+				"For", tt.contentType, tt.body,
+				"expected", "ok:", tt.ok,
+				"got", err,
+			)
+		}
+	}
+}
+
+// TestResponseHeadersAndCookies tests the headers and cookies set by a
+// server are exposed by the response
+func TestResponseHeadersAndCookies(t *testing.T) {
+	t.Log("Reading response headers and cookies... (expected values set by server)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("Location", "/next")
+		w.Header().Add("X-Trace", "a")
+		w.Header().Add("X-Trace", "b")
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/", HttpOnly: true})
+		http.SetCookie(w, &http.Cookie{Name: "theme", Value: "dark"})
+		w.WriteHeader(http.StatusCreated)
+	}))
+	defer ts.Close()
+
+	resp, err := NewRequest().Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	headers := []struct {
+		key      string
+		expected string
+	}{
+		{"X-RateLimit-Remaining", "42"},
+		{"x-ratelimit-remaining", "42"},
+		{"Location", "/next"},
+		{"X-Trace", "a"},
+		{"X-Missing", ""},
+	}
+	for _, tt := range headers {
+		if got := resp.Header(tt.key); got != tt.expected {
+			t.Error(
+				"For", tt.key,
+				"expected", tt.expected,
+				"got", got,
+			)
+		}
+	}
+	if got := resp.Headers().Values("X-Trace"); len(got) != 2 || len(resp.Headers()["Set-Cookie"]) != 2 {
+		t.Error(
+			"For", "Headers",
+			"expected", "2 X-Trace and 2 Set-Cookie",
+			"got", resp.Headers(),
+		)
+	}
+
+	cookies := resp.Cookies()
+	if len(cookies) != 2 || cookies[0].Name != "session" || cookies[0].Value != "s3cr3t" || !cookies[0].HttpOnly ||
+		cookies[1].Name != "theme" || cookies[1].Value != "dark" {
+		t.Error(
+			"For", "Cookies",
+			"expected", "session and theme",
+			"got", cookies,
+		)
+	}
+
+	empty := &Response{}
+	if empty.Header("Location") != "" || empty.Headers() != nil || empty.Cookies() != nil {
+		t.Error(
+			"For", "empty response",
+			"expected", "no headers and cookies",
+			"got", empty.Headers(), empty.Cookies(),
+		)
+	}
+}
@@ -0,0 +1,123 @@
+package gohttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+// ErrRetryAfterTooLong is returned when a server asks to wait longer than
+// allowed by WithMaxRetryAfter before retrying
+var ErrRetryAfterTooLong = errors.New("gohttp: Retry-After exceeds the allowed maximum")
+
+// RetryAfterError describes a Retry-After wait which was refused
+type RetryAfterError struct {
+	StatusCode int
+	Wait       time.Duration
+	Max        time.Duration
+}
+
+func (e *RetryAfterError) Error() string {
+	return fmt.Sprintf("gohttp: status %d asks to retry after %s, maximum is %s", e.StatusCode, e.Wait, e.Max)
+}
+
+// Is reports whether target is ErrRetryAfterTooLong
+func (e *RetryAfterError) Is(target error) bool {
+	return target == ErrRetryAfterTooLong
+}
+
+// defaultMaxRetryAfter is the longest Retry-After wait honored unless
+// WithMaxRetryAfter sets another one
+const defaultMaxRetryAfter = 30 * time.Second
+
+// isRetryableStatus reports whether the server asked to try again later
+func isRetryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code == http.StatusServiceUnavailable
+}
+
+// retryDelay returns how long to wait before retrying after resp. The
+// Retry-After header is used when present, otherwise the backoff doubles
+// with every attempt.
+func (req *Request) retryDelay(resp *http.Response, attempt int) (time.Duration, error) {
+	wait, ok := parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+	if !ok {
+		return backoffDelay(req.retryBackoff, attempt), nil
+	}
+
+	if req.maxRetryAfter > 0 && wait > req.maxRetryAfter {
+		return 0, &RetryAfterError{StatusCode: resp.StatusCode, Wait: wait, Max: req.maxRetryAfter}
+	}
+
+	return wait, nil
+}
+
+// backoffDelay returns backoff doubled attempt times, the longest
+// time.Duration once doubling it would overflow
+func backoffDelay(backoff time.Duration, attempt int) time.Duration {
+	if backoff <= 0 {
+		return 0
+	}
+	if attempt >= 63 || backoff > time.Duration(math.MaxInt64)>>uint(attempt) {
+		return time.Duration(math.MaxInt64)
+	}
+	return backoff << uint(attempt)
+}
+
+// maxRetryAfterSeconds is the longest delta-seconds a time.Duration holds
+const maxRetryAfterSeconds = int64(math.MaxInt64 / time.Second)
+
+// parseRetryAfter parses a Retry-After value in either the delta-seconds or
+// the HTTP-date form. Delta-seconds too large for a time.Duration give the
+// longest one, so they still exceed any WithMaxRetryAfter.
+func parseRetryAfter(val string, now time.Time) (time.Duration, bool) {
+	if val == "" {
+		return 0, false
+	}
+
+	secs, err := strconv.ParseInt(val, 10, 64)
+	if numErr, ok := err.(*strconv.NumError); ok && numErr.Err == strconv.ErrRange {
+		// secs is clamped to the int64 bounds
+		err = nil
+	}
+	if err == nil {
+		if secs < 0 {
+			return 0, false
+		}
+		if secs > maxRetryAfterSeconds {
+			return time.Duration(math.MaxInt64), true
+		}
+		return time.Duration(secs) * time.Second, true
+	}
+
+	date, err := http.ParseTime(val)
+	if err != nil {
+		return 0, false
+	}
+
+	wait := date.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	return wait, true
+}
+
+// sleepContext waits for d or until ctx is done
+func sleepContext(ctx context.Context, d time.Duration) error {
+	if d <= 0 {
+		return withCancelCause(ctx, ctx.Err())
+	}
+
+	timer := time.NewTimer(d)
+	defer timer.Stop()
+
+	select {
+	case <-ctx.Done():
+		return withCancelCause(ctx, ctx.Err())
+	case <-timer.C:
+		return nil
+	}
+}
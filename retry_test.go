@@ -0,0 +1,204 @@
+package gohttp
+
+import (
+	"errors"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyServer returns a server answering the first failures requests
+// with status and header, and 200 afterwards
+func newFlakyServer(t *testing.T, failures int32, status int, retryAfter string) (*httptest.Server, *int32) {
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if atomic.AddInt32(&hits, 1) <= failures {
+			if retryAfter != "" {
+				w.Header().Set("Retry-After", retryAfter)
+			}
+			w.WriteHeader(status)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(ts.Close)
+	return ts, &hits
+}
+
+// TestRetryAfterSeconds tests a retry honoring Retry-After in seconds
+func TestRetryAfterSeconds(t *testing.T) {
+	t.Log("Sending GET request to 429 server... (expected one delayed retry)")
+
+	ts, hits := newFlakyServer(t, 1, http.StatusTooManyRequests, "1")
+
+	start := time.Now()
+	resp, err := NewRequest(WithRetry(3, time.Millisecond)).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.GetStatusCode() != 200 || atomic.LoadInt32(hits) != 2 {
+		t.Error(
+			"For", "GET with Retry-After: 1",
+			"expected", "200 after 2 attempts",
+			"got", resp.GetStatusCode(), atomic.LoadInt32(hits),
+		)
+	}
+
+	if elapsed := time.Since(start); elapsed < time.Second {
+		t.Error(
+			"For", "GET with Retry-After: 1",
+			"expected", "at least 1s",
+			"got", elapsed,
+		)
+	}
+}
+
+// TestRetryBackoff tests a retry without Retry-After
+func TestRetryBackoff(t *testing.T) {
+	t.Log("Sending GET request to 503 server... (expected two retries)")
+
+	ts, hits := newFlakyServer(t, 2, http.StatusServiceUnavailable, "")
+
+	resp, err := NewRequest(WithRetry(2, time.Millisecond)).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if resp.GetStatusCode() != 200 || atomic.LoadInt32(hits) != 3 {
+		t.Error(
+			"For", "GET with backoff",
+			"expected", "200 after 3 attempts",
+			"got", resp.GetStatusCode(), atomic.LoadInt32(hits),
+		)
+	}
+}
+
+// TestMaxRetryAfter tests Retry-After above the configured maximum
+func TestMaxRetryAfter(t *testing.T) {
+	t.Log("Sending GET request asking for a long wait... (expected ErrRetryAfterTooLong)")
+
+	ts, hits := newFlakyServer(t, 1, http.StatusServiceUnavailable, "3600")
+
+	_, err := NewRequest(WithRetry(3, time.Millisecond), WithMaxRetryAfter(30*time.Second)).Get(ts.URL)
+
+	var raErr *RetryAfterError
+	if !errors.Is(err, ErrRetryAfterTooLong) || !errors.As(err, &raErr) || raErr.Wait != time.Hour {
+		t.Error(
+			"For", "GET with Retry-After: 3600",
+			"expected", ErrRetryAfterTooLong,
+			"got", err,
+		)
+	}
+
+	if atomic.LoadInt32(hits) != 1 {
+		t.Error(
+			"For", "GET with Retry-After: 3600",
+			"expected", 1,
+			"got", atomic.LoadInt32(hits),
+		)
+	}
+}
+
+// TestDefaultMaxRetryAfter tests Retry-After above the default maximum
+func TestDefaultMaxRetryAfter(t *testing.T) {
+	t.Log("Sending GET request asking for a long wait without a maximum set... (expected ErrRetryAfterTooLong)")
+
+	ts, hits := newFlakyServer(t, 1, http.StatusServiceUnavailable, "3600")
+
+	_, err := NewRequest(WithRetry(3, time.Millisecond)).Get(ts.URL)
+
+	var raErr *RetryAfterError
+	if !errors.Is(err, ErrRetryAfterTooLong) || !errors.As(err, &raErr) || raErr.Max != 30*time.Second {
+		t.Error(
+			"For", "GET with Retry-After: 3600",
+			"expected", ErrRetryAfterTooLong,
+			"got", err,
+		)
+	}
+
+	if atomic.LoadInt32(hits) != 1 {
+		t.Error(
+			"For", "GET with Retry-After: 3600",
+			"expected", 1,
+			"got", atomic.LoadInt32(hits),
+		)
+	}
+
+	t.Log("Disabling the maximum... (expected the wait honored)")
+
+	req := NewRequest(WithMaxRetryAfter(0))
+	resp := &http.Response{StatusCode: http.StatusServiceUnavailable, Header: http.Header{"Retry-After": {"3600"}}}
+	if wait, err := req.retryDelay(resp, 0); err != nil || wait != time.Hour {
+		t.Error(
+			"For", "WithMaxRetryAfter(0)",
+			"expected", time.Hour,
+			"got", wait, err,
+		)
+	}
+}
+
+// TestBackoffDelay tests the doubling backoff can't overflow
+func TestBackoffDelay(t *testing.T) {
+	t.Log("Doubling backoffs... (expected the longest duration once they overflow)")
+
+	tests := []struct {
+		backoff time.Duration
+		attempt int
+		want    time.Duration
+	}{
+		{time.Millisecond, 0, time.Millisecond},
+		{time.Millisecond, 3, 8 * time.Millisecond},
+		{0, 100, 0},
+		{time.Second, 40, time.Duration(math.MaxInt64)},
+		{time.Second, 64, time.Duration(math.MaxInt64)},
+		{time.Nanosecond, 62, time.Duration(1) << 62},
+		{time.Nanosecond, 63, time.Duration(math.MaxInt64)},
+	}
+
+	for _, test := range tests {
+		if got := backoffDelay(test.backoff, test.attempt); got != test.want {
+			t.Error(
+				"For", test.backoff, test.attempt,
+				"expected", test.want,
+				"got", got,
+			)
+		}
+	}
+}
+
+// TestParseRetryAfter tests both Retry-After forms
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2020, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		val  string
+		wait time.Duration
+		ok   bool
+	}{
+		{"", 0, false},
+		{"120", 2 * time.Minute, true},
+		{"-1", 0, false},
+		{"9223372036", 9223372036 * time.Second, true},
+		{"9999999999999", time.Duration(math.MaxInt64), true},
+		{"99999999999999999999", time.Duration(math.MaxInt64), true},
+		{"-99999999999999999999", 0, false},
+		{"Wed, 01 Jan 2020 00:00:30 GMT", 30 * time.Second, true},
+		{"Tue, 31 Dec 2019 23:00:00 GMT", 0, true},
+		{"soon", 0, false},
+	}
+
+	for _, tt := range tests {
+		wait, ok := parseRetryAfter(tt.val, now)
+		if wait != tt.wait || ok != tt.ok {
+			t.Error(
+				"For", tt.val,
+				"expected", tt.wait, tt.ok,
+				"got", wait, ok,
+			)
+		}
+	}
+}
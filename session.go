@@ -0,0 +1,27 @@
+package gohttp
+
+// Session is a Client under the name other HTTP libraries use. Its requests
+// share one http.Client, and so its connections, along with the session
+// defaults, e.g. WithBaseURL, WithHeaders, WithCookieJar or SetTransport.
+// It is safe for concurrent use once configured.
+type Session struct {
+	*Client
+}
+
+// NewSession returns a session configured with opts, its http client is
+// built right away like the one of NewClient. The cookies are kept in a
+// jar of the session, see WithDefaultCookieJar, unless opts set another.
+func NewSession(opts ...Option) *Session {
+	opts = append([]Option{WithDefaultCookieJar()}, opts...)
+	return &Session{Client: NewClient(opts...)}
+}
+
+// NewRequest returns a new request bound to the session, like R. Its
+// options and methods override the session defaults for this request only,
+// except the options building the http client, like SetTransport,
+// WithCookieJar, WithProxy, SetTimeout or WithRedirectPolicy, which are
+// only taken from NewSession. Use another session for those, or Timeout for
+// the timeout of a request.
+func (s *Session) NewRequest(opts ...Option) *Request {
+	return s.R(opts...)
+}
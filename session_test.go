@@ -0,0 +1,125 @@
+package gohttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"sync"
+	"testing"
+)
+
+// TestSession tests requests of a session reuse its connections and
+// defaults, also when sent concurrently
+func TestSession(t *testing.T) {
+	t.Log("Sending requests with a session... (expected reused connections and defaults)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, "%s %s %s", r.URL.Path, r.Header.Get("X-App"), r.Header.Get("X-Call"))
+	}))
+	defer ts.Close()
+
+	s := NewSession(WithBaseURL(ts.URL+"/api"), WithHeaders(map[string]string{"X-App": "demo"}))
+
+	var reused []bool
+	for i := 0; i < 3; i++ {
+		ctx := httptrace.WithClientTrace(context.Background(), &httptrace.ClientTrace{
+			GotConn: func(info httptrace.GotConnInfo) {
+				reused = append(reused, info.Reused)
+			},
+		})
+		resp, err := s.NewRequest().SetContext(ctx).Get("/users")
+		if err != nil {
+			t.Fatal(err)
+		}
+		if body, _ := resp.GetBodyAsString(); body != "/api/users demo " {
+			t.Error(
+				"For", "session defaults",
+				"expected", "/api/users demo ",
+				"got", body,
+			)
+		}
+	}
+	if fmt.Sprint(reused) != "[false true true]" {
+		t.Error(
+			"For", "GotConn.Reused",
+			"expected", "[false true true]",
+			"got", reused,
+		)
+	}
+
+	var wg sync.WaitGroup
+	errs := make(chan error, 20)
+	for i := 0; i < 20; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			call := fmt.Sprint(i)
+			resp, err := s.NewRequest().Headers(map[string]string{"X-Call": call}).Get("/orders")
+			if err != nil {
+				errs <- err
+				return
+			}
+			if body, _ := resp.GetBodyAsString(); body != "/api/orders demo "+call {
+				errs <- fmt.Errorf("call %s got %q", call, body)
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+	for err := range errs {
+		t.Error(
+			"For", "concurrent requests",
+			"expected", "own headers",
+			"got", err,
+		)
+	}
+
+	if s.NewRequest().createClient() != s.HTTPClient() {
+		t.Error(
+			"For", "NewRequest",
+			"expected", "shared http client",
+			"got", "new client",
+		)
+	}
+}
+
+// TestSessionCookies tests a session keeps the cookies set by its responses
+func TestSessionCookies(t *testing.T) {
+	t.Log("Sending requests with a session... (expected cookie sent back)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/login" {
+			http.SetCookie(w, &http.Cookie{Name: "session", Value: "s3cr3t", Path: "/"})
+			return
+		}
+		if c, err := r.Cookie("session"); err == nil {
+			w.Write([]byte(c.Value))
+		}
+	}))
+	defer ts.Close()
+
+	s := NewSession(WithBaseURL(ts.URL))
+	if _, err := s.NewRequest().Get("/login"); err != nil {
+		t.Fatal(err)
+	}
+	resp, err := s.NewRequest().Get("/profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := NewSession(WithBaseURL(ts.URL)).NewRequest().Get("/profile")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := resp.GetBodyAsString()
+	gotOther, _ := other.GetBodyAsString()
+	if got != "s3cr3t" || gotOther != "" {
+		t.Error(
+			"For", "session cookies",
+			"expected", "s3cr3t only in the same session",
+			"got", got, gotOther,
+		)
+	}
+}
@@ -0,0 +1,87 @@
+package gohttp
+
+import (
+	"context"
+	"io"
+	"math/rand"
+	"os"
+	"time"
+)
+
+// DevShapingEnv is the environment variable which, set to any value,
+// allows WithDevShaping like the AllowDevShaping option
+const DevShapingEnv = "GOHTTP_DEV_SHAPING"
+
+// devShaping delays and throttles requests to simulate a slow network
+type devShaping struct {
+	latency   time.Duration
+	jitter    time.Duration
+	bandwidth int64
+
+	// replaced by tests
+	sleep  func(ctx context.Context, d time.Duration) error
+	random func(n int64) int64
+}
+
+func newDevShaping(latency, jitter time.Duration, bandwidth int64) *devShaping {
+	return &devShaping{
+		latency:   latency,
+		jitter:    jitter,
+		bandwidth: bandwidth,
+		sleep:     sleepContext,
+		random:    rand.Int63n,
+	}
+}
+
+// devShapingActive reports whether WithDevShaping is set and allowed
+func (req *Request) devShapingActive() bool {
+	if req.devShaping == nil {
+		return false
+	}
+	if req.allowDevShaping {
+		return true
+	}
+	_, ok := os.LookupEnv(DevShapingEnv)
+	return ok
+}
+
+// delay waits for the latency plus a random jitter before a request is
+// dispatched
+func (s *devShaping) delay(ctx context.Context) error {
+	d := s.latency
+	if s.jitter > 0 {
+		d += time.Duration(s.random(int64(s.jitter)))
+	}
+	return s.sleep(ctx, d)
+}
+
+// throttle limits reads of body to the bandwidth
+func (s *devShaping) throttle(ctx context.Context, body io.ReadCloser) io.ReadCloser {
+	if s.bandwidth <= 0 {
+		return body
+	}
+	return &throttledReader{ReadCloser: body, ctx: ctx, shaping: s}
+}
+
+// throttledReader sleeps after every read for as long as the bytes read
+// take at the bandwidth of shaping
+type throttledReader struct {
+	io.ReadCloser
+	ctx     context.Context
+	shaping *devShaping
+}
+
+func (t *throttledReader) Read(b []byte) (int, error) {
+	if int64(len(b)) > t.shaping.bandwidth {
+		b = b[:t.shaping.bandwidth]
+	}
+
+	n, err := t.ReadCloser.Read(b)
+	if n > 0 {
+		wait := time.Duration(n) * time.Second / time.Duration(t.shaping.bandwidth)
+		if serr := t.shaping.sleep(t.ctx, wait); serr != nil && err == nil {
+			err = serr
+		}
+	}
+	return n, err
+}
@@ -0,0 +1,107 @@
+package gohttp
+
+import (
+	"context"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeSleeper records the sleeps of a devShaping instead of sleeping
+type fakeSleeper struct {
+	slept []time.Duration
+}
+
+func (f *fakeSleeper) sleep(ctx context.Context, d time.Duration) error {
+	f.slept = append(f.slept, d)
+	return ctx.Err()
+}
+
+// TestWithDevShaping tests latency and bandwidth shaping
+func TestWithDevShaping(t *testing.T) {
+	t.Log("Sending GET request with dev shaping... (expected latency within bounds and throttled body)")
+
+	mock := NewMockTransport().Register("GET", "http://example.com/", mockResponseWith(200, strings.Repeat("x", 250)))
+
+	var clock fakeSleeper
+	req := NewRequest(WithTransport(mock), WithDevShaping(100*time.Millisecond, 50*time.Millisecond, 100), AllowDevShaping())
+	req.devShaping.sleep = clock.sleep
+	req.devShaping.random = func(n int64) int64 { return n - 1 }
+
+	resp, err := req.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body, _ := resp.GetBodyAsString(); len(body) != 250 {
+		t.Fatal(
+			"For", "shaped body",
+			"expected", 250,
+			"got", len(body),
+		)
+	}
+
+	if len(clock.slept) == 0 || clock.slept[0] < 100*time.Millisecond || clock.slept[0] >= 150*time.Millisecond {
+		t.Fatal(
+			"For", "latency",
+			"expected", "between 100ms and 150ms",
+			"got", clock.slept,
+		)
+	}
+
+	var throttled time.Duration
+	for _, d := range clock.slept[1:] {
+		throttled += d
+	}
+	if throttled != 2500*time.Millisecond || !req.Describe().DevShaping {
+		t.Error(
+			"For", "bandwidth",
+			"expected", 2500*time.Millisecond,
+			"got", throttled,
+		)
+	}
+}
+
+// TestWithDevShapingGuard tests shaping is refused without an explicit allowance
+func TestWithDevShapingGuard(t *testing.T) {
+	t.Log("Sending GET request with unallowed dev shaping... (expected no shaping)")
+
+	mock := NewMockTransport().
+		Register("GET", "http://example.com/", mockResponseWith(200, "ok")).
+		Register("GET", "http://example.com/", mockResponseWith(200, "ok"))
+
+	var clock fakeSleeper
+	req := NewRequest(WithTransport(mock), WithDevShaping(time.Second, 0, 1))
+	req.devShaping.sleep = clock.sleep
+
+	resp, err := req.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.GetBodyAsString()
+
+	if len(clock.slept) != 0 || req.Describe().DevShaping {
+		t.Error(
+			"For", "guarded shaping",
+			"expected", "no sleeps",
+			"got", clock.slept,
+		)
+	}
+
+	os.Setenv(DevShapingEnv, "1")
+	defer os.Unsetenv(DevShapingEnv)
+
+	resp, err = req.Get("http://example.com/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.GetBodyAsString()
+
+	if len(clock.slept) == 0 || clock.slept[0] != time.Second {
+		t.Error(
+			"For", DevShapingEnv,
+			"expected", "shaping",
+			"got", clock.slept,
+		)
+	}
+}
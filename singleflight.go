@@ -0,0 +1,135 @@
+package gohttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/ioutil"
+	"net/http"
+	"sync"
+
+	"golang.org/x/sync/singleflight"
+)
+
+// singleFlightGroup deduplicates the requests of every client using
+// WithSingleFlight
+var singleFlightGroup singleflight.Group
+
+// singleFlights are the contexts of the shared requests in flight by key,
+// a shared request is canceled once none of its callers waits for it
+var singleFlights = struct {
+	sync.Mutex
+	m map[string]*singleFlightCall
+}{m: map[string]*singleFlightCall{}}
+
+// singleFlightCall is the context of a shared request and the number of
+// callers waiting for it
+type singleFlightCall struct {
+	ctx     context.Context
+	cancel  context.CancelFunc
+	waiters int
+}
+
+// joinSingleFlight returns the context of the shared request key, made from
+// the values of ctx for the first caller, and a function to call once the
+// caller stops waiting
+func joinSingleFlight(key string, ctx context.Context) (context.Context, func()) {
+	singleFlights.Lock()
+	defer singleFlights.Unlock()
+
+	call := singleFlights.m[key]
+	if call == nil {
+		call = &singleFlightCall{}
+		call.ctx, call.cancel = context.WithCancel(withoutCancel(ctx))
+		singleFlights.m[key] = call
+	}
+	call.waiters++
+
+	return call.ctx, func() {
+		singleFlights.Lock()
+		defer singleFlights.Unlock()
+
+		if call.waiters--; call.waiters == 0 {
+			// the next caller sends a new request instead of joining the
+			// canceled one
+			singleFlightGroup.Forget(key)
+			call.cancel()
+			delete(singleFlights.m, key)
+		}
+	}
+}
+
+// WithSingleFlight option sends only one of the identical GET and HEAD
+// requests in flight at the same time, from any request using this option.
+// The callers waiting for it get a copy of its response. Requests are
+// identical when their method and URL are, the headers are not compared,
+// so it must not be used for responses depending on the caller's auth. It
+// has no effect when a client is given with SetClient.
+//
+// The shared request doesn't end when the caller which sent it is canceled
+// or times out, only that caller stops waiting. It is canceled once no
+// caller waits for it anymore.
+func WithSingleFlight() OptionFunc {
+	return WithMiddleware(singleFlight)
+}
+
+// singleFlightResult is a response shared by deduplicated requests, with
+// its body read already
+type singleFlightResult struct {
+	resp *http.Response
+	body []byte
+}
+
+// singleFlight is the middleware of WithSingleFlight
+func singleFlight(next http.RoundTripper) http.RoundTripper {
+	return RoundTripperFunc(func(r *http.Request) (*http.Response, error) {
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			return next.RoundTrip(r)
+		}
+
+		key := singleFlightKey(r)
+		ctx, leave := joinSingleFlight(key, r.Context())
+		defer leave()
+
+		ch := singleFlightGroup.DoChan(key, func() (interface{}, error) {
+			resp, err := next.RoundTrip(r.WithContext(ctx))
+			if err != nil {
+				return nil, err
+			}
+			defer resp.Body.Close()
+
+			body, err := ioutil.ReadAll(resp.Body)
+			if err != nil {
+				return nil, err
+			}
+			return &singleFlightResult{resp: resp, body: body}, nil
+		})
+
+		var res singleflight.Result
+		select {
+		case res = <-ch:
+		case <-r.Context().Done():
+			return nil, r.Context().Err()
+		}
+		if res.Err != nil {
+			return nil, res.Err
+		}
+
+		result := res.Val.(*singleFlightResult)
+		resp := *result.resp
+		resp.Header = result.resp.Header.Clone()
+		resp.Body = ioutil.NopCloser(bytes.NewReader(result.body))
+		resp.Request = r
+		return &resp, nil
+	})
+}
+
+// singleFlightKey identifies the requests deduplicated by WithSingleFlight,
+// the query is hashed to keep keys of long queries short
+func singleFlightKey(r *http.Request) string {
+	u := *r.URL
+	query := sha256.Sum256([]byte(u.RawQuery))
+	u.RawQuery, u.Fragment = "", ""
+	return r.Method + " " + u.String() + "?" + hex.EncodeToString(query[:])
+}
@@ -0,0 +1,151 @@
+package gohttp
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// TestWithSingleFlight tests concurrent identical GET requests are sent once
+func TestWithSingleFlight(t *testing.T) {
+	t.Log("Sending concurrent requests with single flight... (expected one GET per URL, every POST)")
+
+	var hits int32
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		n := atomic.AddInt32(&hits, 1)
+		time.Sleep(200 * time.Millisecond)
+		fmt.Fprintf(w, "%s %d", r.URL.RawQuery, n)
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		method   string
+		queries  []string
+		expected int32
+	}{
+		{"same GET", http.MethodGet, []string{"a=1", "a=1", "a=1", "a=1", "a=1"}, 1},
+		{"different queries", http.MethodGet, []string{"a=1", "a=2", "a=1", "a=2"}, 2},
+		{"POST", http.MethodPost, []string{"a=1", "a=1", "a=1"}, 3},
+	}
+
+	for _, tt := range tests {
+		atomic.StoreInt32(&hits, 0)
+
+		var wg sync.WaitGroup
+		bodies := make([]string, len(tt.queries))
+		for i, query := range tt.queries {
+			wg.Add(1)
+			go func(i int, query string) {
+				defer wg.Done()
+				resp, err := NewRequest(WithSingleFlight()).Do(tt.method, ts.URL+"?"+query)
+				if err != nil {
+					t.Error(err)
+					return
+				}
+				bodies[i], _ = resp.GetBodyAsString()
+			}(i, query)
+		}
+		wg.Wait()
+
+		if hits != tt.expected {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected, "requests",
+				"got", hits, bodies,
+			)
+		}
+		if tt.method == http.MethodGet {
+			for i, body := range bodies {
+				if body[:3] != tt.queries[i] {
+					t.Error(
+						"For", tt.name, i,
+						"expected", tt.queries[i],
+						"got", body,
+					)
+				}
+			}
+		}
+	}
+}
+
+// TestWithSingleFlightCanceledCaller tests the caller which sent the shared
+// request can be canceled without failing the others, and that the shared
+// request is canceled once no caller waits for it
+func TestWithSingleFlightCanceledCaller(t *testing.T) {
+	t.Log("Canceling callers of a shared request... (expected other callers to get the response)")
+
+	var hits int32
+	canceled := make(chan struct{}, 1)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&hits, 1)
+		select {
+		case <-time.After(200 * time.Millisecond):
+			w.Write([]byte("shared"))
+		case <-r.Context().Done():
+			canceled <- struct{}{}
+		}
+	}))
+	defer ts.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	first := make(chan error, 1)
+	go func() {
+		_, err := NewRequest(WithSingleFlight()).SetContext(ctx).Get(ts.URL + "?shared")
+		first <- err
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	second := make(chan string, 1)
+	go func() {
+		resp, err := NewRequest(WithSingleFlight()).Get(ts.URL + "?shared")
+		if err != nil {
+			second <- err.Error()
+			return
+		}
+		body, _ := resp.GetBodyAsString()
+		second <- body
+	}()
+	time.Sleep(50 * time.Millisecond)
+	cancel()
+
+	if err := <-first; !errors.Is(err, context.Canceled) {
+		t.Error(
+			"For", "canceled caller",
+			"expected", context.Canceled,
+			"got", err,
+		)
+	}
+	if body := <-second; body != "shared" || atomic.LoadInt32(&hits) != 1 {
+		t.Error(
+			"For", "waiting caller",
+			"expected", "shared", 1,
+			"got", body, atomic.LoadInt32(&hits),
+		)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if _, err := NewRequest(WithSingleFlight()).SetContext(ctx).Get(ts.URL + "?alone"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Error(
+			"For", "only caller",
+			"expected", context.DeadlineExceeded,
+			"got", err,
+		)
+	}
+	select {
+	case <-canceled:
+	case <-time.After(time.Second):
+		t.Error(
+			"For", "only caller",
+			"expected", "shared request canceled",
+			"got", "none",
+		)
+	}
+}
@@ -0,0 +1,80 @@
+package gohttp
+
+import (
+	"io"
+	"net/http"
+	"sync/atomic"
+)
+
+// Stats are the bandwidth counters of a StatsRecorder. BytesSent and
+// BytesReceived count the bodies read by the transport and from the
+// responses, headers are not counted. RequestCount counts every attempt,
+// retries included.
+type Stats struct {
+	BytesSent     int64
+	BytesReceived int64
+	RequestCount  int64
+}
+
+// StatsRecorder aggregates the Stats of the requests using it, it is safe
+// for concurrent use. Its zero value is ready to use.
+type StatsRecorder struct {
+	bytesSent     int64
+	bytesReceived int64
+	requests      int64
+}
+
+// Stats returns the counters recorded so far
+func (s *StatsRecorder) Stats() Stats {
+	return Stats{
+		BytesSent:     atomic.LoadInt64(&s.bytesSent),
+		BytesReceived: atomic.LoadInt64(&s.bytesReceived),
+		RequestCount:  atomic.LoadInt64(&s.requests),
+	}
+}
+
+// WithStatsRecorder option records the stats of the request in rec, to
+// aggregate requests which are built separately. Copies made with Clone
+// share the recorder of the request already.
+func WithStatsRecorder(rec *StatsRecorder) OptionFunc {
+	return func(r *Request) {
+		r.stats = rec
+	}
+}
+
+// Stats returns the counters of the recorder of the request, aggregated
+// with its copies and the requests sharing it, see WithStatsRecorder
+func (req *Request) Stats() Stats {
+	return req.stats.Stats()
+}
+
+// countSent counts the bytes of the body of request, and of the copies
+// sent again for redirects
+func (req *Request) countSent(request *http.Request) {
+	if request.Body == nil || request.Body == http.NoBody {
+		// a wrapped empty body would be sent chunked
+		return
+	}
+	request.Body = countingBody{ReadCloser: request.Body, n: &req.stats.bytesSent}
+	if getBody := request.GetBody; getBody != nil {
+		request.GetBody = func() (io.ReadCloser, error) {
+			body, err := getBody()
+			if err != nil || body == http.NoBody {
+				return body, err
+			}
+			return countingBody{ReadCloser: body, n: &req.stats.bytesSent}, nil
+		}
+	}
+}
+
+// countingBody adds the number of bytes read from a body to n
+type countingBody struct {
+	io.ReadCloser
+	n *int64
+}
+
+func (b countingBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	atomic.AddInt64(b.n, int64(n))
+	return n, err
+}
@@ -0,0 +1,62 @@
+package gohttp
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestStats tests the counters are aggregated by copies of a request and by
+// requests sharing a recorder
+func TestStats(t *testing.T) {
+	t.Log("Sending requests with stats... (expected aggregated bytes and requests)")
+
+	ts := newEchoServer(t)
+
+	base := NewRequest()
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			resp, err := base.Clone().Text("hello").Post(ts.URL)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			resp.GetBodyAsByte()
+		}()
+	}
+	wg.Wait()
+	if _, err := base.Get(ts.URL); err != nil {
+		t.Fatal(err)
+	}
+
+	rec := &StatsRecorder{}
+	for _, body := range []string{"first", "second"} {
+		resp, err := NewRequest(WithStatsRecorder(rec)).Text(body).Put(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.GetBodyAsByte()
+	}
+
+	tests := []struct {
+		name     string
+		got      Stats
+		expected Stats
+	}{
+		{"clones", base.Stats(), Stats{BytesSent: 20, BytesReceived: 20, RequestCount: 5}},
+		{"recorder", rec.Stats(), Stats{BytesSent: 11, BytesReceived: 11, RequestCount: 2}},
+		{"unused", NewRequest().Stats(), Stats{}},
+	}
+
+	for _, tt := range tests {
+		if tt.got != tt.expected {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", tt.got,
+			)
+		}
+	}
+}
@@ -0,0 +1,230 @@
+package gohttp
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SSEEvent is an event of a text/event-stream response. Retry is the
+// reconnection delay last sent by the server, zero if it sent none.
+type SSEEvent struct {
+	ID    string
+	Event string
+	Data  string
+	Retry time.Duration
+}
+
+// Lines reads response body line by line in a goroutine, e.g. for NDJSON
+// or other line based streaming APIs. Every non-empty line is sent on the
+// first channel. A read error, or the context error when ctx is done, is
+// sent on the second channel. Both channels are closed once the body is
+// consumed, and the body is closed when ctx is done.
+func (res *Response) Lines(ctx context.Context) (<-chan string, <-chan error) {
+	lines := make(chan string)
+	errs := make(chan error, 1)
+
+	body := res.GetBody()
+	if body == nil {
+		close(lines)
+		close(errs)
+		return lines, errs
+	}
+
+	stop := closeOnDone(ctx, body)
+	go func() {
+		defer close(errs)
+		defer close(lines)
+		defer stop()
+
+		scanner := bufio.NewScanner(body)
+		for scanner.Scan() {
+			line := scanner.Text()
+			if line == "" {
+				continue
+			}
+
+			select {
+			case lines <- line:
+			case <-ctx.Done():
+				errs <- withCancelCause(ctx, ctx.Err())
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			if ctx.Err() != nil {
+				err = withCancelCause(ctx, ctx.Err())
+			}
+			errs <- err
+		}
+	}()
+
+	return lines, errs
+}
+
+// SSE reads response body as a stream of Server-Sent Events in a
+// goroutine. Every dispatched event is sent on the first channel, a read
+// error, or the context error when ctx is done, on the second. Both
+// channels are closed once the body is consumed, and the body is closed
+// when ctx is done. Lines which don't follow the event stream format are
+// ignored as the specification requires.
+func (res *Response) SSE(ctx context.Context) (<-chan SSEEvent, <-chan error) {
+	events := make(chan SSEEvent)
+	errs := make(chan error, 1)
+
+	body := res.GetBody()
+	if body == nil {
+		close(events)
+		close(errs)
+		return events, errs
+	}
+
+	stop := closeOnDone(ctx, body)
+	go func() {
+		defer close(errs)
+		defer close(events)
+		defer stop()
+
+		var p sseParser
+		scanner := bufio.NewScanner(body)
+		scanner.Split(scanSSELines)
+		for first := true; scanner.Scan(); first = false {
+			line := scanner.Text()
+			if first {
+				line = strings.TrimPrefix(line, "\ufeff")
+			}
+
+			event, ok := p.feed(line)
+			if !ok {
+				continue
+			}
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				errs <- withCancelCause(ctx, ctx.Err())
+				return
+			}
+		}
+
+		if err := scanner.Err(); err != nil {
+			if ctx.Err() != nil {
+				err = withCancelCause(ctx, ctx.Err())
+			}
+			errs <- err
+		}
+	}()
+
+	return events, errs
+}
+
+// sseParser assembles events from the lines of an event stream
+type sseParser struct {
+	lastID    string
+	retry     time.Duration
+	eventType string
+	data      strings.Builder
+}
+
+// feed processes a line and returns an event when it completes one
+func (p *sseParser) feed(line string) (SSEEvent, bool) {
+	if line == "" {
+		return p.dispatch()
+	}
+	if strings.HasPrefix(line, ":") {
+		return SSEEvent{}, false
+	}
+
+	field, value := line, ""
+	if i := strings.IndexByte(line, ':'); i >= 0 {
+		field, value = line[:i], strings.TrimPrefix(line[i+1:], " ")
+	}
+
+	switch field {
+	case "event":
+		p.eventType = value
+	case "data":
+		p.data.WriteString(value)
+		p.data.WriteByte('\n')
+	case "id":
+		if !strings.ContainsRune(value, 0) {
+			p.lastID = value
+		}
+	case "retry":
+		if ms, err := strconv.ParseUint(value, 10, 32); err == nil {
+			p.retry = time.Duration(ms) * time.Millisecond
+		}
+	}
+
+	return SSEEvent{}, false
+}
+
+// dispatch returns the buffered event, events without data are dropped
+func (p *sseParser) dispatch() (SSEEvent, bool) {
+	data := p.data.String()
+	eventType := p.eventType
+	p.data.Reset()
+	p.eventType = ""
+
+	if data == "" {
+		return SSEEvent{}, false
+	}
+	if eventType == "" {
+		eventType = "message"
+	}
+
+	return SSEEvent{
+		ID:    p.lastID,
+		Event: eventType,
+		Data:  strings.TrimSuffix(data, "\n"),
+		Retry: p.retry,
+	}, true
+}
+
+// scanSSELines is a bufio.SplitFunc for lines ended by CRLF, LF or CR
+func scanSSELines(data []byte, atEOF bool) (advance int, token []byte, err error) {
+	if atEOF && len(data) == 0 {
+		return 0, nil, nil
+	}
+
+	if i := bytes.IndexAny(data, "\r\n"); i >= 0 {
+		if data[i] == '\r' {
+			if i+1 == len(data) && !atEOF {
+				// need more data to tell CR from CRLF
+				return 0, nil, nil
+			}
+			if i+1 < len(data) && data[i+1] == '\n' {
+				return i + 2, data[:i], nil
+			}
+		}
+		return i + 1, data[:i], nil
+	}
+
+	if atEOF {
+		return len(data), data, nil
+	}
+	return 0, nil, nil
+}
+
+// closeOnDone closes body when ctx is done, so a blocked read returns. The
+// returned function closes body and stops watching ctx.
+func closeOnDone(ctx context.Context, body io.Closer) func() {
+	done := make(chan struct{})
+	go func() {
+		select {
+		case <-ctx.Done():
+			body.Close()
+		case <-done:
+		}
+	}()
+
+	return func() {
+		close(done)
+		body.Close()
+	}
+}
@@ -0,0 +1,137 @@
+package gohttp
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newLinesServer returns a server writing n lines with a delay between them
+func newLinesServer(t *testing.T, n int, delay time.Duration) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for i := 0; i < n; i++ {
+			fmt.Fprintf(w, "{\"n\":%d}\n", i)
+			if i%10 == 0 {
+				fmt.Fprintln(w)
+			}
+			w.(http.Flusher).Flush()
+
+			select {
+			case <-r.Context().Done():
+				return
+			case <-time.After(delay):
+			}
+		}
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// TestLines tests Lines reads every non-empty line
+func TestLines(t *testing.T) {
+	t.Log("Reading streamed lines... (expected 100 lines)")
+
+	ts := newLinesServer(t, 100, 10*time.Millisecond)
+
+	resp, err := NewRequest().Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	lines, errs := resp.Lines(context.Background())
+
+	n := 0
+	for line := range lines {
+		if line != fmt.Sprintf("{\"n\":%d}", n) {
+			t.Error(
+				"For", "Lines",
+				"expected", fmt.Sprintf("{\"n\":%d}", n),
+				"got", line,
+			)
+		}
+		n++
+	}
+
+	if err := <-errs; err != nil || n != 100 {
+		t.Error(
+			"For", "Lines",
+			"expected", 100,
+			"got", n, err,
+		)
+	}
+}
+
+// TestLinesCancel tests Lines stops when the context is cancelled
+func TestLinesCancel(t *testing.T) {
+	t.Log("Cancelling streamed lines... (expected context error)")
+
+	ts := newLinesServer(t, 100, 50*time.Millisecond)
+
+	resp, err := NewRequest().Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	lines, errs := resp.Lines(ctx)
+
+	<-lines
+	cancel()
+	for range lines {
+	}
+
+	if err := <-errs; err != context.Canceled {
+		t.Error(
+			"For", "Lines",
+			"expected", context.Canceled,
+			"got", err,
+		)
+	}
+}
+
+// TestSSE tests SSE parses events and ignores malformed lines
+func TestSSE(t *testing.T) {
+	t.Log("Reading server-sent events... (expected parsed events)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/event-stream")
+		fmt.Fprint(w, "\ufeff: comment\n\n")
+		fmt.Fprint(w, "data: first\n\n")
+		fmt.Fprint(w, "event: update\r\nid: 7\r\ndata: a\r\ndata:b\r\nretry: 1500\r\n\r\n")
+		fmt.Fprint(w, "retry: soon\nbogus\n:\ndata\nid: 8\x00\n\n")
+		fmt.Fprint(w, "event: empty\n\n")
+		fmt.Fprint(w, "data: last\rdata:  spaced\r\r")
+		fmt.Fprint(w, "data: unterminated")
+	}))
+	defer ts.Close()
+
+	resp, err := NewRequest().Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events, errs := resp.SSE(context.Background())
+
+	var got []SSEEvent
+	for event := range events {
+		got = append(got, event)
+	}
+
+	retry := 1500 * time.Millisecond
+	expected := []SSEEvent{
+		{Event: "message", Data: "first"},
+		{ID: "7", Event: "update", Data: "a\nb", Retry: retry},
+		{ID: "7", Event: "message", Data: "", Retry: retry},
+		{ID: "7", Event: "message", Data: "last\n spaced", Retry: retry},
+	}
+	if err := <-errs; err != nil || fmt.Sprint(got) != fmt.Sprint(expected) {
+		t.Error(
+			"For", "SSE",
+			"expected", expected,
+			"got", got, err,
+		)
+	}
+}
@@ -0,0 +1,117 @@
+package gohttp
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+)
+
+var (
+	// ErrNoOCSPStaple is returned when the server stapled no OCSP response
+	ErrNoOCSPStaple = errors.New("gohttp: server stapled no OCSP response")
+	// ErrWeakKey is returned when a certificate has a too small RSA key
+	ErrWeakKey = errors.New("gohttp: certificate key is too weak")
+	// ErrWeakSignature is returned when a certificate is signed with SHA-1
+	ErrWeakSignature = errors.New("gohttp: certificate signature is too weak")
+)
+
+// StrictTLSPolicy sets the checks WithStrictTLS runs on every TLS
+// connection, in addition to the usual certificate verification. Every
+// check is opt-in, the zero policy checks nothing.
+type StrictTLSPolicy struct {
+	// RequireOCSPStaple rejects servers which staple no OCSP response. The
+	// response itself isn't checked.
+	RequireOCSPStaple bool
+	// MinRSABits rejects RSA keys smaller than it anywhere in the chain the
+	// server sent, e.g. 2048, keys aren't checked when zero
+	MinRSABits int
+	// RejectSHA1 rejects SHA-1 signatures anywhere in the chain the server
+	// sent
+	RejectSHA1 bool
+	// ReportOnly accepts the connection and passes every violation to
+	// Report instead, e.g. to roll the policy out
+	ReportOnly bool
+	Report     func(err error)
+}
+
+// TLSPolicyError describes a violation of a StrictTLSPolicy, Rule is one of
+// ErrNoOCSPStaple, ErrWeakKey or ErrWeakSignature
+type TLSPolicyError struct {
+	Rule       error
+	ServerName string
+	Subject    string
+	Detail     string
+}
+
+func (e *TLSPolicyError) Error() string {
+	if e.Subject == "" {
+		return fmt.Sprintf("%v: %s", e.Rule, e.ServerName)
+	}
+	return fmt.Sprintf("%v: %s: %s (%s)", e.Rule, e.ServerName, e.Subject, e.Detail)
+}
+
+// Is reports whether target is the violated rule
+func (e *TLSPolicyError) Is(target error) bool {
+	return target == e.Rule
+}
+
+// check returns the violations of the policy by the connection
+func (p *StrictTLSPolicy) check(cs tls.ConnectionState) []error {
+	var errs []error
+	if p.RequireOCSPStaple && len(cs.OCSPResponse) == 0 {
+		errs = append(errs, &TLSPolicyError{Rule: ErrNoOCSPStaple, ServerName: cs.ServerName})
+	}
+
+	for _, cert := range cs.PeerCertificates {
+		if key, ok := cert.PublicKey.(*rsa.PublicKey); ok && key.N.BitLen() < p.MinRSABits {
+			errs = append(errs, &TLSPolicyError{
+				Rule:       ErrWeakKey,
+				ServerName: cs.ServerName,
+				Subject:    cert.Subject.String(),
+				Detail:     fmt.Sprintf("%d bit RSA key", key.N.BitLen()),
+			})
+		}
+
+		switch cert.SignatureAlgorithm {
+		case x509.SHA1WithRSA, x509.DSAWithSHA1, x509.ECDSAWithSHA1:
+			if p.RejectSHA1 {
+				errs = append(errs, &TLSPolicyError{
+					Rule:       ErrWeakSignature,
+					ServerName: cs.ServerName,
+					Subject:    cert.Subject.String(),
+					Detail:     cert.SignatureAlgorithm.String(),
+				})
+			}
+		}
+	}
+
+	return errs
+}
+
+// verifier returns a tls.Config.VerifyConnection enforcing the policy after
+// next, the VerifyConnection of the transport, if any
+func (p *StrictTLSPolicy) verifier(next func(tls.ConnectionState) error) func(tls.ConnectionState) error {
+	return func(cs tls.ConnectionState) error {
+		if next != nil {
+			if err := next(cs); err != nil {
+				return err
+			}
+		}
+
+		errs := p.check(cs)
+		if len(errs) == 0 {
+			return nil
+		}
+		if !p.ReportOnly {
+			return errs[0]
+		}
+		if p.Report != nil {
+			for _, err := range errs {
+				p.Report(err)
+			}
+		}
+		return nil
+	}
+}
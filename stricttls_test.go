@@ -0,0 +1,166 @@
+package gohttp
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"errors"
+	"io/ioutil"
+	"log"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+// newTestChain returns a leaf certificate with an RSA key of leafBits
+// signed by a 2048 bit CA with sigAlg, along with the CA certificate
+func newTestChain(t *testing.T, leafBits int, sigAlg x509.SignatureAlgorithm) tls.Certificate {
+	caKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ca := &x509.Certificate{
+		SerialNumber:          big.NewInt(1),
+		Subject:               pkix.Name{CommonName: "test ca"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	caDER, err := x509.CreateCertificate(rand.Reader, ca, ca, &caKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	leafKey, err := rsa.GenerateKey(rand.Reader, leafBits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	leaf := &x509.Certificate{
+		SerialNumber:       big.NewInt(2),
+		Subject:            pkix.Name{CommonName: "test leaf"},
+		NotBefore:          time.Now().Add(-time.Hour),
+		NotAfter:           time.Now().Add(time.Hour),
+		DNSNames:           []string{"example.com"},
+		SignatureAlgorithm: sigAlg,
+		KeyUsage:           x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment,
+		ExtKeyUsage:        []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	leafDER, err := x509.CreateCertificate(rand.Reader, leaf, ca, &leafKey.PublicKey, caKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return tls.Certificate{Certificate: [][]byte{leafDER, caDER}, PrivateKey: leafKey}
+}
+
+// newStrictTLSServer returns a TLS server presenting cert
+func newStrictTLSServer(t *testing.T, cert tls.Certificate) *httptest.Server {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	ts.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	ts.StartTLS()
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// TestWithStrictTLS tests every rule against strong and weak chains
+func TestWithStrictTLS(t *testing.T) {
+	t.Log("Connecting to TLS servers with strict policy... (expected typed errors for weak chains)")
+
+	strong := newTestChain(t, 2048, x509.SHA256WithRSA)
+	stapled := strong
+	stapled.OCSPStaple = []byte("staple")
+	weakKey := newTestChain(t, 1024, x509.SHA256WithRSA)
+	weakKey.OCSPStaple = stapled.OCSPStaple
+
+	policy := StrictTLSPolicy{RequireOCSPStaple: true, MinRSABits: 2048, RejectSHA1: true}
+
+	tests := []struct {
+		name     string
+		cert     tls.Certificate
+		policy   StrictTLSPolicy
+		expected error
+	}{
+		{"strong", stapled, policy, nil},
+		{"no staple", strong, policy, ErrNoOCSPStaple},
+		{"no staple allowed", strong, StrictTLSPolicy{}, nil},
+		{"weak key", weakKey, policy, ErrWeakKey},
+		{"weak key allowed", weakKey, StrictTLSPolicy{MinRSABits: 1024}, nil},
+		{"zero policy", weakKey, StrictTLSPolicy{}, nil},
+	}
+
+	for _, tt := range tests {
+		ts := newStrictTLSServer(t, tt.cert)
+
+		// the certificates aren't trusted, only the policy is tested
+		tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+		_, err := NewRequest(SetTransport(tr), WithStrictTLS(tt.policy)).Get(ts.URL)
+
+		var policyErr *TLSPolicyError
+		if tt.expected == nil && err != nil || tt.expected != nil && (!errors.Is(err, tt.expected) || !errors.As(err, &policyErr)) {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", err,
+			)
+		}
+	}
+}
+
+// TestWithStrictTLSWeakSignature tests a SHA-1 signed chain is rejected
+func TestWithStrictTLSWeakSignature(t *testing.T) {
+	t.Log("Connecting to TLS server with SHA-1 chain... (expected ErrWeakSignature)")
+
+	cert := newTestChain(t, 2048, x509.SHA1WithRSA)
+	ts := newStrictTLSServer(t, cert)
+
+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	_, err := NewRequest(SetTransport(tr), WithStrictTLS(StrictTLSPolicy{RejectSHA1: true})).Get(ts.URL)
+	if !errors.Is(err, ErrWeakSignature) {
+		t.Error(
+			"For", "SHA-1 chain",
+			"expected", ErrWeakSignature,
+			"got", err,
+		)
+	}
+}
+
+// TestWithStrictTLSReportOnly tests violations are reported without failing
+func TestWithStrictTLSReportOnly(t *testing.T) {
+	t.Log("Connecting to weak TLS server in report only mode... (expected reported violations)")
+
+	ts := newStrictTLSServer(t, newTestChain(t, 1024, x509.SHA256WithRSA))
+
+	var reported []error
+	policy := StrictTLSPolicy{
+		RequireOCSPStaple: true,
+		MinRSABits:        2048,
+		ReportOnly:        true,
+		Report: func(err error) {
+			reported = append(reported, err)
+		},
+	}
+
+	tr := &http.Transport{TLSClientConfig: &tls.Config{InsecureSkipVerify: true}}
+	resp, err := NewRequest(SetTransport(tr), WithStrictTLS(policy)).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(reported) != 2 || !errors.Is(reported[0], ErrNoOCSPStaple) || !errors.Is(reported[1], ErrWeakKey) ||
+		resp.GetStatusCode() != 200 || tr.TLSClientConfig.VerifyConnection != nil {
+		t.Error(
+			"For", "ReportOnly",
+			"expected", "2 violations and unchanged transport",
+			"got", reported,
+		)
+	}
+}
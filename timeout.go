@@ -0,0 +1,171 @@
+package gohttp
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"os"
+	"sync/atomic"
+	"time"
+)
+
+var (
+	// ErrDialTimeout is matched by timeouts which happened before a
+	// connection was established, i.e. while dialing or during the TLS
+	// handshake
+	ErrDialTimeout = errors.New("gohttp: dial timeout")
+
+	// ErrRequestTimeout is matched by timeouts which happened once a
+	// connection was established, while sending the request or waiting for
+	// the response
+	ErrRequestTimeout = errors.New("gohttp: request timeout")
+)
+
+// TimeoutError is returned when a request, or the read of its response
+// body, times out. Kind is either ErrDialTimeout or ErrRequestTimeout, Err
+// is the original error. It matches context.DeadlineExceeded whichever
+// timeout expired.
+type TimeoutError struct {
+	Kind error
+	Err  error
+}
+
+func (e *TimeoutError) Error() string {
+	return e.Kind.Error() + ": " + e.Err.Error()
+}
+
+// Is reports whether target is the kind of timeout or
+// context.DeadlineExceeded
+func (e *TimeoutError) Is(target error) bool {
+	return target == e.Kind || target == context.DeadlineExceeded
+}
+
+func (e *TimeoutError) Unwrap() error {
+	return e.Err
+}
+
+// connTrace records whether a request got a connection and updates the
+// pool counters
+type connTrace struct {
+	gotConn int32
+	pool    *poolCounters
+}
+
+// attach returns request traced by t
+func (t *connTrace) attach(request *http.Request) *http.Request {
+	trace := &httptrace.ClientTrace{
+		GotConn: func(info httptrace.GotConnInfo) {
+			atomic.StoreInt32(&t.gotConn, 1)
+			if info.Reused {
+				atomic.AddInt64(&t.pool.reused, 1)
+			} else {
+				atomic.AddInt64(&t.pool.dials, 1)
+			}
+		},
+		TLSHandshakeDone: func(_ tls.ConnectionState, err error) {
+			if err == nil {
+				atomic.AddInt64(&t.pool.tlsHandshakes, 1)
+			}
+		},
+	}
+	return request.WithContext(httptrace.WithClientTrace(request.Context(), trace))
+}
+
+// classify wraps timeout errors in a *TimeoutError of the right kind
+func (t *connTrace) classify(err error) error {
+	if !isTimeout(err) {
+		return err
+	}
+
+	kind := ErrDialTimeout
+	if atomic.LoadInt32(&t.gotConn) == 1 {
+		kind = ErrRequestTimeout
+	}
+	return &TimeoutError{Kind: kind, Err: err}
+}
+
+// isTimeout reports whether err is caused by a timeout
+func isTimeout(err error) bool {
+	if errors.Is(err, context.DeadlineExceeded) {
+		return true
+	}
+
+	var netErr net.Error
+	return errors.As(err, &netErr) && netErr.Timeout()
+}
+
+// classifiedBody returns the read errors of a response body like the ones
+// of the request, timeouts as a *TimeoutError and cancellations with a
+// cause as a *CanceledError, so they match the context errors whichever
+// layer failed
+type classifiedBody struct {
+	io.ReadCloser
+	ctx context.Context
+}
+
+func (b *classifiedBody) Read(p []byte) (int, error) {
+	n, err := b.ReadCloser.Read(p)
+	if err == nil || err == io.EOF {
+		return n, err
+	}
+	var timeout *TimeoutError
+	if isTimeout(err) && !errors.As(err, &timeout) {
+		err = &TimeoutError{Kind: ErrRequestTimeout, Err: err}
+	}
+	return n, withCancelCause(b.ctx, err)
+}
+
+// cancelBody releases the context of a request with a Timeout once the
+// response body is closed
+type cancelBody struct {
+	io.ReadCloser
+	cancel context.CancelFunc
+}
+
+func (b *cancelBody) Close() error {
+	err := b.ReadCloser.Close()
+	b.cancel()
+	return err
+}
+
+// readTimeoutBody fails a read of a response body which takes longer than
+// timeout, the body is closed to stop it
+type readTimeoutBody struct {
+	body    io.ReadCloser
+	timeout time.Duration
+	timer   *time.Timer
+	expired int32
+}
+
+func (b *readTimeoutBody) Read(p []byte) (int, error) {
+	if atomic.LoadInt32(&b.expired) == 1 {
+		return 0, &TimeoutError{Kind: ErrRequestTimeout, Err: os.ErrDeadlineExceeded}
+	}
+	if b.timer == nil {
+		b.timer = time.AfterFunc(b.timeout, b.expire)
+	} else {
+		b.timer.Reset(b.timeout)
+	}
+
+	n, err := b.body.Read(p)
+	if !b.timer.Stop() && atomic.LoadInt32(&b.expired) == 1 {
+		return n, &TimeoutError{Kind: ErrRequestTimeout, Err: os.ErrDeadlineExceeded}
+	}
+	return n, err
+}
+
+func (b *readTimeoutBody) expire() {
+	atomic.StoreInt32(&b.expired, 1)
+	b.body.Close()
+}
+
+func (b *readTimeoutBody) Close() error {
+	if b.timer != nil {
+		b.timer.Stop()
+	}
+	return b.body.Close()
+}
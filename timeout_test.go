@@ -0,0 +1,237 @@
+package gohttp
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestDialTimeout tests a timeout while connecting
+func TestDialTimeout(t *testing.T) {
+	t.Log("Sending GET request with a hanging dial... (expected ErrDialTimeout)")
+
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	_, err := NewRequest(SetTransport(tr), SetTimeout(50*time.Millisecond)).Get("http://example.com/")
+	if !errors.Is(err, ErrDialTimeout) || errors.Is(err, ErrRequestTimeout) {
+		t.Error(
+			"For", "hanging dial",
+			"expected", ErrDialTimeout,
+			"got", err,
+		)
+	}
+}
+
+// TestRequestTimeout tests a timeout while waiting for the response
+func TestRequestTimeout(t *testing.T) {
+	t.Log("Sending GET request to a slow server... (expected ErrRequestTimeout)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	_, err := NewRequest(SetTimeout(50 * time.Millisecond)).Get(ts.URL)
+	if !errors.Is(err, ErrRequestTimeout) || errors.Is(err, ErrDialTimeout) {
+		t.Error(
+			"For", "slow server",
+			"expected", ErrRequestTimeout,
+			"got", err,
+		)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	_, err = NewRequest().SetContext(ctx).Get(ts.URL)
+	if !errors.Is(err, ErrRequestTimeout) || !errors.Is(err, context.DeadlineExceeded) {
+		t.Error(
+			"For", "slow server with context deadline",
+			"expected", ErrRequestTimeout,
+			"got", err,
+		)
+	}
+}
+
+// TestRequestTimeoutMethod tests the timeout of a send, which can be
+// shorter than the one of the client
+func TestRequestTimeoutMethod(t *testing.T) {
+	t.Log("Sending GET requests with Timeout... (expected shorter timeout to win, body readable in time)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("slow") != "" {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+		w.Write([]byte("done"))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name string
+		req  *Request
+	}{
+		{"shorter than client", NewRequest(SetTimeout(5 * time.Second)).Timeout(50 * time.Millisecond)},
+		{"longer than client", NewRequest(SetTimeout(50 * time.Millisecond)).Timeout(5 * time.Second)},
+	}
+
+	for _, tt := range tests {
+		start := time.Now()
+		_, err := tt.req.Get(ts.URL + "?slow=1")
+		if !errors.Is(err, ErrRequestTimeout) || time.Since(start) > 500*time.Millisecond {
+			t.Error(
+				"For", tt.name,
+				"expected", ErrRequestTimeout,
+				"got", err, time.Since(start),
+			)
+		}
+	}
+
+	resp, err := NewRequest().Timeout(time.Second).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	time.Sleep(10 * time.Millisecond)
+	if body, err := resp.GetBodyAsString(); body != "done" || err != nil {
+		t.Error(
+			"For", "fast server",
+			"expected", "done",
+			"got", body, err,
+		)
+	}
+}
+
+// TestWithRequestTimeout tests the timeout of calls of a client, composed
+// with their context
+func TestWithRequestTimeout(t *testing.T) {
+	t.Log("Sending GET requests with WithRequestTimeout... (expected timeout or parent cancellation, whichever comes first)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	client := NewClient(SetTimeout(5 * time.Second))
+
+	canceled, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	tests := []struct {
+		name     string
+		ctx      context.Context
+		timeout  time.Duration
+		expected error
+	}{
+		{"timeout first", context.Background(), 50 * time.Millisecond, ErrRequestTimeout},
+		{"parent canceled first", canceled, 5 * time.Second, context.Canceled},
+	}
+
+	for _, tt := range tests {
+		start := time.Now()
+		_, err := client.R(WithRequestTimeout(tt.timeout)).SetContext(tt.ctx).Get(ts.URL)
+		if !errors.Is(err, tt.expected) || time.Since(start) > 500*time.Millisecond {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", err, time.Since(start),
+			)
+		}
+	}
+}
+
+// TestWithConnectTimeout tests a hanging dial fails after the connect
+// timeout while the client timeout is longer
+func TestWithConnectTimeout(t *testing.T) {
+	t.Log("Sending GET request with a hanging dial and a connect timeout... (expected ErrDialTimeout)")
+
+	tr := &http.Transport{
+		DialContext: func(ctx context.Context, network, addr string) (net.Conn, error) {
+			<-ctx.Done()
+			return nil, ctx.Err()
+		},
+	}
+
+	start := time.Now()
+	_, err := NewRequest(SetTransport(tr), SetTimeout(5*time.Second), WithConnectTimeout(50*time.Millisecond)).Get("http://example.com/")
+	if !errors.Is(err, ErrDialTimeout) || time.Since(start) > time.Second {
+		t.Error(
+			"For", "hanging dial",
+			"expected", ErrDialTimeout,
+			"got", err, time.Since(start),
+		)
+	}
+}
+
+// TestWithReadTimeout tests slow headers and stalled bodies time out while
+// a slow but steady body doesn't
+func TestWithReadTimeout(t *testing.T) {
+	t.Log("Reading responses with a read timeout... (expected timeouts for stalls only)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		stall := func() {
+			select {
+			case <-r.Context().Done():
+			case <-time.After(time.Second):
+			}
+		}
+		switch r.URL.Path {
+		case "/headers":
+			stall()
+		case "/body":
+			w.Write([]byte("start"))
+			w.(http.Flusher).Flush()
+			stall()
+		case "/steady":
+			for i := 0; i < 5; i++ {
+				w.Write([]byte("chunk"))
+				w.(http.Flusher).Flush()
+				time.Sleep(30 * time.Millisecond)
+			}
+		}
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		path     string
+		expected error
+		body     string
+	}{
+		{"/headers", ErrRequestTimeout, ""},
+		{"/body", ErrRequestTimeout, ""},
+		{"/steady", nil, strings.Repeat("chunk", 5)},
+	}
+
+	for _, tt := range tests {
+		start := time.Now()
+		var body string
+		resp, err := NewRequest(WithReadTimeout(100 * time.Millisecond)).Get(ts.URL + tt.path)
+		if err == nil {
+			body, err = resp.GetBodyAsString()
+		}
+		if !errors.Is(err, tt.expected) || body != tt.body || time.Since(start) > 500*time.Millisecond {
+			t.Error(
+				"For", tt.path,
+				"expected", tt.expected, tt.body,
+				"got", err, body, time.Since(start),
+			)
+		}
+	}
+}
@@ -0,0 +1,293 @@
+package gohttp
+
+import (
+	"crypto/rsa"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// writePEM writes a PEM block of typ with der to a file in dir
+func writePEM(t *testing.T, dir, name, typ string, der []byte) string {
+	path := filepath.Join(dir, name)
+	if err := ioutil.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: typ, Bytes: der}), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+// TestTLSOptions tests custom root CAs and InsecureSkipVerify
+func TestTLSOptions(t *testing.T) {
+	t.Log("Sending GET requests to TLS server... (expected success only with trusted CA or insecure)")
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	caFile := writePEM(t, t.TempDir(), "ca.pem", "CERTIFICATE", ts.Certificate().Raw)
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		fails bool
+	}{
+		{"system roots", nil, true},
+		{"WithRootCAs", []Option{WithRootCAs(pool)}, false},
+		{"WithRootCAFile", []Option{WithRootCAFile(caFile)}, false},
+		{"missing WithRootCAFile", []Option{WithRootCAFile(filepath.Join(t.TempDir(), "missing.pem"))}, true},
+		{"WithTLSConfig", []Option{WithTLSConfig(&tls.Config{RootCAs: pool})}, false},
+		{"WithInsecureSkipVerify", []Option{WithInsecureSkipVerify()}, false},
+	}
+
+	for _, tt := range tests {
+		_, err := NewRequest(tt.opts...).Get(ts.URL)
+		if (err != nil) != tt.fails {
+			t.Error(
+				"For", tt.name,
+				"expected", "fails:", tt.fails,
+				"got", err,
+			)
+		}
+	}
+}
+
+// TestWithClientCert tests a client certificate is presented to the server
+func TestWithClientCert(t *testing.T) {
+	t.Log("Sending GET request to mutual TLS server... (expected client certificate)")
+
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.TLS.PeerCertificates[0].Subject.CommonName))
+	}))
+	ts.TLS = &tls.Config{ClientAuth: tls.RequireAnyClientCert}
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	cert := newTestChain(t, 2048, x509.SHA256WithRSA)
+	dir := t.TempDir()
+	certFile := writePEM(t, dir, "cert.pem", "CERTIFICATE", cert.Certificate[0])
+	keyFile := writePEM(t, dir, "key.pem", "RSA PRIVATE KEY", x509.MarshalPKCS1PrivateKey(cert.PrivateKey.(*rsa.PrivateKey)))
+
+	resp, err := NewRequest(WithInsecureSkipVerify(), WithClientCert(certFile, keyFile)).Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if body, _ := resp.GetBodyAsString(); body != "test leaf" {
+		t.Error(
+			"For", "WithClientCert",
+			"expected", "test leaf",
+			"got", body,
+		)
+	}
+
+	if _, err = NewRequest(WithClientCert(keyFile, certFile)).Get(ts.URL); err == nil {
+		t.Error(
+			"For", "WithClientCert with swapped files",
+			"expected", "error",
+			"got", err,
+		)
+	}
+}
+
+// TestWithTLSConfigDefaultTransport tests WithTLSConfig configures a copy of
+// the default transport
+func TestWithTLSConfigDefaultTransport(t *testing.T) {
+	t.Log("Building transport with WithTLSConfig... (expected configured copy of default transport)")
+
+	def := http.DefaultTransport.(*http.Transport)
+	defCfg := def.TLSClientConfig
+
+	cfg := &tls.Config{ServerName: "api.internal"}
+	req := NewRequest(WithTLSConfig(cfg))
+	tr, ok := req.createClient().Transport.(*http.Transport)
+	if !ok {
+		t.Fatal("expected *http.Transport")
+	}
+	cfg.ServerName = "changed"
+
+	if tr == def || def.TLSClientConfig != defCfg {
+		t.Error(
+			"For", "http.DefaultTransport",
+			"expected", "unchanged",
+			"got", "modified",
+		)
+	}
+	if tr.TLSClientConfig == cfg || tr.TLSClientConfig.ServerName != "api.internal" {
+		t.Error(
+			"For", "TLSClientConfig",
+			"expected", "copy with ServerName api.internal",
+			"got", tr.TLSClientConfig.ServerName,
+		)
+	}
+	if tr.TLSHandshakeTimeout != def.TLSHandshakeTimeout || tr.IdleConnTimeout != def.IdleConnTimeout ||
+		tr.MaxIdleConns != def.MaxIdleConns || tr.Proxy == nil || tr.DialContext == nil {
+		t.Error(
+			"For", "transport settings",
+			"expected", "taken from http.DefaultTransport",
+			"got", tr.TLSHandshakeTimeout, tr.IdleConnTimeout, tr.MaxIdleConns,
+		)
+	}
+}
+
+// recordingLogger records the messages logged to it
+type recordingLogger struct {
+	msgs []string
+}
+
+func (l *recordingLogger) Printf(format string, v ...interface{}) {
+	l.msgs = append(l.msgs, fmt.Sprintf(format, v...))
+}
+
+// TestWithSkipTLSVerify tests WithSkipTLSVerify and its order with
+// WithTLSConfig
+func TestWithSkipTLSVerify(t *testing.T) {
+	t.Log("Sending GET requests to TLS server... (expected success only when skipping verification last)")
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	defer ts.Close()
+
+	def := http.DefaultTransport.(*http.Transport)
+	defCfg := def.TLSClientConfig
+
+	tests := []struct {
+		name  string
+		opts  []Option
+		fails bool
+		warns bool
+	}{
+		{"skip", []Option{WithSkipTLSVerify(true)}, false, true},
+		{"don't skip", []Option{WithSkipTLSVerify(false)}, true, false},
+		{"skip after WithTLSConfig", []Option{WithTLSConfig(&tls.Config{}), WithSkipTLSVerify(true)}, false, true},
+		{"WithTLSConfig after skip", []Option{WithSkipTLSVerify(true), WithTLSConfig(&tls.Config{})}, true, false},
+		{"don't skip after WithTLSConfig", []Option{WithTLSConfig(&tls.Config{InsecureSkipVerify: true}), WithSkipTLSVerify(false)}, true, false},
+	}
+
+	for _, tt := range tests {
+		logger := &recordingLogger{}
+		_, err := NewRequest(append(tt.opts, WithLogger(logger))...).Get(ts.URL)
+		if (err != nil) != tt.fails || (len(logger.msgs) == 1) != tt.warns {
+			t.Error(
+				"For", tt.name,
+				"expected", "fails:", tt.fails, "warns:", tt.warns,
+				"got", err, logger.msgs,
+			)
+		}
+	}
+
+	if def.TLSClientConfig != defCfg {
+		t.Error(
+			"For", "http.DefaultTransport",
+			"expected", "unchanged",
+			"got", "modified",
+		)
+	}
+}
+
+// TestWithCertPins tests only servers with a pinned certificate are accepted
+func TestWithCertPins(t *testing.T) {
+	t.Log("Sending GET requests to TLS server with pins... (expected success only with its pin)")
+
+	ts := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	defer ts.Close()
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ts.Certificate())
+	pin := CertPin(ts.Certificate())
+	otherPin := "47DEQpj8HBSa+/TImW+5JCeuQeRkm5NMpJWZG3hSuFU="
+
+	tests := []struct {
+		name string
+		pins []string
+		err  error
+	}{
+		{"pinned", []string{otherPin, pin}, nil},
+		{"not pinned", []string{otherPin}, ErrCertNotPinned},
+	}
+
+	for _, tt := range tests {
+		_, err := NewRequest(WithRootCAs(pool), WithCertPins(tt.pins)).Get(ts.URL)
+		if !errors.Is(err, tt.err) {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.err,
+				"got", err,
+			)
+		}
+
+		var pinErr *CertNotPinnedError
+		if errors.As(err, &pinErr) && pinErr.GotFingerprint != pin {
+			t.Error(
+				"For", tt.name,
+				"expected", "fingerprint", pin,
+				"got", pinErr.GotFingerprint,
+			)
+		}
+	}
+}
+
+// TestWithCertPinsAppendedCert tests a pinned certificate sent along with
+// a chain it is not part of is rejected
+func TestWithCertPinsAppendedCert(t *testing.T) {
+	t.Log("Sending GET requests to TLS server appending a pinned certificate... (expected only the verified chain to match)")
+
+	chain := newTestChain(t, 2048, x509.SHA256WithRSA)
+	ca, err := x509.ParseCertificate(chain.Certificate[1])
+	if err != nil {
+		t.Fatal(err)
+	}
+	other := newTestChain(t, 2048, x509.SHA256WithRSA)
+	appended := chain
+	appended.Certificate = append(append([][]byte(nil), chain.Certificate...), other.Certificate[1])
+	ts := newStrictTLSServer(t, appended)
+
+	pool := x509.NewCertPool()
+	pool.AddCert(ca)
+	otherPin := certPin(other.Certificate[1])
+
+	tests := []struct {
+		name string
+		opts []Option
+		err  error
+	}{
+		{"appended pin", []Option{WithRootCAs(pool), WithCertPins([]string{otherPin})}, ErrCertNotPinned},
+		{"chain pin", []Option{WithRootCAs(pool), WithCertPins([]string{otherPin, CertPin(ca)})}, nil},
+		{"appended pin unverified", []Option{WithInsecureSkipVerify(), WithCertPins([]string{otherPin})}, ErrCertNotPinned},
+		{"leaf pin unverified", []Option{WithInsecureSkipVerify(), WithCertPins([]string{certPin(chain.Certificate[0])})}, nil},
+	}
+
+	for _, tt := range tests {
+		// the leaf is issued for example.com
+		opts := append(tt.opts, OptionFunc(func(r *Request) {
+			r.tlsOptions = append(r.tlsOptions, func(cfg *tls.Config) {
+				cfg.ServerName = "example.com"
+			})
+		}))
+		_, err := NewRequest(opts...).Get(ts.URL)
+		if !errors.Is(err, tt.err) {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.err,
+				"got", err,
+			)
+		}
+	}
+}
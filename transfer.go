@@ -0,0 +1,319 @@
+package gohttp
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrChecksumMismatch is returned by Download and UploadFile when the file
+// doesn't match the checksum given with TransferChecksum
+var ErrChecksumMismatch = errors.New("gohttp: checksum mismatch")
+
+// defaultResponseHeaderTimeout is how long Download and UploadFile wait for
+// the response headers, the body itself may take as long as needed
+const defaultResponseHeaderTimeout = 30 * time.Second
+
+// validatorSuffix is appended to the path of a download to name the file
+// keeping the validator of the resource, its ETag or Last-Modified, until
+// the download is complete
+const validatorSuffix = ".validator"
+
+// transferClient is the client of Download and UploadFile
+var transferClient = newTransferClient()
+
+func newTransferClient() *Client {
+	tr := http.DefaultTransport.(*http.Transport).Clone()
+	tr.ResponseHeaderTimeout = defaultResponseHeaderTimeout
+	return NewClient(SetTransport(tr))
+}
+
+// TransferReport describes a file transfer of Download or UploadFile
+type TransferReport struct {
+	// Bytes is the number of bytes transferred, without the part of a
+	// resumed download which was already saved
+	Bytes    int64
+	Duration time.Duration
+	// AverageRate is in bytes per second
+	AverageRate float64
+	Resumed     bool
+}
+
+// TransferOption configures Download and UploadFile
+type TransferOption func(*transferConfig)
+
+type transferConfig struct {
+	requestOpts []Option
+	progress    func(written, total int64)
+	newHash     func() hash.Hash
+	checksum    string
+	noResume    bool
+}
+
+// TransferProgress option calls fn while the file is transferred, with the
+// bytes of the file transferred so far and the total, -1 when unknown. For
+// a resumed download both include the part which was already saved, for an
+// upload they count the whole request body.
+func TransferProgress(fn func(written, total int64)) TransferOption {
+	return func(c *transferConfig) {
+		c.progress = fn
+	}
+}
+
+// TransferChecksum option checks the file against sum, the hex encoded
+// SHA-256 digest of its content, a mismatch fails with
+// ErrChecksumMismatch. A downloaded file which doesn't match is removed.
+func TransferChecksum(sum string) TransferOption {
+	return TransferChecksumFunc(sha256.New, sum)
+}
+
+// TransferChecksumFunc option is like TransferChecksum with the digest of
+// the hash returned by newHash
+func TransferChecksumFunc(newHash func() hash.Hash, sum string) TransferOption {
+	return func(c *transferConfig) {
+		c.newHash = newHash
+		c.checksum = strings.ToLower(sum)
+	}
+}
+
+// TransferNoResume option downloads the whole file even when part of it
+// was saved already
+func TransferNoResume() TransferOption {
+	return func(c *transferConfig) {
+		c.noResume = true
+	}
+}
+
+// TransferRequest option configures the request with opts, e.g. WithHeaders
+// for authentication. Options building the http client are ignored.
+func TransferRequest(opts ...Option) TransferOption {
+	return func(c *transferConfig) {
+		c.requestOpts = append(c.requestOpts, opts...)
+	}
+}
+
+func newTransferConfig(opts []TransferOption) *transferConfig {
+	c := &transferConfig{}
+	for _, opt := range opts {
+		opt(c)
+	}
+	return c
+}
+
+// verify compares the digest of h with the expected checksum
+func (c *transferConfig) verify(h hash.Hash) error {
+	if sum := hex.EncodeToString(h.Sum(nil)); sum != c.checksum {
+		return fmt.Errorf("%w: got %s, expected %s", ErrChecksumMismatch, sum, c.checksum)
+	}
+	return nil
+}
+
+// verifyFile compares the digest of the file at path with the expected
+// checksum
+func (c *transferConfig) verifyFile(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	h := c.newHash()
+	if _, err := io.Copy(h, f); err != nil {
+		return err
+	}
+	return c.verify(h)
+}
+
+// Download saves the body of a GET request to url in the file at path.
+// Until the download is complete the ETag or Last-Modified of the resource
+// is kept next to it, in path with the suffix ".validator". A download
+// interrupted before is resumed, only the missing bytes are requested with
+// the validator in If-Range, so a resource changed since is sent whole
+// again, which replaces the file. An existing file without validator is
+// downloaded again too. Errors of the response are returned as
+// *DownloadError.
+func Download(ctx context.Context, url, path string, opts ...TransferOption) (TransferReport, error) {
+	cfg := newTransferConfig(opts)
+	start := time.Now()
+
+	var offset int64
+	var validator string
+	if !cfg.noResume {
+		info, err := os.Stat(path)
+		if err == nil && info.Mode().IsRegular() {
+			if data, err := ioutil.ReadFile(path + validatorSuffix); err == nil && len(data) > 0 {
+				offset, validator = info.Size(), string(data)
+			}
+		}
+	}
+
+	req := transferClient.R(cfg.requestOpts...).SetContext(ctx)
+	if offset > 0 {
+		req.Headers(map[string]string{"Range": fmt.Sprintf("bytes=%d-", offset), "If-Range": validator})
+	}
+	res, err := req.Get(url)
+	if err != nil {
+		return TransferReport{}, err
+	}
+	body := res.GetBody()
+	defer body.Close()
+
+	resumed, complete := false, false
+	switch {
+	case offset > 0 && res.resp.StatusCode == http.StatusPartialContent:
+		contentRange := res.resp.Header.Get("Content-Range")
+		first, _, _, err := parseContentRange(contentRange)
+		if err == nil && first != offset {
+			err = fmt.Errorf("gohttp: Content-Range %q does not resume at %d", contentRange, offset)
+		}
+		if err != nil {
+			return TransferReport{}, res.downloadError(err)
+		}
+		resumed = true
+	case offset > 0 && res.resp.StatusCode == http.StatusRequestedRangeNotSatisfiable:
+		// the file is complete if the server has nothing after it
+		length, err := unsatisfiedRangeLength(res.resp.Header.Get("Content-Range"))
+		if err != nil || length != offset {
+			return TransferReport{}, res.downloadError(ErrRangeNotSatisfiable)
+		}
+		resumed, complete = true, true
+	case res.resp.StatusCode >= 400:
+		return TransferReport{}, res.downloadError(ErrBadStatus)
+	default:
+		offset = 0
+		if err := saveValidator(path, res.resp); err != nil {
+			return TransferReport{}, res.downloadError(err)
+		}
+	}
+
+	flags := os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	if resumed {
+		flags = os.O_RDWR | os.O_APPEND
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if err != nil {
+		return TransferReport{}, res.downloadError(err)
+	}
+
+	var h hash.Hash
+	if cfg.newHash != nil {
+		h = cfg.newHash()
+		if resumed {
+			if _, err := io.Copy(h, f); err != nil {
+				f.Close()
+				return TransferReport{}, res.downloadError(err)
+			}
+		}
+	}
+
+	var r io.Reader = body
+	total := res.resp.ContentLength
+	if complete {
+		r, total = strings.NewReader(""), 0
+	}
+	if h != nil {
+		r = io.TeeReader(r, h)
+	}
+	if cfg.progress != nil {
+		if total >= 0 {
+			total += offset
+		}
+		r = &progressReader{r: r, total: total, fn: func(written, total int64) {
+			cfg.progress(offset+written, total)
+		}}
+	}
+
+	n, err := io.Copy(f, r)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err == nil && h != nil {
+		if err = cfg.verify(h); err != nil {
+			os.Remove(path)
+		}
+	}
+	if err == nil || errors.Is(err, ErrChecksumMismatch) {
+		os.Remove(path + validatorSuffix)
+	}
+	if err != nil {
+		return TransferReport{}, res.downloadError(err)
+	}
+
+	return newTransferReport(n, time.Since(start), resumed), nil
+}
+
+// UploadFile sends the file at path as the form field fieldName of a
+// multipart POST request to url. The file is streamed while the request is
+// sent, with a checksum it is read a first time to check it before. A
+// response with an error status is returned with ErrBadStatus.
+func UploadFile(ctx context.Context, url, fieldName, path string, opts ...TransferOption) (*Response, error) {
+	cfg := newTransferConfig(opts)
+
+	if cfg.newHash != nil {
+		if err := cfg.verifyFile(path); err != nil {
+			return nil, err
+		}
+	} else if _, err := os.Stat(path); err != nil {
+		return nil, err
+	}
+
+	req := transferClient.R(cfg.requestOpts...).SetContext(ctx)
+	req.addStreamedParts(multipartPart{fieldName: fieldName, fileName: filepath.Base(path), file: true, contentType: defaultFileContentType, path: path})
+	if cfg.progress != nil {
+		req.uploadProgress = cfg.progress
+	}
+
+	res, err := req.Post(url)
+	if err != nil {
+		return res, err
+	}
+	if res.resp.StatusCode >= 400 {
+		return res, fmt.Errorf("%w: %s", ErrBadStatus, res.resp.Status)
+	}
+	return res, nil
+}
+
+// saveValidator keeps the validator of resp next to the download at path,
+// or removes the one kept before when resp has none usable in If-Range
+func saveValidator(path string, resp *http.Response) error {
+	validator := resp.Header.Get("ETag")
+	if validator == "" || strings.HasPrefix(validator, "W/") {
+		// a weak ETag can't be used in If-Range
+		validator = resp.Header.Get("Last-Modified")
+	}
+	if validator == "" {
+		if err := os.Remove(path + validatorSuffix); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+		return nil
+	}
+	return ioutil.WriteFile(path+validatorSuffix, []byte(validator), 0644)
+}
+
+func newTransferReport(n int64, d time.Duration, resumed bool) TransferReport {
+	report := TransferReport{Bytes: n, Duration: d, Resumed: resumed}
+	if secs := d.Seconds(); secs > 0 {
+		report.AverageRate = float64(n) / secs
+	}
+	return report
+}
+
+// unsatisfiedRangeLength parses the Content-Range "bytes */1000" of a 416
+// Range Not Satisfiable response, it returns the complete length
+func unsatisfiedRangeLength(val string) (int64, error) {
+	if !strings.HasPrefix(val, "bytes */") {
+		return 0, fmt.Errorf("gohttp: invalid Content-Range %q", val)
+	}
+	return strconv.ParseInt(strings.TrimPrefix(val, "bytes */"), 10, 64)
+}
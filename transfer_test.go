@@ -0,0 +1,227 @@
+package gohttp
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+// newDownloadServer returns a server of the file content with the ETag
+// etag, the path /cut sends its first half only
+func newDownloadServer(t *testing.T, content *[]byte, etag *string, ranges *[]string) *httptest.Server {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("ETag", *etag)
+		if r.URL.Path == "/cut" {
+			w.Header().Set("Content-Length", strconv.Itoa(len(*content)))
+			w.Write((*content)[:len(*content)/2])
+			return
+		}
+		*ranges = append(*ranges, r.Header.Get("Range")+"|"+r.Header.Get("If-Range"))
+		http.ServeContent(w, r, "file.txt", time.Time{}, bytes.NewReader(*content))
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+// TestDownload tests fresh, resumed and complete downloads
+func TestDownload(t *testing.T) {
+	t.Log("Downloading file in parts... (expected resumed download with matching content)")
+
+	content := bytes.Repeat([]byte("0123456789"), 1000)
+	sum := sha256.Sum256(content)
+	checksum := hex.EncodeToString(sum[:])
+	etag := `"v1"`
+
+	var ranges []string
+	ts := newDownloadServer(t, &content, &etag, &ranges)
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if _, err := Download(context.Background(), ts.URL+"/cut", path); err == nil {
+		t.Fatal("expected interrupted download to fail")
+	}
+
+	var lastWritten, lastTotal int64
+	progress := TransferProgress(func(written, total int64) {
+		lastWritten, lastTotal = written, total
+	})
+	saveValidator := func() {
+		if err := ioutil.WriteFile(path+".validator", []byte(etag), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name    string
+		setup   func()
+		opts    []TransferOption
+		rng     string
+		bytes   int64
+		resumed bool
+	}{
+		{"resumed", func() {}, []TransferOption{progress, TransferChecksum(checksum)}, `bytes=5000-|"v1"`, 5000, true},
+		{"complete", saveValidator, []TransferOption{TransferChecksum(checksum)}, `bytes=10000-|"v1"`, 0, true},
+		{"without validator", func() {}, nil, "|", 10000, false},
+		{"TransferNoResume", saveValidator, []TransferOption{TransferNoResume()}, "|", 10000, false},
+	}
+
+	for _, tt := range tests {
+		tt.setup()
+		ranges = nil
+		report, err := Download(context.Background(), ts.URL, path, tt.opts...)
+		if err != nil {
+			t.Fatal(tt.name, err)
+		}
+
+		got, _ := ioutil.ReadFile(path)
+		if report.Bytes != tt.bytes || report.Resumed != tt.resumed || len(ranges) != 1 || ranges[0] != tt.rng || !bytes.Equal(got, content) {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.bytes, tt.resumed, tt.rng,
+				"got", report.Bytes, report.Resumed, ranges, len(got),
+			)
+		}
+		if _, err := os.Stat(path + ".validator"); !os.IsNotExist(err) {
+			t.Error(
+				"For", tt.name,
+				"expected", "validator removed",
+				"got", err,
+			)
+		}
+	}
+
+	if lastWritten != 10000 || lastTotal != 10000 {
+		t.Error(
+			"For", "TransferProgress",
+			"expected", 10000, 10000,
+			"got", lastWritten, lastTotal,
+		)
+	}
+
+	os.Remove(path)
+	_, err := Download(context.Background(), ts.URL, path, TransferChecksum("00"))
+	if _, statErr := os.Stat(path); !errors.Is(err, ErrChecksumMismatch) || !os.IsNotExist(statErr) {
+		t.Error(
+			"For", "checksum mismatch",
+			"expected", ErrChecksumMismatch, "and file removed",
+			"got", err, statErr,
+		)
+	}
+
+	_, err = Download(context.Background(), ts.URL+"/missing", path)
+	var dErr *DownloadError
+	if !errors.As(err, &dErr) {
+		t.Error(
+			"For", "DownloadError",
+			"expected", "*DownloadError",
+			"got", err,
+		)
+	}
+}
+
+// TestDownloadChanged tests resuming a download of a resource changed since
+// it was interrupted
+func TestDownloadChanged(t *testing.T) {
+	t.Log("Resuming download of a changed file... (expected the new file downloaded whole)")
+
+	content := bytes.Repeat([]byte("old "), 1000)
+	etag := `"v1"`
+	var ranges []string
+	ts := newDownloadServer(t, &content, &etag, &ranges)
+
+	path := filepath.Join(t.TempDir(), "file.txt")
+	if _, err := Download(context.Background(), ts.URL+"/cut", path); err == nil {
+		t.Fatal("expected interrupted download to fail")
+	}
+
+	content, etag = bytes.Repeat([]byte("new "), 1500), `"v2"`
+	report, err := Download(context.Background(), ts.URL, path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	got, _ := ioutil.ReadFile(path)
+	if !bytes.Equal(got, content) || report.Resumed || report.Bytes != 6000 || len(ranges) != 1 || ranges[0] != `bytes=2000-|"v1"` {
+		t.Error(
+			"For", "changed file",
+			"expected", "6000 new bytes, not resumed", `bytes=2000-|"v1"`,
+			"got", report.Bytes, report.Resumed, ranges, string(got[:8]),
+		)
+	}
+}
+
+// TestUploadFile tests a file is uploaded as multipart form field
+func TestUploadFile(t *testing.T) {
+	t.Log("Uploading file... (expected file content in form field)")
+
+	var received []byte
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		f, header, err := r.FormFile("report")
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		defer f.Close()
+		received, _ = ioutil.ReadAll(f)
+		w.Write([]byte(header.Filename))
+	}))
+	defer ts.Close()
+
+	content := []byte("quarterly numbers")
+	sum := sha256.Sum256(content)
+	path := filepath.Join(t.TempDir(), "report.txt")
+	if err := ioutil.WriteFile(path, content, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	var progressCalls int
+	resp, err := UploadFile(context.Background(), ts.URL, "report", path,
+		TransferChecksum(hex.EncodeToString(sum[:])),
+		TransferProgress(func(written, total int64) {
+			progressCalls++
+		}),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	name, _ := resp.GetBodyAsString()
+	if name != "report.txt" || !bytes.Equal(received, content) || progressCalls == 0 {
+		t.Error(
+			"For", "UploadFile",
+			"expected", "report.txt", string(content),
+			"got", name, string(received), progressCalls,
+		)
+	}
+
+	received = nil
+	_, err = UploadFile(context.Background(), ts.URL, "report", path, TransferChecksum("00"))
+	if !errors.Is(err, ErrChecksumMismatch) || received != nil {
+		t.Error(
+			"For", "checksum mismatch",
+			"expected", ErrChecksumMismatch, "and nothing sent",
+			"got", err, string(received),
+		)
+	}
+
+	_, err = UploadFile(context.Background(), ts.URL, "other", path)
+	if !errors.Is(err, ErrBadStatus) {
+		t.Error(
+			"For", "400",
+			"expected", ErrBadStatus,
+			"got", err,
+		)
+	}
+}
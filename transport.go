@@ -0,0 +1,151 @@
+package gohttp
+
+import (
+	"context"
+	"crypto/tls"
+	"net"
+	"net/http"
+	"time"
+)
+
+// defaultExpectContinueTimeout is used with ExpectContinue when the
+// transport has none, without it the body is sent right away
+const defaultExpectContinueTimeout = time.Second
+
+// baseTransport returns the round tripper for a new client, before
+// middlewares are added
+func (req *Request) baseTransport() http.RoundTripper {
+	if req.roundTripper != nil {
+		return req.roundTripper
+	}
+	return req.buildTransport()
+}
+
+// buildTransport returns the transport for a new client. A transport we
+// don't own is never modified, a copy of it is configured instead.
+func (req *Request) buildTransport() *http.Transport {
+	tr := req.transport
+	if tr == nil {
+		tr = http.DefaultTransport.(*http.Transport)
+	}
+
+	hooks := req.hooks()
+	expectContinue := req.expectContinue && tr.ExpectContinueTimeout == 0
+	custom := req.proxy != nil || req.noProxy || len(hooks.connClosedHooks) > 0 ||
+		expectContinue || req.http1Only || req.http2 != nil || req.strictTLS != nil ||
+		req.tlsConfig != nil || len(req.tlsOptions) > 0 || req.skipTLSVerify != nil ||
+		req.unixSocket != "" || req.connectTimeout > 0 || req.readTimeout > 0
+	if !custom {
+		return tr
+	}
+
+	tr = tr.Clone()
+	if req.proxy != nil {
+		tr.Proxy = req.proxy
+	}
+	if req.noProxy {
+		tr.Proxy = nil
+	}
+	if req.unixSocket != "" {
+		tr.Proxy = nil
+		tr.DialContext = unixSocketDialer(req.unixSocket)
+	}
+	if req.connectTimeout > 0 {
+		tr.DialContext = timeoutDialer(tr.DialContext, req.connectTimeout)
+		tr.TLSHandshakeTimeout = req.connectTimeout
+	}
+	if req.readTimeout > 0 {
+		tr.ResponseHeaderTimeout = req.readTimeout
+	}
+	if len(hooks.connClosedHooks) > 0 {
+		tr.DialContext = req.trackingDialer(tr.DialContext)
+	}
+	if expectContinue {
+		tr.ExpectContinueTimeout = defaultExpectContinueTimeout
+	}
+	if req.http1Only || (req.http2 != nil && !*req.http2) {
+		tr.ForceAttemptHTTP2 = false
+		tr.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if req.http2 != nil && *req.http2 {
+		// a nil TLSNextProto lets the transport configure its HTTP/2
+		// support on first use
+		tr.ForceAttemptHTTP2 = true
+		tr.TLSNextProto = nil
+	}
+	if req.tlsConfig != nil || len(req.tlsOptions) > 0 || req.skipTLSVerify != nil {
+		cfg := tr.TLSClientConfig
+		if req.tlsConfig != nil {
+			cfg = req.tlsConfig.Clone()
+		}
+		if cfg == nil {
+			cfg = &tls.Config{}
+		}
+		for _, opt := range req.tlsOptions {
+			opt(cfg)
+		}
+		if req.skipTLSVerify != nil {
+			cfg.InsecureSkipVerify = *req.skipTLSVerify
+			if cfg.InsecureSkipVerify {
+				req.warnf("gohttp: TLS certificate verification is disabled by WithSkipTLSVerify, don't use it in production")
+			}
+		}
+		tr.TLSClientConfig = cfg
+	}
+	if req.strictTLS != nil {
+		if tr.TLSClientConfig == nil {
+			tr.TLSClientConfig = &tls.Config{}
+		}
+		tr.TLSClientConfig.VerifyConnection = req.strictTLS.verifier(tr.TLSClientConfig.VerifyConnection)
+	}
+	if tr.TLSClientConfig != nil && !speaksHTTP2(tr) {
+		// the config copied from a transport which speaks HTTP/2 offers h2
+		// to servers, which would answer with HTTP/2 frames
+		tr.TLSClientConfig.NextProtos = withoutProto(tr.TLSClientConfig.NextProtos, "h2")
+	}
+
+	return tr
+}
+
+// unixSocketDialer returns a dial function connecting to the Unix socket
+// at path whatever the address
+func unixSocketDialer(path string) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	var d net.Dialer
+	return func(ctx context.Context, _, _ string) (net.Conn, error) {
+		return d.DialContext(ctx, "unix", path)
+	}
+}
+
+// timeoutDialer returns dial, or a net.Dialer when it is nil, limited to d
+func timeoutDialer(dial func(ctx context.Context, network, addr string) (net.Conn, error), d time.Duration) func(ctx context.Context, network, addr string) (net.Conn, error) {
+	if dial == nil {
+		dial = (&net.Dialer{}).DialContext
+	}
+	return func(ctx context.Context, network, addr string) (net.Conn, error) {
+		ctx, cancel := context.WithTimeout(ctx, d)
+		defer cancel()
+		return dial(ctx, network, addr)
+	}
+}
+
+// speaksHTTP2 reports whether tr, which has a TLS config, negotiates
+// HTTP/2, see the TLSNextProto and ForceAttemptHTTP2 docs of
+// http.Transport
+func speaksHTTP2(tr *http.Transport) bool {
+	if tr.TLSNextProto != nil {
+		return tr.TLSNextProto["h2"] != nil
+	}
+	return tr.ForceAttemptHTTP2
+}
+
+// withoutProto returns the ALPN protocols protos without proto, protos is
+// not modified
+func withoutProto(protos []string, proto string) []string {
+	var kept []string
+	for _, p := range protos {
+		if p != proto {
+			kept = append(kept, p)
+		}
+	}
+	return kept
+}
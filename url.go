@@ -0,0 +1,198 @@
+package gohttp
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strings"
+)
+
+// resolveURL resolves rawURL against the base URL set with WithBaseURL. An
+// absolute rawURL is used as is, a path is appended to the path of the
+// base, e.g. "users/1" and "/users/1" both resolve against
+// "https://api.example.com/v1" to "https://api.example.com/v1/users/1".
+func (req *Request) resolveURL(rawURL string) (string, error) {
+	if req.baseURL == nil {
+		return rawURL, nil
+	}
+
+	ref, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+	if ref.IsAbs() || ref.Host != "" {
+		return rawURL, nil
+	}
+
+	base := *req.baseURL
+	if !strings.HasSuffix(base.Path, "/") {
+		base.Path += "/"
+		base.RawPath = ""
+	}
+	ref.Path = strings.TrimLeft(ref.Path, "/")
+	ref.RawPath = strings.TrimLeft(ref.RawPath, "/")
+
+	return base.ResolveReference(ref).String(), nil
+}
+
+// bodylessMethod reports whether requests with method are sent without a
+// body in practice
+func bodylessMethod(method string) bool {
+	switch strings.ToUpper(method) {
+	case http.MethodGet, http.MethodHead, http.MethodOptions:
+		return true
+	}
+	return false
+}
+
+// mergeQuery returns a copy of query with the encoded parameters of extra
+// appended, parameters given in both are kept with all their values
+func mergeQuery(query url.Values, extra string) url.Values {
+	vals := copyValues(query)
+	extraVals, _ := url.ParseQuery(extra)
+	for key, val := range extraVals {
+		vals[key] = append(vals[key], val...)
+	}
+	return vals
+}
+
+// copyValues returns a copy of vals which can be added to without
+// changing vals
+func copyValues(vals url.Values) url.Values {
+	c := make(url.Values, len(vals))
+	for key, val := range vals {
+		c[key] = append([]string(nil), val...)
+	}
+	return c
+}
+
+// appendQuery returns rawURL with the encoded query appended to its own
+// query, before its fragment
+func appendQuery(rawURL, query string) string {
+	if query == "" {
+		return rawURL
+	}
+
+	fragment := ""
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		rawURL, fragment = rawURL[:i], rawURL[i:]
+	}
+	switch {
+	case !strings.Contains(rawURL, "?"):
+		rawURL += "?"
+	case !strings.HasSuffix(rawURL, "?") && !strings.HasSuffix(rawURL, "&"):
+		rawURL += "&"
+	}
+	return rawURL + query + fragment
+}
+
+// ErrQueryEncoding is returned for invalid QueryEncodingOptions and for
+// requests mixing them with a query written in the URL
+var ErrQueryEncoding = errors.New("gohttp: invalid query encoding")
+
+// QueryEncodingOptions change how the query params of a request are
+// encoded, see QueryEncoding.
+//
+// They are meant for legacy servers only, e.g. ones splitting the query on
+// semicolons or expecting a raw pipe in values. The query they give isn't
+// the one of HTML forms, most servers, including net/http since Go 1.17,
+// won't parse it as expected.
+type QueryEncodingOptions struct {
+	// Delimiter separates the params, & when it is zero, e.g. ';' for
+	// a=1;b=2. It can't be a letter, digit or one of -._~=%+#? which
+	// would be ambiguous.
+	Delimiter rune
+	// UnescapedChars are sent as they are in keys and values, e.g. "|" for
+	// filter=a|b. They can't be the Delimiter, a letter, digit, space or
+	// one of =%+#, and must be ASCII.
+	UnescapedChars string
+}
+
+// delimiter returns the delimiter of the params
+func (o QueryEncodingOptions) delimiter() rune {
+	if o.Delimiter == 0 {
+		return '&'
+	}
+	return o.Delimiter
+}
+
+// validate returns an error for options giving an ambiguous query
+func (o QueryEncodingOptions) validate() error {
+	plain := func(c rune) bool {
+		return c <= ' ' || c >= 0x7f || 'a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9'
+	}
+
+	delim := o.delimiter()
+	if plain(delim) || strings.ContainsRune("-._~=%+#?", delim) {
+		return fmt.Errorf("%w: delimiter %q", ErrQueryEncoding, delim)
+	}
+	for _, c := range o.UnescapedChars {
+		if plain(c) || c == delim || strings.ContainsRune("=%+#", c) {
+			return fmt.Errorf("%w: unescaped character %q", ErrQueryEncoding, c)
+		}
+	}
+	return nil
+}
+
+// encode returns vals encoded with the options, sorted by key like
+// url.Values.Encode
+func (o QueryEncodingOptions) encode(vals url.Values) string {
+	escape := func(s string) string {
+		s = url.QueryEscape(s)
+		// every % of the escaped string starts an escape
+		for _, c := range o.UnescapedChars {
+			s = strings.ReplaceAll(s, fmt.Sprintf("%%%02X", c), string(c))
+		}
+		return s
+	}
+
+	keys := make([]string, 0, len(vals))
+	for key := range vals {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	var params []string
+	for _, key := range keys {
+		for _, val := range vals[key] {
+			params = append(params, escape(key)+"="+escape(val))
+		}
+	}
+	return strings.Join(params, string(o.delimiter()))
+}
+
+// QueryEncoding encodes the query params set with Query and the other query
+// methods with opts, e.g. to send ?a=1;b=2 or filter=a|b to a legacy
+// server, see QueryEncodingOptions. As the query written in the URL is
+// encoded otherwise, a request having both fails with ErrQueryEncoding,
+// like invalid opts.
+func (req *Request) QueryEncoding(opts QueryEncodingOptions) *Request {
+	if err := opts.validate(); err != nil {
+		req.setErr(err)
+		return req
+	}
+	req.queryEncoding = &opts
+
+	return req
+}
+
+// encodeQuery returns the query params of the request to append to rawURL,
+// encoded with the QueryEncoding options
+func (req *Request) encodeQuery(rawURL string) (string, error) {
+	if req.queryEncoding == nil {
+		return req.queryVals.Encode(), nil
+	}
+	if len(req.queryVals) == 0 {
+		return "", nil
+	}
+
+	if i := strings.IndexByte(rawURL, '#'); i >= 0 {
+		rawURL = rawURL[:i]
+	}
+	if i := strings.IndexByte(rawURL, '?'); i >= 0 && i < len(rawURL)-1 {
+		return "", fmt.Errorf("%w: query params can't be added to the query %q of the URL", ErrQueryEncoding, rawURL[i+1:])
+	}
+	return req.queryEncoding.encode(req.queryVals), nil
+}
@@ -0,0 +1,64 @@
+//go:build go1.18
+// +build go1.18
+
+package gohttp
+
+import (
+	"net/url"
+	"strings"
+	"testing"
+	"unicode"
+)
+
+// FuzzMakeRequestURL tests the URL a request is sent to keeps the scheme,
+// host, path and fragment of the given URL whatever the query parameters
+func FuzzMakeRequestURL(f *testing.F) {
+	seeds := []struct{ base, key, val string }{
+		{"https://api.example.com/users", "q", "go"},
+		{"https://api.example.com/users?page=2", "q", "a b&c=d"},
+		{"https://api.example.com/users?", "next", "/admin"},
+		{"https://api.example.com/users?a=1&", "x", "?y=z#frag"},
+		{"https://api.example.com/users#top", "redirect", "https://evil.example.com/"},
+		{"/relative/path", "../..", "%2F..%2F"},
+		{"https://api.example.com/users", "k\r\nX-Injected: 1", "\x00\t"},
+	}
+	for _, s := range seeds {
+		f.Add(s.base, s.key, s.val)
+	}
+
+	f.Fuzz(func(t *testing.T, base, key, val string) {
+		u, err := url.Parse(base)
+		if err != nil || hasControl(base) {
+			t.Skip()
+		}
+
+		req := NewRequest().Query(map[string]string{key: val})
+		resolved, err := req.resolveURL(base)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got := appendQuery(resolved, req.queryVals.Encode())
+
+		g, err := url.Parse(got)
+		if err != nil {
+			t.Fatalf("%q with %q=%q: invalid URL %q: %v", base, key, val, got, err)
+		}
+		if hasControl(got) {
+			t.Fatalf("%q with %q=%q: control character in %q", base, key, val, got)
+		}
+		if g.Scheme != u.Scheme || g.Host != u.Host || g.EscapedPath() != u.EscapedPath() || g.Fragment != u.Fragment {
+			t.Fatalf("%q with %q=%q: URL changed to %q", base, key, val, got)
+		}
+		if !strings.HasPrefix(g.RawQuery, u.RawQuery) || strings.Contains(g.RawQuery[len(u.RawQuery):], "?") {
+			t.Fatalf("%q with %q=%q: query of %q not appended", base, key, val, got)
+		}
+		if vals := g.Query()[key]; len(vals) == 0 || vals[len(vals)-1] != val {
+			t.Fatalf("%q with %q=%q: parameter lost in %q", base, key, val, got)
+		}
+	})
+}
+
+// hasControl reports whether s has a control character
+func hasControl(s string) bool {
+	return strings.IndexFunc(s, unicode.IsControl) >= 0
+}
@@ -0,0 +1,401 @@
+package gohttp
+
+import (
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+// TestWithBaseURL tests joining paths to the base URL
+func TestWithBaseURL(t *testing.T) {
+	t.Log("Resolving URLs against base URL... (expected joined URLs)")
+
+	tests := []struct {
+		base     string
+		url      string
+		expected string
+	}{
+		{"https://api.example.com", "/users/1", "https://api.example.com/users/1"},
+		{"https://api.example.com/", "users/1", "https://api.example.com/users/1"},
+		{"https://api.example.com/v1", "/users/1", "https://api.example.com/v1/users/1"},
+		{"https://api.example.com/v1/", "/users/1", "https://api.example.com/v1/users/1"},
+		{"https://api.example.com/v1", "users/1?q=a%20b", "https://api.example.com/v1/users/1?q=a%20b"},
+		{"https://api.example.com/v1", "", "https://api.example.com/v1/"},
+		{"https://api.example.com/v1", "https://other.example.com/x", "https://other.example.com/x"},
+		{"https://api.example.com/v1", "//cdn.example.com/x", "//cdn.example.com/x"},
+	}
+
+	for _, tt := range tests {
+		got, err := NewRequest(WithBaseURL(tt.base)).resolveURL(tt.url)
+		if err != nil || got != tt.expected {
+			t.Error(
+				"For", tt.base, tt.url,
+				"expected", tt.expected,
+				"got", got, err,
+			)
+		}
+	}
+}
+
+// TestWithBaseURLSend tests a request is sent to the resolved URL
+func TestWithBaseURLSend(t *testing.T) {
+	t.Log("Sending GET request with base URL... (expected resolved path)")
+
+	mock := NewMockTransport().Register("GET", "http://example.com/api/users/1", mockResponseWith(200, "ok"))
+
+	if _, err := NewRequest(WithTransport(mock), WithBaseURL("http://example.com/api")).Get("/users/1"); err != nil {
+		t.Fatal(err)
+	}
+	mock.AssertExpectations(t)
+
+	if _, err := NewRequest(WithBaseURL("/relative")).Get("/users/1"); err == nil {
+		t.Error(
+			"For", "relative base URL",
+			"expected", "error",
+			"got", err,
+		)
+	}
+}
+
+// TestFormDataOnGet tests form data of a GET request is sent in the query
+func TestFormDataOnGet(t *testing.T) {
+	t.Log("Sending GET request with form data and query... (expected merged query, no body)")
+
+	var query url.Values
+	var body []byte
+	var contentType string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		query = r.URL.Query()
+		body, _ = ioutil.ReadAll(r.Body)
+		contentType = r.Header.Get("Content-Type")
+	}))
+	defer ts.Close()
+
+	_, err := NewRequest().
+		Query(map[string]string{"page": "2", "q": "go"}).
+		FormData(map[string]string{"q": "http", "sort": "asc"}).
+		Get(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "page=2&q=go&q=http&sort=asc"
+	if query.Encode() != expected || len(body) != 0 || contentType == "application/x-www-form-urlencoded" {
+		t.Error(
+			"For", "GET",
+			"expected", expected, "and no body",
+			"got", query.Encode(), string(body), contentType,
+		)
+	}
+
+	_, err = NewRequest().
+		FormData(map[string]string{"q": "http"}).
+		Post(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(query) != 0 || string(body) != "q=http" {
+		t.Error(
+			"For", "POST",
+			"expected", "q=http body",
+			"got", query.Encode(), string(body),
+		)
+	}
+}
+
+// TestAppendQuery tests the query parameters are appended to the query of
+// the URL, before its fragment
+func TestAppendQuery(t *testing.T) {
+	t.Log("Appending query to URLs... (expected a single query before the fragment)")
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"http://example.com/users", "http://example.com/users?q=go"},
+		{"http://example.com/users?page=2", "http://example.com/users?page=2&q=go"},
+		{"http://example.com/users?", "http://example.com/users?q=go"},
+		{"http://example.com/users?page=2&", "http://example.com/users?page=2&q=go"},
+		{"http://example.com/users#top", "http://example.com/users?q=go#top"},
+		{"http://example.com/users#a?b", "http://example.com/users?q=go#a?b"},
+	}
+
+	for _, tt := range tests {
+		if got := appendQuery(tt.url, "q=go"); got != tt.expected {
+			t.Error(
+				"For", tt.url,
+				"expected", tt.expected,
+				"got", got,
+			)
+		}
+	}
+}
+
+// TestQueryWithURLQuery tests Query values are sent along with the query of
+// the URL and leave the Content-Type of the body alone
+func TestQueryWithURLQuery(t *testing.T) {
+	t.Log("Sending requests with query in URL and Query... (expected both queries and body Content-Type)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery + " " + r.Header.Get("Content-Type")))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		send     func() (*Response, error)
+		expected string
+	}{
+		{
+			"GET",
+			func() (*Response, error) {
+				return NewRequest().Query(map[string]string{"limit": "10"}).Get(ts.URL + "/items?page=2")
+			},
+			"page=2&limit=10 ",
+		},
+		{
+			"same key",
+			func() (*Response, error) {
+				return NewRequest().Query(map[string]string{"page": "3"}).Get(ts.URL + "/items?page=2#top")
+			},
+			"page=2&page=3 ",
+		},
+		{
+			"JSON then Query",
+			func() (*Response, error) {
+				return NewRequest().JSON(map[string]interface{}{"a": 1}).Query(map[string]string{"dry": "1"}).Post(ts.URL + "/items?v=2")
+			},
+			"v=2&dry=1 application/json",
+		},
+		{
+			"Query without body",
+			func() (*Response, error) {
+				return NewRequest().Query(map[string]string{"dry": "1"}).Delete(ts.URL + "/items/1")
+			},
+			"dry=1 ",
+		},
+	}
+
+	for _, tt := range tests {
+		resp, err := tt.send()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := resp.GetBodyAsString(); got != tt.expected {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", got,
+			)
+		}
+	}
+}
+
+// TestMultiValueQuery tests repeated query params are sent in order and
+// that the query methods accumulate
+func TestMultiValueQuery(t *testing.T) {
+	t.Log("Sending requests with repeated query params... (expected every value in order)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		req      *Request
+		expected string
+	}{
+		{"AddQuery", NewRequest().AddQuery("tag", "b").AddQuery("tag", "a"), "tag=b&tag=a"},
+		{"QuerySlice", NewRequest().QuerySlice("tag", []string{"b", "a"}).QuerySlice("tag", []string{"c"}), "tag=b&tag=a&tag=c"},
+		{"QueryValues", NewRequest().QueryValues(url.Values{"tag": {"a", "b"}, "q": {"go lang"}}), "q=go+lang&tag=a&tag=b"},
+		{"escaped", NewRequest().AddQuery("a&b", "c=d").AddQuery("e", "ü#"), "a%26b=c%3Dd&e=%C3%BC%23"},
+		{"Query twice", NewRequest().Query(map[string]string{"page": "1", "q": "go"}).Query(map[string]string{"page": "2"}), "page=2&q=go"},
+		{"Query and AddQuery", NewRequest().Query(map[string]string{"tag": "a"}).AddQuery("tag", "b"), "tag=a&tag=b"},
+	}
+
+	for _, tt := range tests {
+		clone := tt.req.Clone().AddQuery("clone", "1")
+		resp, err := tt.req.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := resp.GetBodyAsString(); got != tt.expected {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", got,
+			)
+		}
+		if _, ok := tt.req.queryVals["clone"]; ok || clone.queryVals.Get("clone") != "1" {
+			t.Error(
+				"For", tt.name, "clone",
+				"expected", "separate query",
+				"got", tt.req.queryVals,
+			)
+		}
+	}
+}
+
+type queryFilters struct {
+	Status  string    `url:"status,omitempty"`
+	Limit   int       `url:"limit"`
+	Offset  *int      `url:"offset"`
+	Min     float64   `query:"min"`
+	Active  *bool     `url:"active"`
+	IDs     []int     `url:"id"`
+	Fields  []string  `url:"fields,comma"`
+	Since   time.Time `url:"since"`
+	Day     time.Time `url:"day" layout:"2006-01-02"`
+	Cursor  string    `url:"-"`
+	private string
+}
+
+// TestQueryStruct tests the query built from tagged structs and composed
+// with AddQuery
+func TestQueryStruct(t *testing.T) {
+	t.Log("Sending requests with struct queries... (expected encoded fields and errors naming fields)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	defer ts.Close()
+
+	active := false
+	day := time.Date(2024, 2, 29, 13, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name     string
+		req      *Request
+		expected string
+	}{
+		{
+			"all fields",
+			NewRequest().QueryStruct(queryFilters{
+				Status: "open", Limit: 10, Min: 0.5, Active: &active, IDs: []int{3, 1},
+				Fields: []string{"name", "email"}, Since: day, Day: day, Cursor: "c", private: "p",
+			}),
+			"active=false&day=2024-02-29&fields=name%2Cemail&id=3&id=1&limit=10&min=0.5&since=2024-02-29T13%3A00%3A00Z&status=open",
+		},
+		{
+			"empty",
+			NewRequest().QueryStruct(&queryFilters{}),
+			"day=0001-01-01&limit=0&min=0&since=0001-01-01T00%3A00%3A00Z",
+		},
+		{
+			"with AddQuery",
+			NewRequest().AddQuery("id", "7").QueryStruct(struct {
+				IDs []int `url:"id"`
+			}{[]int{8}}).AddQuery("page", "2"),
+			"id=7&id=8&page=2",
+		},
+	}
+
+	for _, tt := range tests {
+		resp, err := tt.req.Get(ts.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := resp.GetBodyAsString(); got != tt.expected {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", got,
+			)
+		}
+	}
+
+	_, err := NewRequest().QueryStruct(struct {
+		Callback func() `url:"callback"`
+	}{}).Get(ts.URL)
+	if err == nil || !strings.Contains(err.Error(), `"callback"`) {
+		t.Error(
+			"For", "func field",
+			"expected", "error naming callback",
+			"got", err,
+		)
+	}
+}
+
+// TestQueryEncoding tests the exact queries of the legacy encodings and the
+// errors of invalid or mixed ones
+func TestQueryEncoding(t *testing.T) {
+	t.Log("Sending requests with legacy query encodings... (expected exact queries)")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(r.URL.RawQuery))
+	}))
+	defer ts.Close()
+
+	tests := []struct {
+		name     string
+		req      *Request
+		url      string
+		expected string
+	}{
+		{
+			"semicolons",
+			NewRequest().QueryEncoding(QueryEncodingOptions{Delimiter: ';'}).Query(map[string]string{"b": "2;3", "a": "x y"}).AddQuery("a", "&"),
+			ts.URL,
+			"a=x+y;a=%26;b=2%3B3",
+		},
+		{
+			"unescaped pipes",
+			NewRequest().QueryEncoding(QueryEncodingOptions{UnescapedChars: "|,"}).AddQuery("filter", "a|b,c").AddQuery("raw", "%7C&"),
+			ts.URL + "/items?#top",
+			"filter=a|b,c&raw=%257C%26",
+		},
+		{
+			"both",
+			NewRequest().QueryEncoding(QueryEncodingOptions{Delimiter: ';', UnescapedChars: "|&"}).QuerySlice("f", []string{"a|b", "c&d"}).AddQuery("g", "1"),
+			ts.URL,
+			"f=a|b;f=c&d;g=1",
+		},
+		{
+			"URL query only",
+			NewRequest().QueryEncoding(QueryEncodingOptions{Delimiter: ';'}),
+			ts.URL + "?a=1;b=2",
+			"a=1;b=2",
+		},
+	}
+
+	for _, tt := range tests {
+		resp, err := tt.req.Get(tt.url)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := resp.GetBodyAsString(); got != tt.expected {
+			t.Error(
+				"For", tt.name,
+				"expected", tt.expected,
+				"got", got,
+			)
+		}
+	}
+
+	failing := []struct {
+		name string
+		req  *Request
+	}{
+		{"letter delimiter", NewRequest().QueryEncoding(QueryEncodingOptions{Delimiter: 'x'})},
+		{"equal delimiter", NewRequest().QueryEncoding(QueryEncodingOptions{Delimiter: '='})},
+		{"unescaped delimiter", NewRequest().QueryEncoding(QueryEncodingOptions{Delimiter: ';', UnescapedChars: ";"})},
+		{"unescaped percent", NewRequest().QueryEncoding(QueryEncodingOptions{UnescapedChars: "%"})},
+		{"unescaped space", NewRequest().QueryEncoding(QueryEncodingOptions{UnescapedChars: " "})},
+		{"mixed with URL query", NewRequest().QueryEncoding(QueryEncodingOptions{Delimiter: ';'}).AddQuery("b", "2")},
+	}
+
+	for _, tt := range failing {
+		if _, err := tt.req.Get(ts.URL + "?a=1"); !errors.Is(err, ErrQueryEncoding) {
+			t.Error(
+				"For", tt.name,
+				"expected", ErrQueryEncoding,
+				"got", err,
+			)
+		}
+	}
+}
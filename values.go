@@ -0,0 +1,188 @@
+package gohttp
+
+import (
+	"encoding"
+	"fmt"
+	"net/url"
+	"reflect"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// structEncoder encodes the fields of a struct into url.Values by the name
+// of their tag, see FormStruct and QueryStruct
+type structEncoder struct {
+	// tags are the struct tags naming a field, the first one set is used
+	tags []string
+	// what names the encoded values in errors, e.g. form
+	what string
+}
+
+// structValues returns the values of the struct or map v
+func (e structEncoder) structValues(v interface{}) (url.Values, error) {
+	rv := reflect.Indirect(reflect.ValueOf(v))
+	if rv.Kind() != reflect.Struct && rv.Kind() != reflect.Map {
+		return nil, fmt.Errorf("gohttp: %s of %T can't be encoded, expected a struct or map", e.what, v)
+	}
+
+	vals := url.Values{}
+	if err := e.encode(vals, "", rv, fieldOptions{}); err != nil {
+		return nil, err
+	}
+	return vals, nil
+}
+
+// fieldOptions are the options of a field tag
+type fieldOptions struct {
+	omitempty bool
+	// comma sends the values of a slice joined with commas
+	comma bool
+	// layout formats a time.Time, RFC 3339 by default
+	layout string
+}
+
+// tag returns the name and options the tag of field gives, skip is true for
+// the tag "-"
+func (e structEncoder) tag(field reflect.StructField) (name string, opts fieldOptions, skip bool) {
+	var tag string
+	for _, key := range e.tags {
+		if t, ok := field.Tag.Lookup(key); ok {
+			tag = t
+			break
+		}
+	}
+	if tag == "-" {
+		return "", opts, true
+	}
+
+	parts := strings.Split(tag, ",")
+	for _, opt := range parts[1:] {
+		switch opt {
+		case "omitempty":
+			opts.omitempty = true
+		case "comma":
+			opts.comma = true
+		}
+	}
+	opts.layout = field.Tag.Get("layout")
+	return parts[0], opts, false
+}
+
+// formKey returns the key of the child name of the value prefix
+func formKey(prefix, name string) string {
+	if prefix == "" {
+		return name
+	}
+	return prefix + "[" + name + "]"
+}
+
+// encode adds the values of v, which is sent with the key prefix, to vals
+func (e structEncoder) encode(vals url.Values, prefix string, v reflect.Value, opts fieldOptions) error {
+	for v.Kind() == reflect.Ptr || v.Kind() == reflect.Interface {
+		if v.IsNil() {
+			return nil
+		}
+		v = v.Elem()
+	}
+
+	// the values of an unexported embedded struct only give their fields
+	if v.CanInterface() {
+		switch x := v.Interface().(type) {
+		case time.Time:
+			layout := opts.layout
+			if layout == "" {
+				layout = time.RFC3339
+			}
+			vals.Add(prefix, x.Format(layout))
+			return nil
+		case encoding.TextMarshaler:
+			text, err := x.MarshalText()
+			if err != nil {
+				return fmt.Errorf("gohttp: %s field %q: %w", e.what, prefix, err)
+			}
+			vals.Add(prefix, string(text))
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Struct:
+		for i := 0; i < v.NumField(); i++ {
+			field := v.Type().Field(i)
+			embedded := field.Anonymous && reflect.Indirect(v.Field(i)).Kind() == reflect.Struct
+			if field.PkgPath != "" && !embedded {
+				continue
+			}
+			name, opts, skip := e.tag(field)
+			if skip || opts.omitempty && v.Field(i).IsZero() {
+				continue
+			}
+			key := formKey(prefix, name)
+			if name == "" {
+				// embedded structs share the keys of their parent
+				key = prefix
+				if !embedded {
+					key = formKey(prefix, field.Name)
+				}
+			}
+			if err := e.encode(vals, key, v.Field(i), opts); err != nil {
+				return err
+			}
+		}
+	case reflect.Map:
+		if v.Type().Key().Kind() != reflect.String {
+			return fmt.Errorf("gohttp: %s field %q of type %s can't be encoded", e.what, prefix, v.Type())
+		}
+		keys := v.MapKeys()
+		sort.Slice(keys, func(i, j int) bool {
+			return keys[i].String() < keys[j].String()
+		})
+		for _, k := range keys {
+			if err := e.encode(vals, formKey(prefix, k.String()), v.MapIndex(k), fieldOptions{}); err != nil {
+				return err
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Uint8 {
+			vals.Add(prefix, string(v.Bytes()))
+			return nil
+		}
+		if opts.comma {
+			elems := url.Values{}
+			for i := 0; i < v.Len(); i++ {
+				if err := e.encode(elems, prefix, v.Index(i), fieldOptions{layout: opts.layout}); err != nil {
+					return err
+				}
+			}
+			if len(elems[prefix]) > 0 {
+				vals.Add(prefix, strings.Join(elems[prefix], ","))
+			}
+			return nil
+		}
+		for i := 0; i < v.Len(); i++ {
+			// repeated keys can't tell which nested values belong together
+			key := prefix
+			if elem := reflect.Indirect(v.Index(i)); elem.Kind() == reflect.Struct || elem.Kind() == reflect.Map {
+				key = formKey(prefix, strconv.Itoa(i))
+			}
+			if err := e.encode(vals, key, v.Index(i), fieldOptions{layout: opts.layout}); err != nil {
+				return err
+			}
+		}
+	case reflect.String:
+		vals.Add(prefix, v.String())
+	case reflect.Bool:
+		vals.Add(prefix, strconv.FormatBool(v.Bool()))
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		vals.Add(prefix, strconv.FormatInt(v.Int(), 10))
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		vals.Add(prefix, strconv.FormatUint(v.Uint(), 10))
+	case reflect.Float32, reflect.Float64:
+		vals.Add(prefix, strconv.FormatFloat(v.Float(), 'f', -1, v.Type().Bits()))
+	default:
+		return fmt.Errorf("gohttp: %s field %q of type %s can't be encoded", e.what, prefix, v.Type())
+	}
+	return nil
+}
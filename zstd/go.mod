@@ -0,0 +1,12 @@
+module github.com/tenminschool/gohttp/zstd
+
+go 1.22
+
+require (
+	github.com/klauspost/compress v1.18.0
+	github.com/tenminschool/gohttp v0.1.0
+)
+
+require golang.org/x/sync v0.0.0-20220907140024-f12130a52804 // indirect
+
+replace github.com/tenminschool/gohttp => ../
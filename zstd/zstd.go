@@ -0,0 +1,41 @@
+// Package zstd registers the zstd content encoding with gohttp, for
+// WithCompression and WithRequestCompression("zstd"). It is a module of its
+// own so gohttp itself doesn't depend on github.com/klauspost/compress.
+//
+//	import _ "github.com/tenminschool/gohttp/zstd"
+package zstd
+
+import (
+	"io"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/tenminschool/gohttp"
+)
+
+// Encoding is the zstd content encoding
+var Encoding = gohttp.Encoding{
+	NewWriter: func(w io.Writer) (io.WriteCloser, error) {
+		return zstd.NewWriter(w)
+	},
+	NewReader: func(r io.Reader) (io.ReadCloser, error) {
+		d, err := zstd.NewReader(r, zstd.WithDecoderConcurrency(1))
+		if err != nil {
+			return nil, err
+		}
+		return decoder{d}, nil
+	},
+}
+
+func init() {
+	gohttp.RegisterEncoding("zstd", Encoding)
+}
+
+// decoder releases the resources of a zstd.Decoder on Close
+type decoder struct {
+	*zstd.Decoder
+}
+
+func (d decoder) Close() error {
+	d.Decoder.Close()
+	return nil
+}
@@ -0,0 +1,52 @@
+package zstd
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/klauspost/compress/zstd"
+	"github.com/tenminschool/gohttp"
+)
+
+// TestZstdRoundTrip tests a zstd request body is received and a zstd
+// response is decompressed
+func TestZstdRoundTrip(t *testing.T) {
+	t.Log("Sending zstd body and reading zstd response... (expected round trip)")
+
+	payload := strings.Repeat("gohttp zstd ", 100)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		d, err := zstd.NewReader(r.Body)
+		if err != nil || r.Header.Get("Content-Encoding") != "zstd" {
+			http.Error(w, "not zstd", http.StatusBadRequest)
+			return
+		}
+		defer d.Close()
+		body, err := ioutil.ReadAll(d)
+		if err != nil || !strings.Contains(r.Header.Get("Accept-Encoding"), "zstd") {
+			http.Error(w, "bad zstd body or Accept-Encoding", http.StatusBadRequest)
+			return
+		}
+
+		w.Header().Set("Content-Encoding", "zstd")
+		e, _ := zstd.NewWriter(w)
+		e.Write([]byte(strings.ToUpper(string(body))))
+		e.Close()
+	}))
+	defer ts.Close()
+
+	resp, err := gohttp.NewRequest(gohttp.WithCompression(), gohttp.WithRequestCompression("zstd")).Text(payload).Post(ts.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, err := resp.GetBodyAsString()
+	if err != nil || resp.GetStatusCode() != 200 || body != strings.ToUpper(payload) || resp.Header("Content-Encoding") != "" {
+		t.Error(
+			"For", "zstd",
+			"expected", strings.ToUpper(payload)[:24],
+			"got", resp.GetStatusCode(), body, err,
+		)
+	}
+}